@@ -312,6 +312,7 @@ func main() {
 				enrichedProxies = append(enrichedProxies, map[string]any{
 					"config_id": id,
 					"listen":    config.ListenAddr,
+					"mode":      config.Mode,
 					"target":    config.TargetURL.String(),
 					"active":    active,
 				})
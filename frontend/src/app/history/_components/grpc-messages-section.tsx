@@ -0,0 +1,65 @@
+/** biome-ignore-all lint/suspicious/noArrayIndexKey: messages have no ID */
+import { ChevronDown, ChevronRight } from "lucide-react";
+import { useState } from "react";
+import { Badge } from "@/components/ui/badge";
+import { Button } from "@/components/ui/button";
+import type { GrpcMessage } from "@/types";
+
+interface GrpcMessagesSectionProps {
+  title: string;
+  messages: GrpcMessage[] | null | undefined;
+}
+
+export function GrpcMessagesSection({
+  title,
+  messages,
+}: GrpcMessagesSectionProps) {
+  const [isExpanded, setIsExpanded] = useState(true);
+
+  if (!messages || messages.length === 0) return null;
+
+  return (
+    <div className="min-w-0">
+      <h3 className="text-sm font-semibold mb-3 flex items-center gap-2">
+        {title}{" "}
+        <Badge variant="secondary" className="text-[10px] h-5">
+          {messages.length}
+        </Badge>
+        <Button
+          variant="ghost"
+          size="sm"
+          className="h-auto p-0 hover:bg-transparent"
+          onClick={() => setIsExpanded(!isExpanded)}
+        >
+          {isExpanded ? (
+            <ChevronDown className="h-4 w-4" />
+          ) : (
+            <ChevronRight className="h-4 w-4" />
+          )}
+        </Button>
+      </h3>
+      {isExpanded && (
+        <div className="space-y-2">
+          {messages.map((m, i) => (
+            <div
+              key={i}
+              className="rounded-md border bg-muted/30 p-2 font-mono text-xs"
+            >
+              <div className="text-muted-foreground mb-1">
+                #{i + 1} · {m.size} bytes
+                {m.compressed && " · compressed"}
+                {m.truncated && " · truncated"}
+              </div>
+              {m.data !== undefined && (
+                <pre className="whitespace-pre-wrap break-all">
+                  {JSON.stringify(m.data, null, 2)}
+                </pre>
+              )}
+              {m.error && <div className="text-destructive">{m.error}</div>}
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
@@ -0,0 +1,59 @@
+import { useRef, useState } from "react";
+import { Badge } from "@/components/ui/badge";
+import { useSubscription } from "../../_hooks/use-subscription";
+
+interface LiveBodyMessage {
+  type: "session_chunk" | "session_end";
+  session_id: string;
+  offset?: number;
+  data?: string; // Base64 encoded
+  skipped?: number;
+}
+
+interface LiveBodySectionProps {
+  sessionId: string;
+  onEnd: () => void;
+}
+
+// Shows the response body of an in-flight session as it streams
+export function LiveBodySection({ sessionId, onEnd }: LiveBodySectionProps) {
+  const [text, setText] = useState("");
+  const [received, setReceived] = useState(0);
+  const decoderRef = useRef(new TextDecoder());
+
+  useSubscription(`session:${sessionId}`, (msg: LiveBodyMessage) => {
+    if (msg.session_id !== sessionId) return;
+    if (msg.type === "session_end") {
+      onEnd();
+      return;
+    }
+    const bytes = Uint8Array.from(atob(msg.data ?? ""), (c) =>
+      c.charCodeAt(0),
+    );
+    let chunk = decoderRef.current.decode(bytes, { stream: true });
+    if (msg.skipped) {
+      chunk += `\n… ${msg.skipped} bytes skipped …\n`;
+    }
+    setText((prev) => prev + chunk);
+    setReceived((msg.offset ?? 0) + bytes.length + (msg.skipped ?? 0));
+  });
+
+  return (
+    <div className="min-w-0">
+      <h3 className="text-sm font-semibold mb-3 flex items-center gap-2">
+        Response Body
+        <Badge variant="secondary" className="text-[10px] h-5 animate-pulse">
+          live
+        </Badge>
+        {received > 0 && (
+          <span className="text-xs text-muted-foreground font-normal">
+            {received} bytes
+          </span>
+        )}
+      </h3>
+      <pre className="rounded-md border bg-muted/30 p-2 font-mono text-xs whitespace-pre-wrap break-all">
+        {text || "Waiting for the response body…"}
+      </pre>
+    </div>
+  );
+}
@@ -30,7 +30,10 @@ import type { SessionDetailResponse } from "@/types";
 import { FloatToolbar } from "../../_components/float-toolbar";
 import { resetRequestAtom } from "../../_jotai/http-req";
 import { BodySection } from "./body-section";
+import { GrpcMessagesSection } from "./grpc-messages-section";
 import { HeadersSection } from "./headers-section";
+import { LiveBodySection } from "./live-body-section";
+import { SseEventsSection } from "./sse-events-section";
 import { StatusBadge } from "./status-badge";
 
 const createBookmark = async (sessionId: string) => {
@@ -89,7 +92,7 @@ export function SessionDetails({ id }: SessionDetailsProps) {
     };
   }, [id, cache]);
 
-  const { data, error } = useSWR<SessionDetailResponse>(
+  const { data, error, mutate } = useSWR<SessionDetailResponse>(
     `/api/sessions/${id}`,
     fetcher,
     {
@@ -130,6 +133,10 @@ export function SessionDetails({ id }: SessionDetailsProps) {
   const requestHeaders = data?.request_headers;
   const responseHeaders = data?.response_headers;
   const queryParameters = data?.query_parameters;
+  const requestTrailers = data?.request_trailers;
+  const responseTrailers = data?.response_trailers;
+  const sseEvents = data?.sse_events;
+  const timeToFirstEvent = data?.time_to_first_event_ms;
 
   const handleBookmark = async () => {
     if (!data) return;
@@ -231,6 +238,16 @@ export function SessionDetails({ id }: SessionDetailsProps) {
           </div>
           <div className="text-right">
             <Badge variant="outline">{session.RequestProto}</Badge>
+            {session.UpstreamProto &&
+              session.UpstreamProto !== session.RequestProto && (
+                <Badge
+                  variant="outline"
+                  className="ml-1"
+                  title="Protocol spoken with the target"
+                >
+                  upstream {session.UpstreamProto}
+                </Badge>
+              )}
           </div>
         </div>
       </div>
@@ -355,6 +372,16 @@ export function SessionDetails({ id }: SessionDetailsProps) {
                   {session.ResponseStatusCode}
                 </div>
               </div>
+              {session.GRPCStatus != null && (
+                <div className="grid grid-cols-3 gap-2">
+                  <div className="font-medium">gRPC Status</div>
+                  <div className="col-span-2 font-mono break-all">
+                    {session.GRPCStatus}
+                    {session.GRPCStatusMessage &&
+                      ` ${session.GRPCStatusMessage}`}
+                  </div>
+                </div>
+              )}
               <div className="grid grid-cols-3 gap-2">
                 <div className="font-medium">Time Initiated</div>
                 <div className="col-span-2 font-mono break-all">
@@ -367,6 +394,14 @@ export function SessionDetails({ id }: SessionDetailsProps) {
                   {session.DurationMs} ms
                 </div>
               </div>
+              {timeToFirstEvent != null && (
+                <div className="grid grid-cols-3 gap-2">
+                  <div className="font-medium">First Event</div>
+                  <div className="col-span-2 font-mono break-all">
+                    {timeToFirstEvent} ms
+                  </div>
+                </div>
+              )}
             </TabsContent>
           </Tabs>
         </div>
@@ -404,6 +439,11 @@ export function SessionDetails({ id }: SessionDetailsProps) {
                 type={session.RequestContentType}
                 encoding={session.RequestContentEncoding}
               />
+              <HeadersSection title="Trailers" data={requestTrailers} />
+              <GrpcMessagesSection
+                title="gRPC Messages"
+                messages={session.GRPCRequestMessages}
+              />
             </div>
           </TabsContent>
 
@@ -413,13 +453,27 @@ export function SessionDetails({ id }: SessionDetailsProps) {
           >
             <div className="p-6 space-y-6 flex flex-col h-full">
               <HeadersSection title="Headers" data={responseHeaders} />
-              <BodySection
-                title="Response Body"
-                body={session.ResponseBody}
-                size={session.ResponseBodySize}
-                type={session.ResponseContentType}
-                encoding={session.ResponseContentEncoding}
+              {session.ResponseStatusCode === 0 ? (
+                // In flight: follow the body until the session is stored
+                <LiveBodySection
+                  sessionId={session.ID}
+                  onEnd={() => mutate()}
+                />
+              ) : (
+                <BodySection
+                  title="Response Body"
+                  body={session.ResponseBody}
+                  size={session.ResponseBodySize}
+                  type={session.ResponseContentType}
+                  encoding={session.ResponseContentEncoding}
+                />
+              )}
+              <HeadersSection title="Trailers" data={responseTrailers} />
+              <GrpcMessagesSection
+                title="gRPC Messages"
+                messages={session.GRPCResponseMessages}
               />
+              <SseEventsSection title="Events" events={sseEvents} />
             </div>
           </TabsContent>
         </Tabs>
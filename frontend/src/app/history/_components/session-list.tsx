@@ -290,11 +290,31 @@ function SessionRow({
         </span>
       </TableCell>
       <TableCell className="py-2 border-b">
-        <div
-          className="max-w-[300px] truncate font-mono text-[11px] text-muted-foreground"
-          title={session.RequestPath}
-        >
-          {session.RequestPath}
+        <div className="flex items-center gap-1.5">
+          <div
+            className="max-w-[300px] truncate font-mono text-[11px] text-muted-foreground"
+            title={session.RequestPath}
+          >
+            {session.RequestPath}
+          </div>
+          {session.Mocked && (
+            <Badge
+              variant="outline"
+              className="text-[10px] px-1 py-0 h-4"
+              title="Answered by a mock rule"
+            >
+              mock
+            </Badge>
+          )}
+          {session.ReplayedFrom && (
+            <Badge
+              variant="outline"
+              className="text-[10px] px-1 py-0 h-4"
+              title={`Replayed from session ${session.ReplayedFrom}`}
+            >
+              replay
+            </Badge>
+          )}
         </div>
       </TableCell>
       <TableCell className="py-2 text-right text-[11px] font-mono text-muted-foreground border-b">
@@ -0,0 +1,58 @@
+import { ChevronDown, ChevronRight } from "lucide-react";
+import { useState } from "react";
+import { Badge } from "@/components/ui/badge";
+import { Button } from "@/components/ui/button";
+import type { ProxySessionEvent } from "@/types";
+
+interface SseEventsSectionProps {
+  title: string;
+  events: ProxySessionEvent[] | null | undefined;
+}
+
+export function SseEventsSection({ title, events }: SseEventsSectionProps) {
+  const [isExpanded, setIsExpanded] = useState(true);
+
+  if (!events || events.length === 0) return null;
+
+  return (
+    <div className="min-w-0">
+      <h3 className="text-sm font-semibold mb-3 flex items-center gap-2">
+        {title}{" "}
+        <Badge variant="secondary" className="text-[10px] h-5">
+          {events.length}
+        </Badge>
+        <Button
+          variant="ghost"
+          size="sm"
+          className="h-auto p-0 hover:bg-transparent"
+          onClick={() => setIsExpanded(!isExpanded)}
+        >
+          {isExpanded ? (
+            <ChevronDown className="h-4 w-4" />
+          ) : (
+            <ChevronRight className="h-4 w-4" />
+          )}
+        </Button>
+      </h3>
+      {isExpanded && (
+        <div className="space-y-2">
+          {events.map((e) => (
+            <div
+              key={e.ID}
+              className="rounded-md border bg-muted/30 p-2 font-mono text-xs"
+            >
+              <div className="text-muted-foreground mb-1">
+                #{e.Seq} · +{e.OffsetMs} ms · {e.Event || "message"}
+                {e.EventID && ` · id ${e.EventID}`}
+                {e.Retry != null && ` · retry ${e.Retry} ms`}
+              </div>
+              {e.Data && (
+                <pre className="whitespace-pre-wrap break-all">{e.Data}</pre>
+              )}
+            </div>
+          ))}
+        </div>
+      )}
+    </div>
+  );
+}
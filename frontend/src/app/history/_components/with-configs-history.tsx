@@ -156,7 +156,7 @@ export function WithConfigsHistory({
       type: string;
       ids?: string[];
     }) => {
-      if (type === "new_session" && session) {
+      if ((type === "new_session" || type === "session_failed") && session) {
         if (session.ConfigID !== configId) return;
         if (mergeSessions) {
           setAllLoadedSessions((prev) => mergeSessions(prev, session));
@@ -124,7 +124,7 @@ export function WithConfigsRecent({
       //   configId,
       // );
 
-      if (type === "new_session" && session) {
+      if ((type === "new_session" || type === "session_failed") && session) {
         if (session.ConfigID !== configId) return;
         if (mergeSessions) {
           setAllLoadedSessions((prev) => mergeSessions(prev, session));
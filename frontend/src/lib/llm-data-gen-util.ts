@@ -26,6 +26,8 @@ interface SessionData {
   request_headers: Record<string, string[]>;
   response_headers: Record<string, string[]>;
   query_parameters: Record<string, string[]>;
+  request_trailers?: Record<string, string[]>;
+  response_trailers?: Record<string, string[]>;
 }
 
 /**
@@ -130,13 +132,29 @@ function formatHeaders(headers: Record<string, string[]> | undefined): string {
   return headerStr;
 }
 
+/**
+ * Formats trailers like headers, nothing when there are none
+ */
+function formatTrailers(
+  trailers: Record<string, string[]> | undefined,
+): string {
+  if (!trailers || Object.keys(trailers).length === 0) return "";
+  return `\n- **Trailers:**\n${formatHeaders(trailers)}\n`;
+}
+
 /**
  * Generates LLM-friendly Markdown for a single session
  */
 export function generateLLMMarkdown(data: SessionData): string {
   if (!data || !data.session) return "No session data available";
 
-  const { session, request_headers, response_headers } = data;
+  const {
+    session,
+    request_headers,
+    response_headers,
+    request_trailers,
+    response_trailers,
+  } = data;
   let timeStr = "Unknown";
   try {
     if (session.Timestamp) {
@@ -159,14 +177,14 @@ ${formatHeaders(request_headers)}
 
 - **Body (${session.RequestContentType || "text/plain"}):**
 ${formatBody(session.RequestBody, session.RequestContentType || "text/plain", session.RequestBodySize || 0)}
-
+${formatTrailers(request_trailers)}
 ## Response
 - **Headers:**
 ${formatHeaders(response_headers)}
 
 - **Body (${session.ResponseContentType || "text/plain"}):**
 ${formatBody(session.ResponseBody, session.ResponseContentType || "text/plain", session.ResponseBodySize || 0)}
-
+${formatTrailers(response_trailers)}
 ---
 `;
 }
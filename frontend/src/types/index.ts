@@ -16,10 +16,12 @@ export interface ProxySession {
   RequestPath: string;
   RequestQuery: string;
   RequestProto: string;
+  UpstreamProto?: string; // Empty when the target was not called
   RequestHost: string;
   RequestURLFull: string;
   RequestHeaders: any; // Raw JSON from DB
   QueryParameters: any; // Raw JSON from DB
+  RequestTrailers?: any; // Raw JSON from DB
   RequestBody: string; // Base64 encoded if blob? Or string? Go's []byte marshals to base64 string usually.
   RequestBodySize: number;
   RequestContentType: string;
@@ -27,10 +29,68 @@ export interface ProxySession {
   ResponseStatusCode: number;
   ResponseStatusText: string;
   ResponseHeaders: any; // Raw JSON from DB
+  ResponseTrailers?: any; // Raw JSON from DB
   ResponseBody: string; // Base64 encoded
   ResponseBodySize: number;
   ResponseContentType: string;
   ResponseContentEncoding: string;
+  Mocked?: boolean;
+  ReplayedFrom?: string; // ID of the recorded session replayed instead of the target
+  // Set when rewrite rules ran
+  AppliedRules?: { id: string; name: string; phase: string }[] | null;
+  ModifiedRequestURL?: string;
+  ModifiedRequestHeaders?: any;
+  ModifiedRequestBody?: string;
+  OriginalResponseStatusCode?: number;
+  OriginalResponseHeaders?: any;
+  OriginalResponseBody?: string;
+  // Set when the proxy injected faults
+  AppliedFaults?: { type: string; detail: string }[] | null;
+  // Set when redaction rules masked secrets before storing
+  Redactions?: { location: string; rule: string; target: string }[] | null;
+  // Set for gRPC calls
+  GRPCStatus?: number | null;
+  GRPCStatusMessage?: string;
+  GRPCRequestMessages?: GrpcMessage[] | null;
+  GRPCResponseMessages?: GrpcMessage[] | null;
+}
+
+export interface GrpcMessage {
+  size: number;
+  compressed?: boolean;
+  truncated?: boolean;
+  data?: any; // Decoded with the proxy's protoset
+  error?: string;
+}
+
+// A Server-Sent Event parsed from a streamed response
+export interface ProxySessionEvent {
+  ID: string;
+  SessionID: string;
+  Seq: number;
+  Timestamp: string;
+  OffsetMs: number; // Since the request started
+  EventID: string;
+  Event: string; // Empty for the default "message" type
+  Data: string;
+  Retry?: number | null;
+}
+
+export interface Breakpoint {
+  id: string;
+  config_id: string;
+  session_id?: string;
+  rule_id: string;
+  rule_name: string;
+  phase: "request" | "response";
+  hit_at: string;
+  expires_at: string;
+  method: string;
+  url: string;
+  status_code?: number;
+  headers: Record<string, string[]>;
+  body: string;
+  body_editable: boolean;
 }
 
 export interface ProxyConfigRow {
@@ -59,6 +119,9 @@ export interface ProxySessionStub {
   ResponseStatusCode: number;
   RequestMethod: string;
   RequestPath: string;
+  Route?: string;
+  Mocked?: boolean;
+  ReplayedFrom?: string;
   Timestamp: string;
   DurationMs: number;
 }
@@ -76,6 +139,10 @@ export interface SessionDetailResponse {
   request_headers: Record<string, string[]>;
   response_headers: Record<string, string[]>;
   query_parameters: Record<string, string[]>;
+  request_trailers?: Record<string, string[]>;
+  response_trailers?: Record<string, string[]>;
+  sse_events?: ProxySessionEvent[];
+  time_to_first_event_ms?: number | null;
 }
 
 export interface MethodStats {
@@ -114,6 +181,7 @@ export interface ProxyBookmark {
   ResponseContentType: string;
   ResponseContentEncoding: string;
   ConfigID: string;
+  Redactions?: { location: string; rule: string; target: string }[] | null;
   ConfigSourcePath: string;
   ConfigJSON: string;
 }
@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000008_add_proxy_session_frames.down.sql
+-- Description: Drop proxy_session_frames table
+-- ============================================================
+
+DROP TRIGGER IF EXISTS proxy_sessions_frames_ad;
+DROP INDEX IF EXISTS idx_frames_session_seq;
+DROP TABLE IF EXISTS proxy_session_frames;
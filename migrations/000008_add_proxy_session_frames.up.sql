@@ -0,0 +1,30 @@
+-- ============================================================
+-- File: migrations/000008_add_proxy_session_frames.up.sql
+-- Description: Add proxy_session_frames table for WebSocket frame capture
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS proxy_session_frames (
+    id TEXT PRIMARY KEY NOT NULL,
+    session_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+
+    -- 'client_to_server' or 'server_to_client'
+    direction TEXT NOT NULL,
+
+    -- RFC 6455 opcode: 0 continuation, 1 text, 2 binary, 8 close, 9 ping, 10 pong
+    opcode INTEGER NOT NULL,
+    fin INTEGER NOT NULL DEFAULT 1,
+
+    -- Unmasked payload (possibly truncated, see payload_truncated)
+    payload BLOB,
+    payload_size INTEGER DEFAULT 0,
+    payload_truncated INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_frames_session_seq ON proxy_session_frames(session_id, seq);
+
+-- Frames belong to their session, drop them together
+CREATE TRIGGER IF NOT EXISTS proxy_sessions_frames_ad AFTER DELETE ON proxy_sessions BEGIN
+    DELETE FROM proxy_session_frames WHERE session_id = old.id;
+END;
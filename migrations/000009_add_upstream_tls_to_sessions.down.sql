@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000009_add_upstream_tls_to_sessions.down.sql
+-- Description: Drop the upstream TLS columns from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN upstream_tls_peer_certs;
+ALTER TABLE proxy_sessions DROP COLUMN upstream_tls_cipher;
+ALTER TABLE proxy_sessions DROP COLUMN upstream_tls_version;
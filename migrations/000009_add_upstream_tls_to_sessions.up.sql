@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000009_add_upstream_tls_to_sessions.up.sql
+-- Description: Record the negotiated upstream TLS version, cipher and peer chain per session
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN upstream_tls_version TEXT;
+ALTER TABLE proxy_sessions ADD COLUMN upstream_tls_cipher TEXT;
+ALTER TABLE proxy_sessions ADD COLUMN upstream_tls_peer_certs TEXT;
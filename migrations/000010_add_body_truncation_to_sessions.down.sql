@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000010_add_body_truncation_to_sessions.down.sql
+-- Description: Drop the body truncation flags from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN response_body_truncated;
+ALTER TABLE proxy_sessions DROP COLUMN request_body_truncated;
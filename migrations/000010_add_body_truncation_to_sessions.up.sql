@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000010_add_body_truncation_to_sessions.up.sql
+-- Description: Flag sessions whose stored bodies were cut at the capture limit
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN request_body_truncated INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN response_body_truncated INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000011_add_error_details_to_sessions.down.sql
+-- Description: Drop the upstream failure details from proxy_sessions
+-- ============================================================
+
+DROP INDEX IF EXISTS idx_sessions_config_error_kind;
+ALTER TABLE proxy_sessions DROP COLUMN error_message;
+ALTER TABLE proxy_sessions DROP COLUMN error_kind;
@@ -0,0 +1,10 @@
+-- ============================================================
+-- File: migrations/000011_add_error_details_to_sessions.up.sql
+-- Description: Record why the target could not be reached for failed sessions
+-- ============================================================
+
+-- 'dns', 'connection_refused', 'timeout', 'tls', 'client_cancelled' or 'other'
+ALTER TABLE proxy_sessions ADD COLUMN error_kind TEXT NOT NULL DEFAULT '';
+ALTER TABLE proxy_sessions ADD COLUMN error_message TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_sessions_config_error_kind ON proxy_sessions(config_id, error_kind);
@@ -0,0 +1,10 @@
+-- ============================================================
+-- File: migrations/000012_add_timing_to_sessions.down.sql
+-- Description: Drop the per-phase upstream timing from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN timing_transfer_ms;
+ALTER TABLE proxy_sessions DROP COLUMN timing_ttfb_ms;
+ALTER TABLE proxy_sessions DROP COLUMN timing_tls_ms;
+ALTER TABLE proxy_sessions DROP COLUMN timing_connect_ms;
+ALTER TABLE proxy_sessions DROP COLUMN timing_dns_ms;
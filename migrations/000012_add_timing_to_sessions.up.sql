@@ -0,0 +1,10 @@
+-- ============================================================
+-- File: migrations/000012_add_timing_to_sessions.up.sql
+-- Description: Add per-phase upstream timing (milliseconds) to proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN timing_dns_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN timing_connect_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN timing_tls_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN timing_ttfb_ms INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN timing_transfer_ms INTEGER NOT NULL DEFAULT 0;
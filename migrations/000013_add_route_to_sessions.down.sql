@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000013_add_route_to_sessions.down.sql
+-- Description: Drop the route column from proxy_sessions
+-- ============================================================
+
+DROP INDEX IF EXISTS idx_sessions_config_route;
+ALTER TABLE proxy_sessions DROP COLUMN route;
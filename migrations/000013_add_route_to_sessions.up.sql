@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000013_add_route_to_sessions.up.sql
+-- Description: Record which route of a proxy handled each session
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN route TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_config_route ON proxy_sessions(config_id, route);
@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000014_add_upstream_to_sessions.down.sql
+-- Description: Drop the upstream details from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN attempts;
+ALTER TABLE proxy_sessions DROP COLUMN upstream;
@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000014_add_upstream_to_sessions.up.sql
+-- Description: Record which upstream served each session and how many were tried
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN upstream TEXT NOT NULL DEFAULT '';
+ALTER TABLE proxy_sessions ADD COLUMN attempts INTEGER NOT NULL DEFAULT 1;
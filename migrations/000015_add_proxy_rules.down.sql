@@ -0,0 +1,15 @@
+-- ============================================================
+-- File: migrations/000015_add_proxy_rules.down.sql
+-- Description: Drop proxy_rules and the unmodified exchange columns
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN original_response_body;
+ALTER TABLE proxy_sessions DROP COLUMN original_response_headers;
+ALTER TABLE proxy_sessions DROP COLUMN original_response_status_code;
+ALTER TABLE proxy_sessions DROP COLUMN modified_request_body;
+ALTER TABLE proxy_sessions DROP COLUMN modified_request_headers;
+ALTER TABLE proxy_sessions DROP COLUMN modified_request_url;
+ALTER TABLE proxy_sessions DROP COLUMN applied_rules;
+
+DROP INDEX IF EXISTS idx_rules_config_priority;
+DROP TABLE IF EXISTS proxy_rules;
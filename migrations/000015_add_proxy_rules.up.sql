@@ -0,0 +1,37 @@
+-- ============================================================
+-- File: migrations/000015_add_proxy_rules.up.sql
+-- Description: Add proxy_rules table and keep the unmodified exchange on sessions
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS proxy_rules (
+    id TEXT PRIMARY KEY NOT NULL,
+    config_id TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+    name TEXT NOT NULL DEFAULT '',
+    enabled INTEGER NOT NULL DEFAULT 1,
+    priority INTEGER NOT NULL DEFAULT 0,
+
+    -- 'request' or 'response'
+    phase TEXT NOT NULL,
+
+    -- RuleMatch and []RuleAction as JSON
+    matcher TEXT,
+    actions TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_rules_config_priority ON proxy_rules(config_id, priority);
+
+-- Rules that changed the session, as JSON
+ALTER TABLE proxy_sessions ADD COLUMN applied_rules TEXT;
+
+-- Request as sent to the target, set when request rules changed it
+ALTER TABLE proxy_sessions ADD COLUMN modified_request_url TEXT NOT NULL DEFAULT '';
+ALTER TABLE proxy_sessions ADD COLUMN modified_request_headers TEXT;
+ALTER TABLE proxy_sessions ADD COLUMN modified_request_body BLOB;
+
+-- Response as returned by the target, set when response rules changed it
+ALTER TABLE proxy_sessions ADD COLUMN original_response_status_code INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE proxy_sessions ADD COLUMN original_response_headers TEXT;
+ALTER TABLE proxy_sessions ADD COLUMN original_response_body BLOB;
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000016_add_mocked_to_sessions.down.sql
+-- Description: Drop the mocked flag from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN mocked;
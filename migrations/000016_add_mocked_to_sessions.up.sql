@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000016_add_mocked_to_sessions.up.sql
+-- Description: Flag sessions answered by a mock rule
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN mocked INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000017_add_replay_to_sessions.down.sql
+-- Description: Drop the replay columns from proxy_sessions
+-- ============================================================
+
+DROP INDEX IF EXISTS idx_sessions_replay;
+ALTER TABLE proxy_sessions DROP COLUMN replayed_from;
+ALTER TABLE proxy_sessions DROP COLUMN request_body_hash;
@@ -0,0 +1,9 @@
+-- ============================================================
+-- File: migrations/000017_add_replay_to_sessions.up.sql
+-- Description: Match recorded sessions for replay and flag replayed ones
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN request_body_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE proxy_sessions ADD COLUMN replayed_from TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_sessions_replay ON proxy_sessions(config_id, request_method, request_path);
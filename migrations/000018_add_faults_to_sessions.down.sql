@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000018_add_faults_to_sessions.down.sql
+-- Description: Drop the injected faults from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN applied_faults;
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000018_add_faults_to_sessions.up.sql
+-- Description: Record the faults injected into each session
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN applied_faults TEXT;
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000019_add_proxy_session_counts.down.sql
+-- Description: Drop the proxy_session_counts table
+-- ============================================================
+
+DROP TABLE IF EXISTS proxy_session_counts;
@@ -0,0 +1,15 @@
+-- ============================================================
+-- File: migrations/000019_add_proxy_session_counts.up.sql
+-- Description: Count exchanges that capture filters keep out of proxy_sessions
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS proxy_session_counts (
+    config_id TEXT NOT NULL,
+    request_method TEXT NOT NULL,
+    request_path TEXT NOT NULL,
+    response_status_code INTEGER NOT NULL,
+    count INTEGER NOT NULL DEFAULT 0,
+    total_duration_ms INTEGER NOT NULL DEFAULT 0,
+    last_seen DATETIME NOT NULL,
+    PRIMARY KEY (config_id, request_method, request_path, response_status_code)
+);
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000020_add_redactions_to_sessions.down.sql
+-- Description: Drop the redaction markers from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN redactions;
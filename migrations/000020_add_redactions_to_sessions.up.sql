@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000020_add_redactions_to_sessions.up.sql
+-- Description: Record what redaction rules masked in each session
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN redactions TEXT;
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000021_add_request_body_overflow_to_sessions.down.sql
+-- Description: Drop the request body overflow outcome from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN request_body_overflow;
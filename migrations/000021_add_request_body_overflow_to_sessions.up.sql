@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000021_add_request_body_overflow_to_sessions.up.sql
+-- Description: Record how request bodies over the size limit were handled
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN request_body_overflow TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000022_add_upstream_proto_to_sessions.down.sql
+-- Description: Drop the upstream protocol from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN upstream_proto;
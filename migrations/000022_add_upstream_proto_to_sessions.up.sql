@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000022_add_upstream_proto_to_sessions.up.sql
+-- Description: Record the protocol each target answered with
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN upstream_proto TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000024_add_trailers_to_sessions.down.sql
+-- Description: Drop the trailers from proxy_sessions
+-- ============================================================
+
+ALTER TABLE proxy_sessions DROP COLUMN request_trailers;
+ALTER TABLE proxy_sessions DROP COLUMN response_trailers;
@@ -0,0 +1,7 @@
+-- ============================================================
+-- File: migrations/000024_add_trailers_to_sessions.up.sql
+-- Description: Record the trailers sent after request and response bodies
+-- ============================================================
+
+ALTER TABLE proxy_sessions ADD COLUMN request_trailers TEXT;
+ALTER TABLE proxy_sessions ADD COLUMN response_trailers TEXT;
@@ -0,0 +1,8 @@
+-- ============================================================
+-- File: migrations/000025_add_proxy_session_events.down.sql
+-- Description: Drop proxy_session_events table
+-- ============================================================
+
+DROP TRIGGER IF EXISTS proxy_sessions_events_ad;
+DROP INDEX IF EXISTS idx_events_session_seq;
+DROP TABLE IF EXISTS proxy_session_events;
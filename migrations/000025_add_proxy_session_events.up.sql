@@ -0,0 +1,27 @@
+-- ============================================================
+-- File: migrations/000025_add_proxy_session_events.up.sql
+-- Description: Add proxy_session_events table for Server-Sent Events capture
+-- ============================================================
+
+CREATE TABLE IF NOT EXISTS proxy_session_events (
+    id TEXT PRIMARY KEY NOT NULL,
+    session_id TEXT NOT NULL,
+    seq INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL,
+
+    -- Milliseconds between the request and the blank line ending the event
+    offset_ms INTEGER NOT NULL DEFAULT 0,
+
+    -- Fields of the event, data lines joined with a newline
+    event_id TEXT NOT NULL DEFAULT '',
+    event TEXT NOT NULL DEFAULT '',
+    data TEXT,
+    retry INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS idx_events_session_seq ON proxy_session_events(session_id, seq);
+
+-- Events belong to their session, drop them together
+CREATE TRIGGER IF NOT EXISTS proxy_sessions_events_ad AFTER DELETE ON proxy_sessions BEGIN
+    DELETE FROM proxy_session_events WHERE session_id = old.id;
+END;
@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000026_add_redactions_to_bookmarks.down.sql
+-- Description: Drop the redactions from proxy_bookmarks
+-- ============================================================
+
+ALTER TABLE proxy_bookmarks DROP COLUMN redactions;
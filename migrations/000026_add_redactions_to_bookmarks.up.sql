@@ -0,0 +1,6 @@
+-- ============================================================
+-- File: migrations/000026_add_redactions_to_bookmarks.up.sql
+-- Description: Copy what redaction and omit-headers changed in a session to its bookmarks
+-- ============================================================
+
+ALTER TABLE proxy_bookmarks ADD COLUMN redactions TEXT;
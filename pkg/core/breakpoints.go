@@ -0,0 +1,324 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// Ways a breakpoint is left
+const (
+	BreakpointResumed   = "resumed"
+	BreakpointAborted   = "aborted"
+	BreakpointTimedOut  = "timeout"   // Continued unchanged once the rule's timeout passed
+	BreakpointCancelled = "cancelled" // The client went away while waiting
+)
+
+// ErrBreakpointNotFound is returned for breakpoints that are not (or no longer) pending
+var ErrBreakpointNotFound = errors.New("breakpoint not found")
+
+// errBreakpointAborted fails an exchange that was aborted at a breakpoint
+var errBreakpointAborted = errors.New("aborted at breakpoint")
+
+// Breakpoint is an exchange paused by a breakpoint rule, waiting to be resumed or aborted
+type Breakpoint struct {
+	ID        string    `json:"id"`
+	ConfigID  string    `json:"config_id"`
+	SessionID string    `json:"session_id,omitempty"`
+	RuleID    string    `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	Phase     string    `json:"phase"`
+	HitAt     time.Time `json:"hit_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+
+	// The message as it will be sent on, after all rules of the phase ran. It is shown
+	// like sessions are stored: omitted headers are left out and secrets masked.
+	Method       string      `json:"method"`
+	URL          string      `json:"url"`
+	StatusCode   int         `json:"status_code,omitempty"` // Response phase only
+	Headers      http.Header `json:"headers"`
+	Body         string      `json:"body"`
+	BodyEditable bool        `json:"body_editable"` // False for streamed or encoded bodies
+
+	resolved   chan breakpointResolution
+	requestURI string // Path and query as shown, to tell an edited URL from the masked one
+}
+
+// BreakpointEdit replaces parts of a paused message. Fields left nil are kept.
+type BreakpointEdit struct {
+	URL        *string     `json:"url"`         // Request phase only, path and query
+	StatusCode *int        `json:"status_code"` // Response phase only
+	Headers    http.Header `json:"headers"`
+	Body       *string     `json:"body"`
+}
+
+type breakpointResolution struct {
+	outcome string
+	edit    *BreakpointEdit
+}
+
+// BreakpointStore keeps the exchanges paused at a breakpoint
+type BreakpointStore struct {
+	mu      sync.Mutex
+	pending map[string]*Breakpoint
+}
+
+// Breakpoints holds the pending breakpoints of all proxies
+var Breakpoints = NewBreakpointStore()
+
+// NewBreakpointStore creates an empty BreakpointStore
+func NewBreakpointStore() *BreakpointStore {
+	return &BreakpointStore{pending: make(map[string]*Breakpoint)}
+}
+
+// List returns the pending breakpoints, oldest first, optionally only those of configID
+func (s *BreakpointStore) List(configID string) []*Breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]*Breakpoint, 0, len(s.pending))
+	for _, bp := range s.pending {
+		if configID == "" || bp.ConfigID == configID {
+			list = append(list, bp)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].HitAt.Before(list[j].HitAt) })
+	return list
+}
+
+// Get returns a pending breakpoint
+func (s *BreakpointStore) Get(id string) (*Breakpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bp, ok := s.pending[id]
+	if !ok {
+		return nil, ErrBreakpointNotFound
+	}
+	return bp, nil
+}
+
+// Resume lets a paused exchange continue, with edit applied when it is not nil
+func (s *BreakpointStore) Resume(id string, edit *BreakpointEdit) error {
+	bp, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if edit != nil {
+		if err := edit.validate(bp.Phase); err != nil {
+			return err
+		}
+	}
+	return s.resolve(id, breakpointResolution{outcome: BreakpointResumed, edit: edit})
+}
+
+// Abort fails a paused exchange, the client gets a 502
+func (s *BreakpointStore) Abort(id string) error {
+	return s.resolve(id, breakpointResolution{outcome: BreakpointAborted})
+}
+
+// resolve hands res to the exchange waiting at breakpoint id
+func (s *BreakpointStore) resolve(id string, res breakpointResolution) error {
+	s.mu.Lock()
+	bp, ok := s.pending[id]
+	delete(s.pending, id)
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrBreakpointNotFound
+	}
+	bp.resolved <- res
+	return nil
+}
+
+// hold registers bp and blocks until it is resolved, its timeout passes or ctx is done
+func (s *BreakpointStore) hold(ctx context.Context, bp *Breakpoint, timeout time.Duration, publish func(topic string, v any)) breakpointResolution {
+	bp.resolved = make(chan breakpointResolution, 1)
+	s.mu.Lock()
+	s.pending[bp.ID] = bp
+	s.mu.Unlock()
+
+	publish("breakpoints", map[string]any{
+		"type":       "breakpoint_hit",
+		"breakpoint": bp,
+	})
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var res breakpointResolution
+	select {
+	case res = <-bp.resolved:
+	case <-timer.C:
+		res = s.expire(bp, BreakpointTimedOut)
+	case <-ctx.Done():
+		res = s.expire(bp, BreakpointCancelled)
+	}
+
+	publish("breakpoints", map[string]any{
+		"type":    "breakpoint_resolved",
+		"id":      bp.ID,
+		"outcome": res.outcome,
+	})
+	return res
+}
+
+// expire removes bp with outcome, unless it was resolved at the same moment
+func (s *BreakpointStore) expire(bp *Breakpoint, outcome string) breakpointResolution {
+	if err := s.resolve(bp.ID, breakpointResolution{outcome: outcome}); err != nil {
+		log.Debug().Str("breakpoint_id", bp.ID).Msg("Breakpoint resolved while expiring")
+	}
+	return <-bp.resolved
+}
+
+func (e *BreakpointEdit) validate(phase string) error {
+	if e.URL != nil {
+		if phase != RulePhaseRequest {
+			return fmt.Errorf("the url can only be edited in the request phase")
+		}
+		if _, err := url.ParseRequestURI(*e.URL); err != nil {
+			return fmt.Errorf("invalid url: %w", err)
+		}
+	}
+	if e.StatusCode != nil {
+		if phase != RulePhaseResponse {
+			return fmt.Errorf("the status code can only be edited in the response phase")
+		}
+		if *e.StatusCode < 100 || *e.StatusCode > 999 {
+			return fmt.Errorf("invalid status %d", *e.StatusCode)
+		}
+	}
+	return nil
+}
+
+// applyTo makes the edit on msg, the edit must have been validated for msg's phase
+func (e *BreakpointEdit) applyTo(msg *ruleMessage) {
+	if e.URL != nil {
+		parsed, _ := url.ParseRequestURI(*e.URL)
+		u := *msg.URL
+		u.Path, u.RawPath, u.RawQuery = parsed.Path, parsed.RawPath, parsed.RawQuery
+		msg.URL = &u
+	}
+	if e.StatusCode != nil {
+		msg.StatusCode = *e.StatusCode
+	}
+	if e.Headers != nil {
+		msg.Header = e.Headers.Clone()
+	}
+	if e.Body != nil && msg.HasBody {
+		msg.Body = []byte(*e.Body)
+		if msg.Header.Get("Content-Length") != "" {
+			msg.Header.Set("Content-Length", strconv.Itoa(len(msg.Body)))
+		}
+	}
+}
+
+// unmask keeps the real values of what the breakpoint showed masked or left out, unless
+// the edit changed them
+func (bp *Breakpoint) unmask(e *BreakpointEdit, msg *ruleMessage) {
+	if e.URL != nil && *e.URL == bp.requestURI {
+		e.URL = nil
+	}
+	if e.Body != nil && *e.Body == bp.Body {
+		e.Body = nil
+	}
+	if e.Headers == nil {
+		return
+	}
+	headers := http.Header{}
+	for name, values := range e.Headers {
+		headers[http.CanonicalHeaderKey(name)] = values
+	}
+	for name, values := range msg.Header {
+		shown, ok := bp.Headers[name]
+		if !ok {
+			// Omitted headers cannot be edited, they are sent as they were
+			headers[name] = values
+		} else if edited, ok := headers[name]; ok && slices.Equal(edited, shown) {
+			headers[name] = values
+		}
+	}
+	e.Headers = headers
+}
+
+// pauseAtBreakpoint holds the exchange when the rules of a phase hit a breakpoint.
+// It reports false when the exchange must not go on, after answering the client.
+func pauseAtBreakpoint(
+	config *ProxyConfig,
+	w http.ResponseWriter,
+	r *http.Request,
+	entry *LogEntry,
+	session *ProxySessionRow,
+	phase string,
+	msg *ruleMessage,
+) bool {
+	if msg.breakpoint == nil {
+		return true
+	}
+
+	id, err := gonanoid.New(12)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to create breakpoint ID, continuing")
+		return true
+	}
+	headersLocation, bodyLocation := RedactRequestHeaders, RedactRequestBody
+	if phase == RulePhaseResponse {
+		headersLocation, bodyLocation = RedactResponseHeaders, RedactResponseBody
+	}
+	// Published to every UI, so secrets are masked like in stored sessions. What is
+	// masked here is masked again when the session is stored, the marks are not kept.
+	var marks []Redaction
+	red := entry.redactor
+
+	now := time.Now()
+	bp := &Breakpoint{
+		ID:           id,
+		ConfigID:     config.ConfigID,
+		RuleID:       msg.breakpoint.rule.ID,
+		RuleName:     msg.breakpoint.rule.Name,
+		Phase:        phase,
+		HitAt:        now,
+		ExpiresAt:    now.Add(msg.breakpoint.timeout),
+		Method:       entry.RequestMethod,
+		URL:          red.redactURL(entry.RequestURL, &marks).String(),
+		StatusCode:   msg.StatusCode,
+		Headers:      red.redactHeaders(entry.headersToOmit.filter(msg.Header), headersLocation, &marks).Clone(),
+		Body:         string(red.redactBody(msg.Body, msg.Header, bodyLocation, &marks)),
+		BodyEditable: msg.HasBody,
+	}
+	if msg.URL != nil {
+		shown := red.redactURL(msg.URL, &marks)
+		bp.URL, bp.requestURI = shown.String(), shown.RequestURI()
+	}
+	if session != nil {
+		bp.SessionID = session.ID
+	}
+
+	log.Info().Str("breakpoint_id", bp.ID).Str("phase", phase).Str("url", bp.URL).Msg("Exchange paused at breakpoint")
+	res := Breakpoints.hold(r.Context(), bp, msg.breakpoint.timeout, config.WsPublishFn)
+
+	switch res.outcome {
+	case BreakpointAborted:
+		failProxySession(config, w, r, entry, session, errBreakpointAborted)
+		return false
+	case BreakpointCancelled:
+		failProxySession(config, w, r, entry, session, r.Context().Err())
+		return false
+	}
+	if res.edit != nil {
+		edit := *res.edit
+		bp.unmask(&edit, msg)
+		edit.applyTo(msg)
+	}
+	return true
+}
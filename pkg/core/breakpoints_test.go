@@ -0,0 +1,246 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBreakpointProxy returns a proxy to target with a single breakpoint rule
+func newBreakpointProxy(t *testing.T, configID string, target string, phase string, timeout string) *ProxyConfig {
+	t.Helper()
+	rules, err := compileRules([]ProxyRuleRow{{
+		ID: "bp", Name: "pause", Enabled: true, Phase: phase,
+		Actions: []RuleAction{{Type: RuleActionBreakpoint, Timeout: timeout}},
+	}})
+	if err != nil {
+		t.Fatalf("compileRules failed: %v", err)
+	}
+	targetURL, _ := url.Parse(target)
+	config := &ProxyConfig{
+		ConfigID:    configID,
+		TargetURL:   targetURL,
+		DB:          setupTestDB(t),
+		WsPublishFn: func(topic string, v any) {},
+	}
+	config.SetRules(rules)
+	return config
+}
+
+// waitForBreakpoint polls until configID has a pending breakpoint
+func waitForBreakpoint(t *testing.T, configID string) *Breakpoint {
+	t.Helper()
+	for i := 0; i < 40; i++ {
+		if list := Breakpoints.List(configID); len(list) > 0 {
+			return list[0]
+		}
+		time.Sleep(25 * time.Millisecond)
+	}
+	t.Fatalf("No breakpoint hit for %s", configID)
+	return nil
+}
+
+func waitForSession(t *testing.T, config *ProxyConfig) ProxySessionRow {
+	t.Helper()
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := config.DB.Where("config_id = ?", config.ConfigID).First(&session).Error; err == nil && session.ResponseStatusCode != 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return session
+}
+
+func TestBreakpoint_ResumeWithEditedRequest(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + " " + r.Header.Get("X-Debug") + " " + string(body)))
+	}))
+	defer mockTarget.Close()
+
+	config := newBreakpointProxy(t, "test-bp-request", mockTarget.URL, RulePhaseRequest, "")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader("qty=1"))
+		w := httptest.NewRecorder()
+		NewProxyHandler(config)(w, req)
+		done <- w
+	}()
+
+	bp := waitForBreakpoint(t, config.ConfigID)
+	if bp.Phase != RulePhaseRequest || bp.URL != "/orders" || bp.Body != "qty=1" || !bp.BodyEditable {
+		t.Errorf("Unexpected breakpoint: %+v", bp)
+	}
+
+	newURL, newBody := "/orders/v2", "qty=9"
+	err := Breakpoints.Resume(bp.ID, &BreakpointEdit{
+		URL:     &newURL,
+		Headers: http.Header{"X-Debug": {"on"}},
+		Body:    &newBody,
+	})
+	if err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+
+	w := <-done
+	if w.Body.String() != "/orders/v2 on qty=9" {
+		t.Errorf("Expected the edited request to reach the target, got %q", w.Body.String())
+	}
+	if err := Breakpoints.Resume(bp.ID, nil); err != ErrBreakpointNotFound {
+		t.Errorf("Expected resolved breakpoint to be gone, got %v", err)
+	}
+
+	session := waitForSession(t, config)
+	if string(session.RequestBody) != "qty=1" || string(session.ModifiedRequestBody) != "qty=9" || session.ModifiedRequestURL != "/orders/v2" {
+		t.Errorf("Expected both requests on the session, got %s / %s %s", session.RequestBody, session.ModifiedRequestURL, session.ModifiedRequestBody)
+	}
+}
+
+func TestBreakpoint_AbortResponse(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer mockTarget.Close()
+
+	config := newBreakpointProxy(t, "test-bp-abort", mockTarget.URL, RulePhaseResponse, "")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		NewProxyHandler(config)(w, httptest.NewRequest("GET", "/", nil))
+		done <- w
+	}()
+
+	bp := waitForBreakpoint(t, config.ConfigID)
+	if bp.StatusCode != http.StatusOK || bp.Body != "secret" {
+		t.Errorf("Unexpected breakpoint: %+v", bp)
+	}
+	badURL := "/elsewhere"
+	if err := Breakpoints.Resume(bp.ID, &BreakpointEdit{URL: &badURL}); err == nil {
+		t.Error("Expected URL edits to be rejected in the response phase")
+	}
+	if err := Breakpoints.Abort(bp.ID); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+
+	w := <-done
+	if w.Code != http.StatusBadGateway || strings.Contains(w.Body.String(), "secret") {
+		t.Errorf("Expected 502 without the response, got %d %q", w.Code, w.Body.String())
+	}
+
+	session := waitForSession(t, config)
+	if session.ErrorKind != UpstreamErrorAborted || session.OriginalResponseStatusCode != http.StatusOK {
+		t.Errorf("Expected aborted session with the target's response, got %q %d", session.ErrorKind, session.OriginalResponseStatusCode)
+	}
+}
+
+func TestBreakpoint_ResponseHoldNotInTransfer(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer mockTarget.Close()
+
+	config := newBreakpointProxy(t, "test-bp-transfer", mockTarget.URL, RulePhaseResponse, "")
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		NewProxyHandler(config)(w, httptest.NewRequest("GET", "/", nil))
+		done <- w
+	}()
+
+	bp := waitForBreakpoint(t, config.ConfigID)
+	time.Sleep(300 * time.Millisecond)
+	if err := Breakpoints.Resume(bp.ID, nil); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", w.Code)
+	}
+
+	session := waitForSession(t, config)
+	if session.TimingTransferMs >= 300 || session.DurationMs < 300 {
+		t.Errorf("Expected the hold in the duration only, got transfer %dms of %dms", session.TimingTransferMs, session.DurationMs)
+	}
+}
+
+func TestBreakpoint_Timeout(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer mockTarget.Close()
+
+	config := newBreakpointProxy(t, "test-bp-timeout", mockTarget.URL, RulePhaseRequest, "50ms")
+
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusOK || w.Body.String() != "ok" {
+		t.Errorf("Expected forgotten breakpoint to continue unchanged, got %d %q", w.Code, w.Body.String())
+	}
+	if list := Breakpoints.List(config.ConfigID); len(list) != 0 {
+		t.Errorf("Expected no pending breakpoints, got %d", len(list))
+	}
+}
+
+func TestBreakpoint_RedactsPublishedMessage(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Header.Get("Authorization") + " " + r.Header.Get("X-Internal") + " " + r.Header.Get("X-Debug") + " " + string(body)))
+	}))
+	defer mockTarget.Close()
+
+	config := newBreakpointProxy(t, "test-bp-redact", mockTarget.URL, RulePhaseRequest, "")
+	red, err := newRedactor(&SysConfigRedaction{Headers: []string{"Authorization"}, Patterns: []string{`tok_[a-z0-9]+`}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	config.Redact = red
+	config.SetOmitHeaders([]string{"X-Internal"})
+
+	published := make(chan *Breakpoint, 1)
+	config.WsPublishFn = func(topic string, v any) {
+		if m, ok := v.(map[string]any); ok && m["type"] == "breakpoint_hit" {
+			published <- m["breakpoint"].(*Breakpoint)
+		}
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req := httptest.NewRequest("POST", "/orders", strings.NewReader("key=tok_abc123"))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Internal", "node-7")
+		w := httptest.NewRecorder()
+		NewProxyHandler(config)(w, req)
+		done <- w
+	}()
+
+	bp := waitForBreakpoint(t, config.ConfigID)
+	if bp.Headers.Get("Authorization") != DefaultRedactionMask || bp.Headers.Get("X-Internal") != "" {
+		t.Errorf("Expected masked and omitted headers, got %v", bp.Headers)
+	}
+	if strings.Contains(bp.Body, "tok_abc123") {
+		t.Errorf("Expected the token to be masked in the body, got %q", bp.Body)
+	}
+	if hit := <-published; hit.Headers.Get("Authorization") != DefaultRedactionMask {
+		t.Errorf("Expected the published breakpoint to be masked, got %v", hit.Headers)
+	}
+
+	// Sending back what was shown, plus a header, keeps the real values
+	headers := bp.Headers.Clone()
+	headers.Set("X-Debug", "on")
+	body := bp.Body
+	if err := Breakpoints.Resume(bp.ID, &BreakpointEdit{Headers: headers, Body: &body}); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	w := <-done
+	if w.Body.String() != "Bearer secret node-7 on key=tok_abc123" {
+		t.Errorf("Expected the real values to reach the target, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,255 @@
+package core
+
+import (
+	"container/list"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	caCertFileName = "ca.pem"
+	caKeyFileName  = "ca-key.pem"
+)
+
+// CertAuthority is the local certificate authority used to mint leaf certificates
+// for intercepted HTTPS hosts
+type CertAuthority struct {
+	Cert    *x509.Certificate
+	CertPEM []byte
+	key     *ecdsa.PrivateKey
+
+	mu     sync.Mutex
+	leaves map[string]*list.Element // Of cachedLeaf, in lru
+	lru    *list.List               // Most recently used first, at most MaxCachedLeafCerts
+}
+
+type cachedLeaf struct {
+	host string
+	cert *tls.Certificate
+}
+
+var (
+	defaultCA     *CertAuthority
+	defaultCAErr  error
+	defaultCAOnce sync.Once
+)
+
+// DefaultCADir returns the directory where the ihpp CA is stored
+func DefaultCADir() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return ".ihpp" // Fallback to current directory
+	}
+	return filepath.Join(homeDir, ".ihpp")
+}
+
+// DefaultCA returns the CA stored under DefaultCADir, creating it on first use
+func DefaultCA() (*CertAuthority, error) {
+	defaultCAOnce.Do(func() {
+		defaultCA, defaultCAErr = LoadOrCreateCA(DefaultCADir())
+	})
+	return defaultCA, defaultCAErr
+}
+
+// LoadOrCreateCA loads the CA certificate and key from dir, generating a new pair if none exists
+func LoadOrCreateCA(dir string) (*CertAuthority, error) {
+	certPath := filepath.Join(dir, caCertFileName)
+	keyPath := filepath.Join(dir, caKeyFileName)
+
+	certPEM, certErr := os.ReadFile(certPath)
+	keyPEM, keyErr := os.ReadFile(keyPath)
+	if certErr == nil && keyErr == nil {
+		return parseCA(certPEM, keyPEM)
+	}
+	if !os.IsNotExist(certErr) && certErr != nil {
+		return nil, fmt.Errorf("failed to read CA certificate: %w", certErr)
+	}
+	if !os.IsNotExist(keyErr) && keyErr != nil {
+		return nil, fmt.Errorf("failed to read CA key: %w", keyErr)
+	}
+	// A new pair would replace a CA that clients may already trust
+	if certErr == nil {
+		return nil, fmt.Errorf("CA certificate %s exists but its key %s is missing, restore the key or remove both to create a new CA", certPath, keyPath)
+	}
+	if keyErr == nil {
+		return nil, fmt.Errorf("CA key %s exists but its certificate %s is missing, restore the certificate or remove both to create a new CA", keyPath, certPath)
+	}
+
+	log.Info().Str("dir", dir).Msg("Generating new local certificate authority")
+
+	certPEM, keyPEM, err := generateCA()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create CA directory %s: %w", dir, err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write CA key: %w", err)
+	}
+	if err := os.WriteFile(certPath, certPEM, 0644); err != nil {
+		return nil, fmt.Errorf("failed to write CA certificate: %w", err)
+	}
+
+	return parseCA(certPEM, keyPEM)
+}
+
+// generateCA creates a self-signed CA certificate and returns it with its key in PEM form
+func generateCA() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate CA key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Inspect HTTP Proxy Plus CA",
+			Organization: []string{"ihpp"},
+		},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create CA certificate: %w", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal CA key: %w", err)
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func parseCA(certPEM, keyPEM []byte) (*CertAuthority, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("invalid CA certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %w", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("invalid CA key PEM")
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA key: %w", err)
+	}
+
+	return &CertAuthority{
+		Cert:    cert,
+		CertPEM: certPEM,
+		key:     key,
+		leaves:  make(map[string]*list.Element),
+		lru:     list.New(),
+	}, nil
+}
+
+// LeafFor returns a certificate for host signed by the CA, minting and caching it on first use
+func (ca *CertAuthority) LeafFor(host string) (*tls.Certificate, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if elem, ok := ca.leaves[host]; ok {
+		if leaf := elem.Value.(*cachedLeaf).cert; time.Now().Before(leaf.Leaf.NotAfter) {
+			ca.lru.MoveToFront(elem)
+			return leaf, nil
+		}
+		ca.lru.Remove(elem)
+		delete(ca.leaves, host)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate leaf key: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   host,
+			Organization: []string{"ihpp"},
+		},
+		NotBefore:   time.Now().Add(-time.Hour),
+		NotAfter:    time.Now().AddDate(1, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else {
+		template.DNSNames = []string{host}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign leaf certificate for %s: %w", host, err)
+	}
+	leafCert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := &tls.Certificate{
+		Certificate: [][]byte{der, ca.Cert.Raw},
+		PrivateKey:  key,
+		Leaf:        leafCert,
+	}
+	ca.leaves[host] = ca.lru.PushFront(&cachedLeaf{host: host, cert: leaf})
+	if ca.lru.Len() > MaxCachedLeafCerts {
+		oldest := ca.lru.Back()
+		ca.lru.Remove(oldest)
+		delete(ca.leaves, oldest.Value.(*cachedLeaf).host)
+	}
+	return leaf, nil
+}
+
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial, nil
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadOrCreateCA(t *testing.T) {
+	dir := t.TempDir()
+
+	ca, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+	if !ca.Cert.IsCA {
+		t.Error("Expected generated certificate to be a CA")
+	}
+
+	info, err := os.Stat(filepath.Join(dir, caKeyFileName))
+	if err != nil {
+		t.Fatalf("Expected CA key to be written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected CA key mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Loading again must return the same CA
+	reloaded, err := LoadOrCreateCA(dir)
+	if err != nil {
+		t.Fatalf("Reloading CA failed: %v", err)
+	}
+	if !reloaded.Cert.Equal(ca.Cert) {
+		t.Error("Expected reloaded CA to match the generated one")
+	}
+
+	// A lost key must not silently replace a CA clients trust
+	if err := os.Remove(filepath.Join(dir, caKeyFileName)); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if _, err := LoadOrCreateCA(dir); err == nil {
+		t.Error("Expected an error when the CA key is missing")
+	}
+	if _, err := os.Stat(filepath.Join(dir, caKeyFileName)); !os.IsNotExist(err) {
+		t.Error("Expected no new CA key to be written")
+	}
+}
+
+func TestCertAuthority_LeafFor(t *testing.T) {
+	ca, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	for _, host := range []string{"example.com:443", "127.0.0.1"} {
+		leaf, err := ca.LeafFor(host)
+		if err != nil {
+			t.Fatalf("LeafFor(%s) failed: %v", host, err)
+		}
+
+		name := host
+		if host == "example.com:443" {
+			name = "example.com"
+		}
+		if _, err := leaf.Leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: pool}); err != nil {
+			t.Errorf("Leaf for %s does not verify against the CA: %v", host, err)
+		}
+	}
+
+	first, _ := ca.LeafFor("example.com")
+	second, _ := ca.LeafFor("example.com")
+	if first != second {
+		t.Error("Expected leaf certificates to be cached per host")
+	}
+
+	// The least recently used leaves are dropped once the cache is full
+	for i := range MaxCachedLeafCerts {
+		if i == MaxCachedLeafCerts/2 {
+			ca.LeafFor("127.0.0.1")
+		}
+		if _, err := ca.LeafFor(fmt.Sprintf("host%d.test", i)); err != nil {
+			t.Fatalf("LeafFor failed: %v", err)
+		}
+	}
+	if len(ca.leaves) != MaxCachedLeafCerts || ca.lru.Len() != MaxCachedLeafCerts {
+		t.Errorf("Expected %d cached leaves, got %d", MaxCachedLeafCerts, len(ca.leaves))
+	}
+	if _, ok := ca.leaves["example.com"]; ok {
+		t.Error("Expected the least recently used leaf to be evicted")
+	}
+	if _, ok := ca.leaves["127.0.0.1"]; !ok {
+		t.Error("Expected a recently used leaf to be kept")
+	}
+}
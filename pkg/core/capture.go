@@ -0,0 +1,72 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// captureBuffer keeps the first limit bytes written to it while counting all of them,
+// so a body can be recorded without holding more than the capture limit in memory
+type captureBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+	total int
+}
+
+func newCaptureBuffer(limit int) *captureBuffer {
+	return &captureBuffer{limit: limit}
+}
+
+// Write never fails, bytes past the limit are counted and dropped
+func (c *captureBuffer) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.total += len(p)
+	if room := c.limit - c.buf.Len(); room > 0 {
+		c.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// Bytes returns the captured prefix of everything written
+func (c *captureBuffer) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf.Bytes())
+}
+
+// Size returns the number of bytes written, captured or not
+func (c *captureBuffer) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Truncated reports whether more was written than captured
+func (c *captureBuffer) Truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total > c.buf.Len()
+}
+
+// captureBody trims an already buffered body to the capture limit,
+// returning the stored part, the full size and whether it was cut
+func captureBody(body []byte, limit int) ([]byte, int, bool) {
+	if len(body) <= limit {
+		return body, len(body), false
+	}
+	return body[:limit], len(body), true
+}
+
+// teeReadCloser copies everything read from the body into a capture while streaming it on
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func newTeeReadCloser(body io.ReadCloser, capture io.Writer) io.ReadCloser {
+	return &teeReadCloser{Reader: io.TeeReader(body, capture), Closer: body}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"fmt"
+	"mime"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Capture actions, deciding how an exchange is recorded. It is always proxied.
+const (
+	CaptureStore    = "store"     // Stored as a session (default)
+	CaptureNoBodies = "no-bodies" // Stored without its bodies, their sizes are kept
+	CaptureCount    = "count"     // Only counted in the stats
+)
+
+// capturePolicy holds the compiled capture filters of a proxy
+type capturePolicy struct {
+	filters       []*captureFilter
+	defaultAction string
+}
+
+type captureFilter struct {
+	name         string
+	paths        []*regexp.Regexp
+	methods      map[string]bool
+	contentTypes []string
+	statuses     [][2]int // Inclusive ranges
+	minBodySize  int
+	maxBodySize  int
+	action       string
+}
+
+// newCapturePolicy compiles the capture filters of an entry, nil when everything is stored
+func newCapturePolicy(entry SysConfigProxyEntry) (*capturePolicy, error) {
+	if len(entry.CaptureFilters) == 0 && (entry.CaptureDefault == "" || entry.CaptureDefault == CaptureStore) {
+		return nil, nil
+	}
+
+	policy := &capturePolicy{defaultAction: CaptureStore}
+	if entry.CaptureDefault != "" {
+		if !isCaptureAction(entry.CaptureDefault) {
+			return nil, fmt.Errorf("invalid 'capture-default': %q", entry.CaptureDefault)
+		}
+		policy.defaultAction = entry.CaptureDefault
+	}
+
+	for i, f := range entry.CaptureFilters {
+		cf, err := compileCaptureFilter(f)
+		if err != nil {
+			return nil, fmt.Errorf("capture filter %d: %w", i, err)
+		}
+		policy.filters = append(policy.filters, cf)
+	}
+	return policy, nil
+}
+
+func isCaptureAction(action string) bool {
+	return action == CaptureStore || action == CaptureNoBodies || action == CaptureCount
+}
+
+func compileCaptureFilter(f SysConfigCaptureFilter) (*captureFilter, error) {
+	if !isCaptureAction(f.Action) {
+		return nil, fmt.Errorf("invalid action %q, use %q, %q or %q", f.Action, CaptureStore, CaptureNoBodies, CaptureCount)
+	}
+	if f.MinBodySize < 0 {
+		return nil, fmt.Errorf("invalid min-body-size %d", f.MinBodySize)
+	}
+	if f.MaxBodySize < 0 || (f.MaxBodySize > 0 && f.MaxBodySize < f.MinBodySize) {
+		return nil, fmt.Errorf("invalid max-body-size %d", f.MaxBodySize)
+	}
+
+	cf := &captureFilter{name: f.Name, action: f.Action, minBodySize: f.MinBodySize, maxBodySize: f.MaxBodySize}
+	for _, glob := range f.Paths {
+		cf.paths = append(cf.paths, globToRegexp(glob))
+	}
+	if len(f.Methods) > 0 {
+		cf.methods = make(map[string]bool, len(f.Methods))
+		for _, m := range f.Methods {
+			cf.methods[strings.ToUpper(m)] = true
+		}
+	}
+	for _, ct := range f.ContentTypes {
+		ct = strings.ToLower(ct)
+		if _, err := path.Match(ct, ""); err != nil {
+			return nil, fmt.Errorf("invalid content type %q: %w", ct, err)
+		}
+		cf.contentTypes = append(cf.contentTypes, ct)
+	}
+	for _, code := range f.StatusCodes {
+		r, err := parseStatusRange(code)
+		if err != nil {
+			return nil, err
+		}
+		cf.statuses = append(cf.statuses, r)
+	}
+	return cf, nil
+}
+
+// globToRegexp turns a path glob into an anchored regexp, "**" matches across segments
+func globToRegexp(glob string) *regexp.Regexp {
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.MustCompile(sb.String())
+}
+
+// parseStatusRange reads "404", "5xx" or "300-399"
+func parseStatusRange(code string) ([2]int, error) {
+	invalid := fmt.Errorf("invalid status code %q", code)
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) == 3 && strings.HasSuffix(code, "xx") && code[0] >= '1' && code[0] <= '5' {
+		base := int(code[0]-'0') * 100
+		return [2]int{base, base + 99}, nil
+	}
+	if lo, hi, ok := strings.Cut(code, "-"); ok {
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from > to {
+			return [2]int{}, invalid
+		}
+		return [2]int{from, to}, nil
+	}
+	n, err := strconv.Atoi(code)
+	if err != nil {
+		return [2]int{}, invalid
+	}
+	return [2]int{n, n}, nil
+}
+
+// needsResponse reports whether the filter looks at anything only known once the exchange is done
+func (cf *captureFilter) needsResponse() bool {
+	return len(cf.contentTypes) > 0 || len(cf.statuses) > 0 || cf.minBodySize > 0 || cf.maxBodySize > 0
+}
+
+func (cf *captureFilter) matchesRequest(method, reqPath string) bool {
+	if cf.methods != nil && !cf.methods[method] {
+		return false
+	}
+	if len(cf.paths) == 0 {
+		return true
+	}
+	for _, re := range cf.paths {
+		if re.MatchString(reqPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func (cf *captureFilter) matches(entry *LogEntry) bool {
+	if !cf.matchesRequest(entry.RequestMethod, entry.RequestURL.Path) {
+		return false
+	}
+
+	if len(cf.contentTypes) > 0 {
+		mediaType, _, _ := mime.ParseMediaType(entry.ResponseHeaders.Get("Content-Type"))
+		matched := false
+		for _, ct := range cf.contentTypes {
+			if ok, _ := path.Match(ct, mediaType); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(cf.statuses) > 0 {
+		matched := false
+		for _, r := range cf.statuses {
+			if entry.StatusCode >= r[0] && entry.StatusCode <= r[1] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if cf.minBodySize > 0 || cf.maxBodySize > 0 {
+		size := max(entry.RequestBodySize, len(entry.RequestBody), entry.ResponseBodySize, len(entry.ResponseBody))
+		if size < cf.minBodySize || (cf.maxBodySize > 0 && size > cf.maxBodySize) {
+			return false
+		}
+	}
+	return true
+}
+
+// decideRequest returns the capture action for a request when it does not depend on the
+// response, or "" when it has to wait for decide
+func (p *capturePolicy) decideRequest(method, reqPath string) string {
+	if p == nil {
+		return CaptureStore
+	}
+	for _, cf := range p.filters {
+		if !cf.matchesRequest(method, reqPath) {
+			continue
+		}
+		if cf.needsResponse() {
+			return ""
+		}
+		return cf.action
+	}
+	return p.defaultAction
+}
+
+// decide returns the capture action for a finished exchange
+func (p *capturePolicy) decide(entry *LogEntry) string {
+	if p == nil {
+		return CaptureStore
+	}
+	for _, cf := range p.filters {
+		if cf.matches(entry) {
+			return cf.action
+		}
+	}
+	return p.defaultAction
+}
+
+// recordSession stores a finished exchange the way the capture filters say. session is nil
+// when it was not started, which stores it now unless it is only counted. It returns the
+// session to announce, nil when there is none.
+func recordSession(config *ProxyConfig, session *ProxySessionRow, entry *LogEntry) (*ProxySessionRow, error) {
+	if session != nil {
+		return session, FinishProxySession(config.DB, session, entry)
+	}
+
+	if entry.CaptureAction == "" {
+		entry.CaptureAction = config.Capture.decide(entry)
+	}
+	if entry.CaptureAction == CaptureCount {
+		log.Debug().Str("config_id", entry.ConfigID).Str("path", entry.RequestURL.Path).Msg("Counted exchange without storing it")
+		return nil, CountProxySession(config.DB, entry)
+	}
+	return CreateProxySession(config.DB, entry)
+}
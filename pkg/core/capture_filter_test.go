@@ -0,0 +1,159 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCapturePolicy(t *testing.T) {
+	policy, err := newCapturePolicy(SysConfigProxyEntry{
+		CaptureDefault: CaptureCount,
+		CaptureFilters: []SysConfigCaptureFilter{
+			{Paths: []string{"/static/**", "/*.ico"}, Action: CaptureCount},
+			{Methods: []string{"post"}, StatusCodes: []string{"5xx", "400-404"}, Action: CaptureStore},
+			{Paths: []string{"/api/**"}, MinBodySize: 100, Action: CaptureNoBodies},
+			{Paths: []string{"/api/**"}, Action: CaptureStore},
+			{Paths: []string{"/upload"}, MaxBodySize: 100, Action: CaptureStore},
+			{Paths: []string{"/upload"}, Action: CaptureNoBodies},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newCapturePolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		method, path string
+		status, size int
+		atRequest    string
+		atResponse   string
+	}{
+		{"GET", "/static/js/app.js", 200, 0, CaptureCount, CaptureCount},
+		{"GET", "/favicon.ico", 200, 0, CaptureCount, CaptureCount},
+		{"GET", "/a/favicon.ico", 200, 0, CaptureCount, CaptureCount}, // Default
+		{"POST", "/login", 503, 0, "", CaptureStore},
+		{"POST", "/login", 200, 0, "", CaptureCount},
+		{"GET", "/api/users", 200, 10, "", CaptureStore},
+		{"GET", "/api/users", 200, 1000, "", CaptureNoBodies},
+		{"PUT", "/upload", 200, 100, "", CaptureStore},
+		{"PUT", "/upload", 200, 101, "", CaptureNoBodies},
+	}
+	for _, tt := range tests {
+		if got := policy.decideRequest(tt.method, tt.path); got != tt.atRequest {
+			t.Errorf("%s %s: expected %q at request time, got %q", tt.method, tt.path, tt.atRequest, got)
+		}
+		entry := &LogEntry{
+			RequestMethod:    tt.method,
+			RequestURL:       &url.URL{Path: tt.path},
+			StatusCode:       tt.status,
+			ResponseHeaders:  http.Header{},
+			ResponseBodySize: tt.size,
+		}
+		if got := policy.decide(entry); got != tt.atResponse {
+			t.Errorf("%s %s %d: expected %q, got %q", tt.method, tt.path, tt.status, tt.atResponse, got)
+		}
+	}
+
+	for _, entry := range []SysConfigProxyEntry{
+		{CaptureDefault: "drop"},
+		{CaptureFilters: []SysConfigCaptureFilter{{Action: "skip"}}},
+		{CaptureFilters: []SysConfigCaptureFilter{{StatusCodes: []string{"2xxx"}, Action: CaptureCount}}},
+		{CaptureFilters: []SysConfigCaptureFilter{{ContentTypes: []string{"image/["}, Action: CaptureCount}}},
+		{CaptureFilters: []SysConfigCaptureFilter{{MinBodySize: 100, MaxBodySize: 10, Action: CaptureCount}}},
+	} {
+		if _, err := newCapturePolicy(entry); err == nil {
+			t.Errorf("Expected %+v to be rejected", entry)
+		}
+	}
+}
+
+func TestProxyHandler_CaptureFilters(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/logo.png" {
+			w.Header().Set("Content-Type", "image/png")
+		}
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer mockTarget.Close()
+
+	capture, err := newCapturePolicy(SysConfigProxyEntry{
+		CaptureFilters: []SysConfigCaptureFilter{
+			{Paths: []string{"/health"}, Action: CaptureCount},
+			{ContentTypes: []string{"image/*"}, Action: CaptureNoBodies},
+		},
+	})
+	if err != nil {
+		t.Fatalf("newCapturePolicy failed: %v", err)
+	}
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-capture-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Capture:     capture,
+	}
+	handler := NewProxyHandler(config)
+
+	for _, path := range []string{"/health", "/logo.png", "/api"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != "body of "+path {
+			t.Errorf("%s: expected to be proxied, got %q", path, w.Body.String())
+		}
+	}
+
+	sessions := waitForSessions(t, config, 2)
+	time.Sleep(50 * time.Millisecond)
+	db.Where("config_id = ?", config.ConfigID).Find(&sessions)
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 stored sessions, got %d", len(sessions))
+	}
+	for _, s := range sessions {
+		switch s.RequestPath {
+		case "/logo.png":
+			if len(s.ResponseBody) != 0 || s.ResponseBodySize != len("body of /logo.png") {
+				t.Errorf("Expected /logo.png without its body but with its size, got %q (%d)", s.ResponseBody, s.ResponseBodySize)
+			}
+		case "/api":
+			if string(s.ResponseBody) != "body of /api" {
+				t.Errorf("Expected /api with its body, got %q", s.ResponseBody)
+			}
+		default:
+			t.Errorf("Unexpected stored session %s", s.RequestPath)
+		}
+	}
+
+	counts, err := GetSessionCounts(db, config.ConfigID, 10, 0)
+	if err != nil || len(counts) != 1 || counts[0].RequestPath != "/health" || counts[0].Count != 1 {
+		t.Errorf("Expected /health to be counted once, got %+v (%v)", counts, err)
+	}
+}
+
+func TestCountProxySession_MasksPath(t *testing.T) {
+	db := setupTestDB(t)
+	red, err := newRedactor(&SysConfigRedaction{Patterns: []string{`tok_[a-z0-9]+`}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	entry := &LogEntry{
+		ConfigID:      "test-count-config",
+		RequestMethod: "GET",
+		RequestURL:    &url.URL{Path: "/reset/tok_abc123"},
+		StatusCode:    http.StatusOK,
+		Timestamp:     time.Now(),
+		redactor:      red,
+	}
+	if err := CountProxySession(db, entry); err != nil {
+		t.Fatalf("CountProxySession failed: %v", err)
+	}
+
+	counts, err := GetSessionCounts(db, entry.ConfigID, 10, 0)
+	if err != nil || len(counts) != 1 || counts[0].RequestPath != "/reset/[REDACTED]" {
+		t.Errorf("Expected the token masked in the counted path, got %+v (%v)", counts, err)
+	}
+}
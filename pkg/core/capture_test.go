@@ -0,0 +1,88 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureBuffer(t *testing.T) {
+	c := newCaptureBuffer(5)
+	c.Write([]byte("abc"))
+	c.Write([]byte("defgh"))
+
+	if string(c.Bytes()) != "abcde" {
+		t.Errorf("Expected captured 'abcde', got '%s'", string(c.Bytes()))
+	}
+	if c.Size() != 8 || !c.Truncated() {
+		t.Errorf("Expected size 8 truncated, got %d truncated=%v", c.Size(), c.Truncated())
+	}
+
+	body, size, truncated := captureBody([]byte("short"), 10)
+	if string(body) != "short" || size != 5 || truncated {
+		t.Errorf("Unexpected capture of short body: %q %d %v", body, size, truncated)
+	}
+}
+
+func TestProxyHandler_StreamBodies(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), 64*1024)
+
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		if len(received) != len(large) {
+			t.Errorf("Target expected %d request bytes, got %d", len(large), len(received))
+		}
+		w.Write(large)
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:        "test-stream-config",
+		TargetURL:       targetURL,
+		DB:              db,
+		WsPublishFn:     func(topic string, v any) {},
+		StreamBodies:    true,
+		MaxCaptureBytes: 1024,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	resp, err := http.Post(proxy.URL+"/upload", "application/octet-stream", bytes.NewReader(large))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// The client always receives the full body
+	if len(body) != len(large) {
+		t.Errorf("Expected %d response bytes, got %d", len(large), len(body))
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-stream-config").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if len(session.RequestBody) != 1024 || session.RequestBodySize != len(large) || !session.RequestBodyTruncated {
+		t.Errorf("Expected truncated request capture, got %d/%d truncated=%v",
+			len(session.RequestBody), session.RequestBodySize, session.RequestBodyTruncated)
+	}
+	if len(session.ResponseBody) != 1024 || session.ResponseBodySize != len(large) || !session.ResponseBodyTruncated {
+		t.Errorf("Expected truncated response capture, got %d/%d truncated=%v",
+			len(session.ResponseBody), session.ResponseBodySize, session.ResponseBodyTruncated)
+	}
+	if !strings.HasPrefix(string(large), string(session.ResponseBody)) {
+		t.Error("Expected the stored response to be a prefix of the full body")
+	}
+}
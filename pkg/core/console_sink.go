@@ -2,11 +2,8 @@ package core
 
 import (
 	"bytes"
-	"compress/flate"
-	"compress/gzip"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"sort"
@@ -14,7 +11,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/andybalholm/brotli"
 	"github.com/rs/zerolog/log"
 )
 
@@ -34,7 +30,7 @@ func printIncomingRequest(entry *LogEntry) {
 	)
 	fmt.Printf("%sHost:%s %s\n", ColorGray, ColorReset, entry.RequestHost)
 	printQueryParams(entry.RequestURL.Query())
-	printHeaders("Request Headers:", entry.RequestHeaders)
+	printHeaders("Request Headers:", entry.RequestHeaders, entry)
 	printBody("Request Body:", entry.RequestHeaders, entry.RequestBody, false)
 	fmt.Printf("%s------------------------%s\n", ColorBold+ColorCyan, ColorReset)
 }
@@ -56,14 +52,45 @@ func printTargetResponse(entry *LogEntry, status string, truncate bool) {
 		ColorGray, ColorReset,
 		ColorBold+statusColor, status, entry.StatusCode, ColorReset,
 	)
-	printHeaders("Response Headers:", entry.ResponseHeaders)
+	printHeaders("Response Headers:", entry.ResponseHeaders, entry)
 	printBody("Response Body:", entry.ResponseHeaders, entry.ResponseBody, truncate)
+	if len(entry.ResponseTrailers) > 0 {
+		printHeaders("Response Trailers:", entry.ResponseTrailers, entry)
+	}
 	fmt.Printf("%sDuration:%s %v%s\n", ColorGray, ColorReset, entry.Duration, ColorReset)
 	fmt.Printf("%s-----------------------%s\n", ColorBold+ColorCyan, ColorReset)
 }
 
-// printHeaders prints headers to the console with formatting and redaction
-func printHeaders(title string, h http.Header) {
+// printWebSocketFrame prints a one line summary of a relayed WebSocket frame
+func printWebSocketFrame(frame *ProxySessionFrameRow) {
+	if IsDaemon() {
+		return
+	}
+	arrow := ColorGreen + "-->" + ColorReset
+	if frame.Direction == WsFrameServerToClient {
+		arrow = ColorBlue + "<--" + ColorReset
+	}
+	preview := ""
+	if frame.Opcode == 0x1 {
+		preview = string(frame.Payload)
+		if len(preview) > 80 {
+			preview = preview[:80] + "..."
+		}
+	}
+	fmt.Printf("%s[WS]%s %s %s%s%s (%d bytes) %s\n",
+		ColorGray, ColorReset, arrow,
+		ColorBold, frame.OpcodeName(), ColorReset,
+		frame.PayloadSize, preview,
+	)
+}
+
+// printHeaders prints headers to the console with formatting and redaction, leaving out
+// the proxy's ConsoleHeadersToOmit and the headers it omits from sessions
+func printHeaders(title string, h http.Header, entry *LogEntry) {
+	hidden := entry.consoleOmit
+	if hidden == nil {
+		hidden = HeadersToOmit
+	}
 	fmt.Printf("%s%s:%s\n", ColorCyan, title, ColorReset)
 	headerCount := 0
 	keys := make([]string, 0, len(h))
@@ -74,7 +101,7 @@ func printHeaders(title string, h http.Header) {
 	for _, k := range keys {
 		vv := h[k]
 		lowerK := strings.ToLower(k)
-		if _, exists := HeadersToOmit[lowerK]; exists {
+		if _, exists := hidden[lowerK]; exists || entry.headersToOmit.omits(k) {
 			continue
 		}
 
@@ -133,38 +160,12 @@ func printBody(title string, headers http.Header, originalCompleteBody []byte, t
 	encoding = strings.ToLower(strings.TrimSpace(encoding))
 
 	var decompErr error
-	if encoding != "" && len(originalCompleteBody) > 0 {
-		bodyReader := bytes.NewReader(originalCompleteBody)
-		var reader io.Reader = bodyReader
-		switch encoding {
-		case "gzip":
-			gzipReader, err := gzip.NewReader(bodyReader)
-			if err == nil {
-				defer gzipReader.Close()
-				reader = gzipReader
-			} else {
-				decompErr = fmt.Errorf("gzip reader init failed: %w", err)
-			}
-		case "br":
-			reader = brotli.NewReader(bodyReader)
-		case "deflate":
-			flateReader := flate.NewReader(bodyReader)
-			defer flateReader.Close()
-			reader = flateReader
-		default:
-			reader = nil
-			decompErr = fmt.Errorf("unsupported encoding for logging: %s", encoding)
-		}
-
-		if reader != bodyReader && decompErr == nil {
-			var decompressedBuf bytes.Buffer
-			_, err := io.Copy(&decompressedBuf, reader)
-			if err == nil {
-				bodyToProcess = decompressedBuf.Bytes()
-				decompressed = true
-			} else {
-				decompErr = fmt.Errorf("decompression read failed (%s): %w", encoding, err)
-			}
+	if encoding != "" {
+		if decoded, err := decodeContent(originalCompleteBody, encoding); err == nil {
+			bodyToProcess = decoded
+			decompressed = true
+		} else {
+			decompErr = err
 		}
 	}
 
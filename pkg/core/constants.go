@@ -13,6 +13,42 @@ const (
 	DefaultResponseTimeout = 30 * time.Second
 	// Extended timeout for Server-Sent Events (SSE) streaming
 	SSEResponseTimeout = 60 * time.Minute
+
+	// Time a CONNECT tunnel client has to send its first bytes and finish the TLS handshake
+	ConnectHandshakeTimeout = 10 * time.Second
+
+	// Time a request in a CONNECT tunnel has to send its headers, and an idle tunnel is
+	// kept open between requests
+	TunnelReadHeaderTimeout = 30 * time.Second
+	TunnelIdleTimeout       = 2 * time.Minute
+
+	// Leaf certificates the CA keeps, the least recently used are minted again when needed
+	MaxCachedLeafCerts = 1000
+
+	// Max payload bytes captured per WebSocket frame (the full frame is always relayed)
+	MaxWsFrameCaptureSize = 1024 * 1024
+
+	// Max Server-Sent Events stored per session, and bytes kept of each line of one
+	MaxSSEEvents   = 10000
+	MaxSSELineSize = 1024 * 1024
+
+	// Streamed response bodies of in-flight sessions are published at most once per
+	// interval, with up to MaxLiveChunkSize bytes each time
+	LiveChunkInterval = 100 * time.Millisecond
+	MaxLiveChunkSize  = 64 * 1024
+
+	// Bytes of a live body held back for the next chunk when a redaction pattern has no
+	// longest match, e.g. because it ends with a '+'
+	MaxLiveRedactHold = 4 * 1024
+
+	// Default max bytes of a request or response body stored with a session
+	DefaultMaxCaptureBytes = 10 * 1024 * 1024
+
+	// Default max bytes of a buffered request body, see RequestBodyOverflow
+	DefaultMaxRequestBody = 10 * 1024 * 1024
+
+	// Default time an exchange waits at a breakpoint before it continues unchanged
+	DefaultBreakpointTimeout = 60 * time.Second
 )
 
 // ANSI Color Codes
@@ -51,7 +87,7 @@ var hopHeaders = []string{
 	"Proxy-Authenticate",
 	"Proxy-Authorization",
 	"Te",
-	"Trailers",
+	"Trailer", // Announced again for the trailers actually relayed
 	"Transfer-Encoding",
 	"Upgrade",
 }
@@ -0,0 +1,324 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Flags of the byte before each length-prefixed gRPC message
+const (
+	grpcFlagCompressed = 0x01
+	grpcFlagTrailers   = 0x80 // gRPC-Web sends its trailers as a last message
+)
+
+// GRPCMessage is one length-prefixed message of a gRPC exchange, stored as a JSON list with sessions
+type GRPCMessage struct {
+	Size       int             `json:"size"`                 // Payload bytes, as announced by the prefix
+	Compressed bool            `json:"compressed,omitempty"` // Compressed with the grpc-encoding of its side
+	Truncated  bool            `json:"truncated,omitempty"`  // Cut by the capture limit, not decoded
+	Data       json.RawMessage `json:"data,omitempty"`       // Decoded with the proxy's protoset
+	Error      string          `json:"error,omitempty"`      // Why the message could not be decoded
+}
+
+// isGRPCContentType reports whether a content type is gRPC or gRPC-Web
+func isGRPCContentType(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "application/grpc")
+}
+
+// isGRPCWebText reports whether the messages of a gRPC-Web body are base64 encoded
+func isGRPCWebText(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(contentType)), "application/grpc-web-text")
+}
+
+// grpcDecoder turns gRPC messages into JSON with the types of a protobuf descriptor set
+type grpcDecoder struct {
+	files *protoregistry.Files
+	types *dynamicpb.Types
+}
+
+// newGRPCDecoder loads a descriptor set, as written by protoc --descriptor_set_out
+// with --include_imports. It returns nil without a path.
+func newGRPCDecoder(path string) (*grpcDecoder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protoset: %w", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("invalid protoset %s: %w", path, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protoset %s: %w", path, err)
+	}
+	return &grpcDecoder{files: files, types: dynamicpb.NewTypes(files)}, nil
+}
+
+// method finds the method called by a request path like "/pkg.Service/Method"
+func (d *grpcDecoder) method(path string) (protoreflect.MethodDescriptor, error) {
+	service, name, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if !ok || service == "" || name == "" {
+		return nil, fmt.Errorf("not a gRPC method path: %s", path)
+	}
+	desc, err := d.files.FindDescriptorByName(protoreflect.FullName(service + "." + name))
+	if err != nil {
+		return nil, fmt.Errorf("method %s.%s not in protoset", service, name)
+	}
+	method, ok := desc.(protoreflect.MethodDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is not a method", service, name)
+	}
+	return method, nil
+}
+
+// decode renders a message payload as JSON
+func (d *grpcDecoder) decode(desc protoreflect.MessageDescriptor, payload []byte) (json.RawMessage, error) {
+	msg := dynamicpb.NewMessage(desc)
+	if err := (proto.UnmarshalOptions{Resolver: d.types}).Unmarshal(payload, msg); err != nil {
+		return nil, err
+	}
+	return protojson.MarshalOptions{Resolver: d.types}.Marshal(msg)
+}
+
+// grpcFrame is a length-prefixed message as found in a body
+type grpcFrame struct {
+	flags     byte
+	size      int
+	payload   []byte
+	truncated bool // The body ends before the payload does
+}
+
+// parseGRPCFrames splits a body into its length-prefixed messages. A captured body cut
+// at the capture limit ends with a truncated frame.
+func parseGRPCFrames(body []byte) []grpcFrame {
+	var frames []grpcFrame
+	for len(body) >= 5 {
+		f := grpcFrame{flags: body[0], size: int(binary.BigEndian.Uint32(body[1:5]))}
+		body = body[5:]
+		if f.size > len(body) {
+			f.payload, f.truncated = body, true
+			frames = append(frames, f)
+			break
+		}
+		f.payload, body = body[:f.size], body[f.size:]
+		frames = append(frames, f)
+	}
+	return frames
+}
+
+// decodeGRPCWebText decodes a gRPC-Web text body, which may be several base64 chunks
+// each with its own padding
+func decodeGRPCWebText(body []byte) []byte {
+	s := strings.Join(strings.Fields(string(body)), "")
+	var out []byte
+	for len(s) >= 4 {
+		end := len(s) - len(s)%4
+		if pad := strings.IndexByte(s, '='); pad >= 0 {
+			end = min((pad/4+1)*4, len(s))
+		}
+		chunk, err := base64.StdEncoding.DecodeString(s[:end])
+		if err != nil {
+			break
+		}
+		out = append(out, chunk...)
+		s = strings.TrimLeft(s[end:], "=")
+	}
+	return out
+}
+
+// grpcMessages describes the messages of one side of an exchange, decoding them to JSON
+// when desc is known. The gRPC-Web trailers message is returned apart.
+func grpcMessages(frames []grpcFrame, d *grpcDecoder, desc protoreflect.MessageDescriptor, descErr error, encoding string) ([]GRPCMessage, http.Header) {
+	messages := []GRPCMessage{}
+	var trailers http.Header
+	for _, f := range frames {
+		if f.flags&grpcFlagTrailers != 0 {
+			if !f.truncated {
+				trailers = parseGRPCWebTrailers(f.payload)
+			}
+			continue
+		}
+
+		m := GRPCMessage{Size: f.size, Compressed: f.flags&grpcFlagCompressed != 0, Truncated: f.truncated}
+		if d == nil || m.Truncated {
+			messages = append(messages, m)
+			continue
+		}
+		if descErr != nil {
+			m.Error = descErr.Error()
+			messages = append(messages, m)
+			continue
+		}
+
+		payload := f.payload
+		if m.Compressed {
+			var err error
+			if payload, err = grpcDecompress(payload, encoding); err != nil {
+				m.Error = err.Error()
+				messages = append(messages, m)
+				continue
+			}
+		}
+		data, err := d.decode(desc, payload)
+		if err != nil {
+			m.Error = err.Error()
+		} else {
+			m.Data = data
+		}
+		messages = append(messages, m)
+	}
+	return messages, trailers
+}
+
+// grpcDecompress inflates a compressed message, only gzip is supported
+func grpcDecompress(payload []byte, encoding string) ([]byte, error) {
+	if !strings.EqualFold(encoding, "gzip") {
+		return nil, fmt.Errorf("unsupported grpc-encoding %q", encoding)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// parseGRPCWebTrailers reads the header block of a gRPC-Web trailers message
+func parseGRPCWebTrailers(payload []byte) http.Header {
+	trailers := http.Header{}
+	for _, line := range strings.Split(string(payload), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		trailers.Add(textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)), strings.TrimSpace(value))
+	}
+	return trailers
+}
+
+// grpcStatus finds the status of a call in the first of sources that has it: the
+// trailers, the gRPC-Web trailers message or the headers of a trailers-only response
+func grpcStatus(sources ...http.Header) (*int, string) {
+	for _, h := range sources {
+		raw := h.Get("Grpc-Status")
+		if raw == "" {
+			continue
+		}
+		code, err := strconv.Atoi(raw)
+		if err != nil {
+			continue
+		}
+		// grpc-message is percent-encoded
+		message := h.Get("Grpc-Message")
+		if unescaped, err := url.PathUnescape(message); err == nil {
+			message = unescaped
+		}
+		return &code, message
+	}
+	return nil, ""
+}
+
+// recordGRPC sets the gRPC columns of a session, masking the decoded messages like bodies
+func recordGRPC(session *ProxySessionRow, entry *LogEntry) error {
+	d := entry.grpcDecoder
+	var method protoreflect.MethodDescriptor
+	var methodErr error
+	if d != nil {
+		method, methodErr = d.method(entry.RequestURL.Path)
+	}
+	var input, output protoreflect.MessageDescriptor
+	if method != nil {
+		input, output = method.Input(), method.Output()
+	}
+
+	requestBody, responseBody := entry.RequestBody, entry.ResponseBody
+	if isGRPCWebText(entry.RequestHeaders.Get("Content-Type")) {
+		requestBody = decodeGRPCWebText(requestBody)
+	}
+	if isGRPCWebText(entry.ResponseHeaders.Get("Content-Type")) {
+		responseBody = decodeGRPCWebText(responseBody)
+	}
+
+	requestMessages, _ := grpcMessages(parseGRPCFrames(requestBody), d, input, methodErr,
+		entry.RequestHeaders.Get("Grpc-Encoding"))
+	responseMessages, webTrailers := grpcMessages(parseGRPCFrames(responseBody), d, output, methodErr,
+		entry.ResponseHeaders.Get("Grpc-Encoding"))
+	session.GRPCStatus, session.GRPCStatusMessage = grpcStatus(entry.ResponseTrailers, webTrailers, entry.ResponseHeaders)
+
+	if entry.CaptureAction == CaptureNoBodies {
+		// Message sizes are kept, their content is not
+		for i := range requestMessages {
+			requestMessages[i].Data = nil
+		}
+		for i := range responseMessages {
+			responseMessages[i].Data = nil
+		}
+	}
+	entry.redactGRPCMessages(requestMessages, RedactRequestBody)
+	entry.redactGRPCMessages(responseMessages, RedactResponseBody)
+
+	requestJSON, err := json.Marshal(requestMessages)
+	if err != nil {
+		return err
+	}
+	responseJSON, err := json.Marshal(responseMessages)
+	if err != nil {
+		return err
+	}
+	session.GRPCRequestMessages = requestJSON
+	session.GRPCResponseMessages = responseJSON
+	return nil
+}
+
+// redactGRPCMessages masks decoded messages like JSON bodies
+func (e *LogEntry) redactGRPCMessages(messages []GRPCMessage, location string) {
+	for i, m := range messages {
+		if m.Data == nil {
+			continue
+		}
+		data := e.redactor.redactText(m.Data, location, &e.Redactions)
+		if !json.Valid(data) {
+			// A pattern matched across JSON syntax, keep nothing rather than a broken document
+			messages[i].Data, messages[i].Error = nil, "redacted"
+			continue
+		}
+		messages[i].Data = data
+	}
+}
+
+// flushWriter flushes the response after every write, so streamed messages reach the
+// client as they come
+type flushWriter struct {
+	io.Writer
+	rc *http.ResponseController
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.Writer.Write(p)
+	if err == nil {
+		_ = f.rc.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// writeTestProtoset writes a descriptor set for a greeter.Greeter/SayHello method taking
+// and returning a message with a single string field
+func writeTestProtoset(t *testing.T) string {
+	t.Helper()
+	message := func(name, field string) *descriptorpb.DescriptorProto {
+		return &descriptorpb.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String(field),
+				JsonName: proto.String(field),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			}},
+		}
+	}
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:        proto.String("greeter.proto"),
+		Package:     proto.String("greeter"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{message("HelloRequest", "name"), message("HelloReply", "message")},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("SayHello"),
+				InputType:  proto.String(".greeter.HelloRequest"),
+				OutputType: proto.String(".greeter.HelloReply"),
+			}},
+		}},
+	}}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("Failed to marshal protoset: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "greeter.protoset")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write protoset: %v", err)
+	}
+	return path
+}
+
+// grpcFrameBytes prefixes a message with its flags and length
+func grpcFrameBytes(flags byte, payload []byte) []byte {
+	frame := make([]byte, 5, 5+len(payload))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(payload)))
+	return append(frame, payload...)
+}
+
+// stringField encodes a message whose field 1 is s
+func stringField(s string) []byte {
+	return append([]byte{0x0a, byte(len(s))}, s...)
+}
+
+func TestProxyHandler_GRPC(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Te") != "trailers" {
+			t.Errorf("Expected the target to get TE: trailers, got %q", r.Header.Get("Te"))
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrameBytes(0, stringField("hello ann")))
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "not%20here")
+	}))
+	defer mockTarget.Close()
+
+	decoder, err := newGRPCDecoder(writeTestProtoset(t))
+	if err != nil {
+		t.Fatalf("newGRPCDecoder failed: %v", err)
+	}
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-grpc-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		GRPC:        decoder,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest("POST", proxy.URL+"/greeter.Greeter/SayHello",
+		strings.NewReader(string(grpcFrameBytes(0, stringField("ann")))))
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(body), "hello ann") {
+		t.Errorf("Expected the reply message, got %q", body)
+	}
+	if resp.Trailer.Get("Grpc-Status") != "5" {
+		t.Errorf("Expected the grpc-status trailer to be relayed, got %v", resp.Trailer)
+	}
+
+	s := waitForSession(t, config)
+	if s.GRPCStatus == nil || *s.GRPCStatus != 5 || s.GRPCStatusMessage != "not here" {
+		t.Errorf("Expected status 5 \"not here\", got %v %q", s.GRPCStatus, s.GRPCStatusMessage)
+	}
+	var requestMessages, responseMessages []GRPCMessage
+	json.Unmarshal(s.GRPCRequestMessages, &requestMessages)
+	json.Unmarshal(s.GRPCResponseMessages, &responseMessages)
+	if len(requestMessages) != 1 || string(requestMessages[0].Data) != `{"name":"ann"}` {
+		t.Errorf("Expected the decoded request message, got %s", s.GRPCRequestMessages)
+	}
+	if len(responseMessages) != 1 || string(responseMessages[0].Data) != `{"message":"hello ann"}` {
+		t.Errorf("Expected the decoded reply message, got %s", s.GRPCResponseMessages)
+	}
+
+	found, err := SearchSessions(db, config.ConfigID, "hello ann", 10, 0)
+	if err != nil || len(found) != 1 {
+		t.Errorf("Expected the decoded messages to be searchable, got %d sessions (%v)", len(found), err)
+	}
+}
+
+func TestProxyHandler_GRPCRedaction(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Write(grpcFrameBytes(0, stringField("hello ann")))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	defer mockTarget.Close()
+
+	decoder, err := newGRPCDecoder(writeTestProtoset(t))
+	if err != nil {
+		t.Fatalf("newGRPCDecoder failed: %v", err)
+	}
+	red, err := newRedactor(&SysConfigRedaction{JSONPaths: []string{"$.name"}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-grpc-redaction-config",
+		TargetURL:   targetURL,
+		DB:          setupTestDB(t),
+		WsPublishFn: func(topic string, v any) {},
+		GRPC:        decoder,
+		Redact:      red,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	req, _ := http.NewRequest("POST", proxy.URL+"/greeter.Greeter/SayHello",
+		strings.NewReader(string(grpcFrameBytes(0, stringField("s3cr3t")))))
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	s := waitForSession(t, config)
+	if string(s.GRPCRequestMessages) != `[{"size":8,"data":{"name":"[REDACTED]"}}]` {
+		t.Errorf("Expected the decoded name masked, got %s", s.GRPCRequestMessages)
+	}
+	// Protobuf cannot be masked, so the raw bodies are not stored at all
+	if s.RequestBody != nil || s.ResponseBody != nil {
+		t.Errorf("Expected no raw bodies, got %q and %q", s.RequestBody, s.ResponseBody)
+	}
+	if !strings.Contains(string(s.Redactions), RedactionRuleRawGRPC) {
+		t.Errorf("Expected the raw bodies to be listed as redacted, got %s", s.Redactions)
+	}
+}
+
+func TestGRPCWebMessages(t *testing.T) {
+	decoder, err := newGRPCDecoder(writeTestProtoset(t))
+	if err != nil {
+		t.Fatalf("newGRPCDecoder failed: %v", err)
+	}
+
+	// Each gRPC-Web text chunk is base64 encoded on its own
+	reply := grpcFrameBytes(0, stringField("hi"))
+	trailers := grpcFrameBytes(grpcFlagTrailers, []byte("grpc-status: 0\r\ngrpc-message: ok\r\n"))
+	body := base64.StdEncoding.EncodeToString(reply) + base64.StdEncoding.EncodeToString(trailers)
+
+	entry := &LogEntry{
+		RequestURL:      &url.URL{Path: "/greeter.Greeter/SayHello"},
+		RequestHeaders:  http.Header{"Content-Type": {"application/grpc-web-text"}},
+		RequestBody:     []byte(base64.StdEncoding.EncodeToString(grpcFrameBytes(0, stringField("bob")))),
+		ResponseHeaders: http.Header{"Content-Type": {"application/grpc-web-text+proto"}},
+		ResponseBody:    []byte(body),
+		grpcDecoder:     decoder,
+	}
+	var s ProxySessionRow
+	if err := recordGRPC(&s, entry); err != nil {
+		t.Fatalf("recordGRPC failed: %v", err)
+	}
+	if s.GRPCStatus == nil || *s.GRPCStatus != 0 || s.GRPCStatusMessage != "ok" {
+		t.Errorf("Expected the status from the trailers message, got %v %q", s.GRPCStatus, s.GRPCStatusMessage)
+	}
+	if string(s.GRPCRequestMessages) != `[{"size":5,"data":{"name":"bob"}}]` {
+		t.Errorf("Unexpected request messages %s", s.GRPCRequestMessages)
+	}
+	if string(s.GRPCResponseMessages) != `[{"size":4,"data":{"message":"hi"}}]` {
+		t.Errorf("Unexpected response messages %s", s.GRPCResponseMessages)
+	}
+
+	// A body cut by the capture limit ends with a truncated message, unknown methods are reported
+	entry.RequestURL.Path = "/greeter.Greeter/Unknown"
+	entry.ResponseBody = reply[:6]
+	entry.ResponseHeaders.Set("Content-Type", "application/grpc-web+proto")
+	if err := recordGRPC(&s, entry); err != nil {
+		t.Fatalf("recordGRPC failed: %v", err)
+	}
+	if string(s.GRPCResponseMessages) != `[{"size":4,"truncated":true}]` {
+		t.Errorf("Expected a truncated message, got %s", s.GRPCResponseMessages)
+	}
+	if !strings.Contains(string(s.GRPCRequestMessages), "not in protoset") {
+		t.Errorf("Expected the unknown method to be reported, got %s", s.GRPCRequestMessages)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+)
+
+// headerOmission lists the headers a proxy leaves out of stored sessions and its console output
+type headerOmission struct {
+	names []string            // Canonical names, as configured
+	set   map[string]struct{} // Lower case names
+}
+
+// newHeaderOmission returns nil when no header is omitted
+func newHeaderOmission(names []string) *headerOmission {
+	o := &headerOmission{set: make(map[string]struct{})}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		lower := strings.ToLower(name)
+		if _, dup := o.set[lower]; dup {
+			continue
+		}
+		o.set[lower] = struct{}{}
+		o.names = append(o.names, http.CanonicalHeaderKey(name))
+	}
+	if len(o.names) == 0 {
+		return nil
+	}
+	return o
+}
+
+// Names returns the omitted headers
+func (o *headerOmission) Names() []string {
+	if o == nil {
+		return []string{}
+	}
+	return o.names
+}
+
+func (o *headerOmission) omits(name string) bool {
+	if o == nil {
+		return false
+	}
+	_, ok := o.set[strings.ToLower(name)]
+	return ok
+}
+
+// filter returns h without the omitted headers, h itself when none of them is there
+func (o *headerOmission) filter(h http.Header) http.Header {
+	if o == nil {
+		return h
+	}
+	var out http.Header
+	for name := range h {
+		if !o.omits(name) {
+			continue
+		}
+		if out == nil {
+			out = h.Clone()
+		}
+		delete(out, name)
+	}
+	if out == nil {
+		return h
+	}
+	return out
+}
+
+// SetProxyOmitHeaders replaces the headers the proxy for configID leaves out of its
+// sessions until it is restarted, returning the list in effect. The list is only kept
+// across restarts once the config is exported.
+func SetProxyOmitHeaders(configID string, names []string) ([]string, error) {
+	pc := GlobalVar.GetProxyConfig(configID)
+	if pc == nil {
+		return nil, ErrProxyNotFound
+	}
+	pc.SetOmitHeaders(names)
+	return pc.OmitHeaders(), nil
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxyHandler_OmitHeaders(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Internal-Trace") == "" {
+			t.Error("Expected omitted headers to still reach the target")
+		}
+		w.Header().Set("X-Internal-Trace", "resp-trace")
+		w.Write([]byte("ok"))
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-omit-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	config.SetOmitHeaders([]string{" x-internal-trace", "X-Internal-Trace", ""})
+	if names := config.OmitHeaders(); len(names) != 1 || names[0] != "X-Internal-Trace" {
+		t.Fatalf("Expected one canonical header, got %v", names)
+	}
+	handler := NewProxyHandler(config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Internal-Trace", "req-trace")
+	req.Header.Set("X-Kept", "kept")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if w.Header().Get("X-Internal-Trace") != "resp-trace" {
+		t.Error("Expected omitted headers to still reach the client")
+	}
+
+	s := waitForSession(t, config)
+	stored := string(s.RequestHeaders) + string(s.ResponseHeaders)
+	if strings.Contains(stored, "trace") || !strings.Contains(stored, "X-Kept") {
+		t.Errorf("Expected X-Internal-Trace to be left out, got %s %s", s.RequestHeaders, s.ResponseHeaders)
+	}
+	if found, err := SearchSessions(db, config.ConfigID, "trace", 10, 0); err != nil || len(found) != 0 {
+		t.Errorf("Expected omitted headers to be missing from the search index, got %d sessions (%v)", len(found), err)
+	}
+
+	config.SetOmitHeaders(nil)
+	if names := config.OmitHeaders(); len(names) != 0 {
+		t.Errorf("Expected no omitted headers, got %v", names)
+	}
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// LiveTopic is the WebSocket topic of an in-flight session, see liveBody and FormatSessionEnd
+func LiveTopic(sessionID string) string {
+	return "session:" + sessionID
+}
+
+// liveBody publishes a streamed response body to the session's topic while it is
+// relayed. Chunks are coalesced so there is at most one message per LiveChunkInterval,
+// bytes over MaxLiveChunkSize in an interval are skipped. With redaction patterns, the
+// tail a match could still span is held back until the next chunk. A nil liveBody does nothing.
+type liveBody struct {
+	publish   func(topic string, v any)
+	sessionID string
+	redactor  *redactor
+
+	mu      sync.Mutex
+	pending []byte
+	skipped int         // Bytes dropped after pending
+	hold    int         // Bytes of pending held back for the next chunk, see redactor.patternCut
+	offset  int         // Body offset of pending
+	last    time.Time   // When the last chunk was published
+	timer   *time.Timer // Publishes pending once the interval is over
+	closed  bool
+}
+
+// newLiveBody returns nil when the body cannot be shown: the session is not stored,
+// its bodies are not kept, the body is compressed or JSON paths are redacted (which needs
+// the whole document)
+func newLiveBody(config *ProxyConfig, session *ProxySessionRow, entry *LogEntry, compressed bool) *liveBody {
+	if session == nil || config.WsPublishFn == nil || entry.CaptureAction == CaptureNoBodies || compressed {
+		return nil
+	}
+	l := &liveBody{publish: config.WsPublishFn, sessionID: session.ID, redactor: entry.redactor}
+	if r := entry.redactor; r != nil {
+		if len(r.jsonPaths) > 0 {
+			return nil
+		}
+		if len(r.patterns) > 0 {
+			l.hold = r.maxMatch
+			if l.hold < 0 {
+				l.hold = MaxLiveRedactHold
+			}
+		}
+	}
+	return l
+}
+
+// Write never fails, so a slow UI never holds up the client
+func (l *liveBody) Write(p []byte) (int, error) {
+	if l == nil {
+		return len(p), nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return len(p), nil
+	}
+
+	room := max(MaxLiveChunkSize-len(l.pending), 0)
+	if l.skipped > 0 {
+		// Keep what is published contiguous until the next message
+		room = 0
+	}
+	l.pending = append(l.pending, p[:min(len(p), room)]...)
+	l.skipped += len(p) - min(len(p), room)
+
+	if wait := LiveChunkInterval - time.Since(l.last); wait <= 0 {
+		l.flushLocked(false)
+	} else if l.timer == nil {
+		l.timer = time.AfterFunc(wait, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.timer = nil
+			if !l.closed {
+				l.flushLocked(false)
+			}
+		})
+	}
+	return len(p), nil
+}
+
+// flushLocked publishes pending but the tail held back for the next chunk, or all of it
+// when the body is complete
+func (l *liveBody) flushLocked(final bool) {
+	cut := len(l.pending)
+	if !final && l.hold > 0 {
+		cut = l.redactor.patternCut(l.pending, max(cut-l.hold, 0))
+	}
+	skipped := l.skipped
+	if skipped > 0 {
+		// What follows the tail is gone, so it cannot be masked once complete
+		skipped += len(l.pending) - cut
+	} else if cut == 0 {
+		return
+	}
+
+	var marks []Redaction
+	l.publish(LiveTopic(l.sessionID), map[string]any{
+		"type":       "session_chunk",
+		"session_id": l.sessionID,
+		"offset":     l.offset,
+		"data":       l.redactor.redactText(l.pending[:cut], RedactResponseBody, &marks),
+		"skipped":    skipped,
+	})
+	l.offset += cut + skipped
+	if skipped > 0 {
+		l.pending = nil
+	} else {
+		l.pending = append([]byte(nil), l.pending[cut:]...)
+	}
+	l.skipped = 0
+	l.last = time.Now()
+}
+
+// close publishes what is left once the body is relayed
+func (l *liveBody) close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.flushLocked(true)
+	l.closed = true
+}
+
+// FormatSessionEnd is published on the session's topic once it is stored, so viewers of
+// the in-flight session can load it in full
+func FormatSessionEnd(session *ProxySessionRow) map[string]any {
+	return map[string]any{
+		"type":       "session_end",
+		"session_id": session.ID,
+		"size":       session.ResponseBodySize,
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLiveBody_SkipsOverChunkSize(t *testing.T) {
+	var published []map[string]any
+	l := &liveBody{
+		publish:   func(topic string, v any) { published = append(published, v.(map[string]any)) },
+		sessionID: "s1",
+		last:      time.Now().Add(time.Hour), // Hold everything until close
+	}
+
+	l.Write(bytes.Repeat([]byte("a"), MaxLiveChunkSize-10))
+	l.Write(bytes.Repeat([]byte("b"), 30))
+	l.Write([]byte("c"))
+	l.close()
+	l.Write([]byte("late"))
+
+	if len(published) != 1 {
+		t.Fatalf("Expected a single coalesced message, got %d", len(published))
+	}
+	m := published[0]
+	data := m["data"].([]byte)
+	if m["offset"] != 0 || len(data) != MaxLiveChunkSize || m["skipped"] != 21 {
+		t.Errorf("Expected %d bytes then 21 skipped, got %d bytes, skipped %v", MaxLiveChunkSize, len(data), m["skipped"])
+	}
+	if !bytes.HasSuffix(data, bytes.Repeat([]byte("b"), 10)) {
+		t.Errorf("Expected the published part to be contiguous")
+	}
+}
+
+func TestLiveBody_RedactsAcrossWrites(t *testing.T) {
+	r, err := newRedactor(&SysConfigRedaction{Patterns: []string{"bearer-token", "aws-access-key"}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	var published []map[string]any
+	config := &ProxyConfig{WsPublishFn: func(topic string, v any) { published = append(published, v.(map[string]any)) }}
+	l := newLiveBody(config, &ProxySessionRow{ID: "s1"}, &LogEntry{redactor: r}, false)
+
+	// Each write is published at once, but for the tail a match could still span
+	for _, chunk := range []string{"key AKIAABCDEFGH", "IJKLMNOP, auth Bearer abc", "defghij done"} {
+		l.last = time.Time{}
+		l.Write([]byte(chunk))
+	}
+	l.close()
+
+	var live []byte
+	for _, m := range published {
+		if m["offset"] != len(live) || m["skipped"] != 0 {
+			t.Errorf("Expected offset %d and nothing skipped, got %v and %v", len(live), m["offset"], m["skipped"])
+		}
+		live = append(live, m["data"].([]byte)...)
+	}
+	if want := "key [REDACTED], auth Bearer [REDACTED] done"; string(live) != want {
+		t.Errorf("Expected %q, got %q", want, live)
+	}
+
+	// A bounded pattern only holds back as much as its longest match
+	r, _ = newRedactor(&SysConfigRedaction{Patterns: []string{"aws-access-key"}})
+	published = nil
+	l = newLiveBody(config, &ProxySessionRow{ID: "s2"}, &LogEntry{redactor: r}, false)
+	l.Write([]byte(strings.Repeat("x", 100) + " AKIAABCD"))
+	if len(published) != 1 || string(published[0]["data"].([]byte)) != strings.Repeat("x", 109-20) {
+		t.Errorf("Expected all but the 20 bytes of a key to be published, got %v", published)
+	}
+
+	jsonPaths, _ := newRedactor(&SysConfigRedaction{JSONPaths: []string{"$.password"}})
+	if newLiveBody(config, &ProxySessionRow{ID: "s3"}, &LogEntry{redactor: jsonPaths}, false) != nil {
+		t.Errorf("Expected no live body when JSON paths are redacted")
+	}
+}
+
+func TestProxyHandler_LiveBody(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 1; i <= 20; i++ {
+			fmt.Fprintf(w, "data: t%d\n\n", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(15 * time.Millisecond)
+		}
+	}))
+	defer mockTarget.Close()
+
+	var mu sync.Mutex
+	var chunks []map[string]any
+	ended := make(chan struct{})
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:  "test-live-body-config",
+		TargetURL: targetURL,
+		DB:        db,
+		WsPublishFn: func(topic string, v any) {
+			if !strings.HasPrefix(topic, "session:") {
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			m := v.(map[string]any)
+			if m["type"] == "session_end" {
+				close(ended)
+			} else {
+				chunks = append(chunks, m)
+			}
+		},
+	}
+	handler := NewProxyHandler(config)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/v1/completions", nil))
+	select {
+	case <-ended:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a session_end message once the session is stored")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(chunks) == 0 {
+		t.Fatal("Expected the body to be published while it streamed")
+	}
+	// However the chunks were coalesced, they add up to the body without gaps
+	var live []byte
+	for _, c := range chunks {
+		if c["offset"] != len(live) {
+			t.Errorf("Expected offset %d, got %v", len(live), c["offset"])
+		}
+		if c["skipped"] != 0 {
+			t.Errorf("Expected nothing skipped from a small body, got %v", c["skipped"])
+		}
+		live = append(live, c["data"].([]byte)...)
+	}
+	if string(live) != w.Body.String() {
+		t.Errorf("Expected the live chunks to add up to the body, got %q", live)
+	}
+}
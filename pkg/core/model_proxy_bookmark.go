@@ -41,7 +41,8 @@ type ProxyBookmark struct {
 	ResponseBodySize        int
 	ResponseContentType     string
 	ResponseContentEncoding string
-	ConfigID                string `gorm:"index"`
+	ConfigID                string         `gorm:"index"`
+	Redactions              datatypes.JSON `gorm:"type:text"` // What was masked or omitted when the session was stored
 
 	// Full copy of ProxyConfigRow fields (relevant ones)
 	ConfigSourcePath string
@@ -108,6 +109,7 @@ func CreateBookmark(db *gorm.DB, sessionID string) (*ProxyBookmark, error) {
 		ResponseContentType:     session.ResponseContentType,
 		ResponseContentEncoding: session.ResponseContentEncoding,
 		ConfigID:                session.ConfigID,
+		Redactions:              session.Redactions,
 		Note:                    "",
 		Tags:                    "",
 		ConfigSourcePath:        config.SourcePath,
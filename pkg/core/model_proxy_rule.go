@@ -0,0 +1,88 @@
+package core
+
+import (
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+)
+
+// ProxyRuleRow is a rewrite rule applied to the traffic of one proxy config
+type ProxyRuleRow struct {
+	ID        string    `gorm:"primaryKey;type:text" json:"id"`
+	ConfigID  string    `gorm:"not null;index:idx_rules_config_priority" json:"config_id"` // References ProxyConfigRow.ID
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name     string `gorm:"not null;default:''" json:"name"`
+	Enabled  bool   `gorm:"not null" json:"enabled"`
+	Priority int    `gorm:"not null;default:0;index:idx_rules_config_priority" json:"priority"` // Lower runs first
+	Phase    string `gorm:"not null" json:"phase"`                                              // RulePhaseRequest or RulePhaseResponse
+
+	Match   RuleMatch    `gorm:"column:matcher;type:text;serializer:json" json:"match"`
+	Actions []RuleAction `gorm:"type:text;serializer:json" json:"actions"`
+}
+
+// BeforeCreate is a GORM hook to generate rule ID
+func (r *ProxyRuleRow) BeforeCreate(tx *gorm.DB) (err error) {
+	if r.ID == "" {
+		id, err := gonanoid.New(12)
+		if err != nil {
+			return err
+		}
+		r.ID = id
+	}
+	return nil
+}
+
+// TableName overrides the default tablename
+func (ProxyRuleRow) TableName() string {
+	return "proxy_rules"
+}
+
+// GetProxyRules retrieves the rules of a config in the order they run
+func GetProxyRules(db *gorm.DB, configID string) ([]ProxyRuleRow, error) {
+	var rules []ProxyRuleRow
+	err := db.Where("config_id = ?", configID).
+		Order("priority ASC").
+		Order("created_at ASC").
+		Find(&rules).Error
+	return rules, err
+}
+
+// GetProxyRule retrieves a single rule of a config
+func GetProxyRule(db *gorm.DB, configID string, ruleID string) (*ProxyRuleRow, error) {
+	var rule ProxyRuleRow
+	if err := db.First(&rule, "id = ? AND config_id = ?", ruleID, configID).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// CreateProxyRule validates and stores a new rule
+func CreateProxyRule(db *gorm.DB, rule *ProxyRuleRow) error {
+	if err := ValidateProxyRule(rule); err != nil {
+		return err
+	}
+	return db.Create(rule).Error
+}
+
+// SaveProxyRule validates and updates an existing rule
+func SaveProxyRule(db *gorm.DB, rule *ProxyRuleRow) error {
+	if err := ValidateProxyRule(rule); err != nil {
+		return err
+	}
+	return db.Save(rule).Error
+}
+
+// DeleteProxyRule deletes a rule of a config
+func DeleteProxyRule(db *gorm.DB, configID string, ruleID string) error {
+	result := db.Delete(&ProxyRuleRow{}, "id = ? AND config_id = ?", ruleID, configID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -1,7 +1,11 @@
 package core
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
@@ -26,6 +30,13 @@ type ProxySessionRow struct {
 	Timestamp  time.Time `gorm:"not null;index:idx_sessions_timestamp"`
 	DurationMs int64     `gorm:"not null;index:idx_sessions_duration"` // Duration in milliseconds
 
+	// Upstream phase breakdown in milliseconds, see UpstreamTiming
+	TimingDNSMs      int64 `gorm:"column:timing_dns_ms;not null;default:0"`
+	TimingConnectMs  int64 `gorm:"column:timing_connect_ms;not null;default:0"`
+	TimingTLSMs      int64 `gorm:"column:timing_tls_ms;not null;default:0"`
+	TimingTTFBMs     int64 `gorm:"column:timing_ttfb_ms;not null;default:0"`
+	TimingTransferMs int64 `gorm:"column:timing_transfer_ms;not null;default:0"`
+
 	// Client information
 	ClientAddr string `gorm:"not null"`
 	ClientIP   string `gorm:"index:idx_sessions_client_ip"` // Extracted IP without port
@@ -36,15 +47,25 @@ type ProxySessionRow struct {
 	RequestQuery   string // Raw query string
 	RequestProto   string `gorm:"not null"`
 	RequestHost    string `gorm:"not null"`
-	RequestURLFull string `gorm:"not null"` // Complete URL for reference
+	RequestURLFull string `gorm:"not null"`               // Complete URL for reference
+	Route          string `gorm:"not null;default:''"`    // Name of the matched route, if any
+	Upstream       string `gorm:"not null;default:''"`    // Scheme and host of the target that served it
+	UpstreamProto  string `gorm:"not null;default:''"`    // Protocol the target answered with, empty when it was not called
+	Attempts       int    `gorm:"not null;default:1"`     // Upstreams tried, more than 1 after failover
+	Mocked         bool   `gorm:"not null;default:false"` // Answered by a mock rule instead of the target
+	ReplayedFrom   string `gorm:"not null;default:''"`    // ID of the recorded session replayed instead of calling the target
 
 	// Request headers and query params as JSON
 	RequestHeaders  datatypes.JSON `gorm:"type:text"` // Stored as JSON
 	QueryParameters datatypes.JSON `gorm:"type:text"` // Stored as JSON
+	RequestTrailers datatypes.JSON `gorm:"type:text"` // Sent after the body, stored like headers
 
 	// Request body
 	RequestBody            []byte `gorm:"type:blob"`
-	RequestBodySize        int    `gorm:"default:0"`
+	RequestBodySize        int    `gorm:"default:0"` // Full size, even when the stored body is truncated
+	RequestBodyTruncated   bool   `gorm:"not null;default:false"`
+	RequestBodyHash        string `gorm:"not null;default:''"` // SHA-256 of the full body, empty without a body
+	RequestBodyOverflow    string `gorm:"not null;default:''"` // How a body over max-request-body was handled, empty when under it
 	RequestContentType     string
 	RequestContentEncoding string
 
@@ -53,13 +74,59 @@ type ProxySessionRow struct {
 	ResponseStatusText string
 
 	// Response headers as JSON
-	ResponseHeaders datatypes.JSON `gorm:"type:text"` // Stored as JSON
+	ResponseHeaders  datatypes.JSON `gorm:"type:text"` // Stored as JSON
+	ResponseTrailers datatypes.JSON `gorm:"type:text"` // Sent after the body, stored like headers
 
 	// Response body
 	ResponseBody            []byte `gorm:"type:blob"`
-	ResponseBodySize        int    `gorm:"default:0"`
+	ResponseBodySize        int    `gorm:"default:0"` // Full size, even when the stored body is truncated
+	ResponseBodyTruncated   bool   `gorm:"not null;default:false"`
 	ResponseContentType     string
 	ResponseContentEncoding string
+
+	// Upstream TLS details (empty for plain HTTP targets)
+	UpstreamTLSVersion   string
+	UpstreamTLSCipher    string
+	UpstreamTLSPeerCerts datatypes.JSON `gorm:"type:text"` // Peer certificate chain as JSON
+
+	// Upstream failure details (empty when the target answered)
+	ErrorKind    string `gorm:"not null;default:''"`
+	ErrorMessage string
+
+	// Rewrite rules that ran (AppliedRule list as JSON). The request columns above are as
+	// the client sent them and the response columns as the client received them, so these
+	// keep the request sent to the target and the response it returned, when rules changed them.
+	AppliedRules               datatypes.JSON `gorm:"type:text"`
+	ModifiedRequestURL         string         `gorm:"not null;default:''"`
+	ModifiedRequestHeaders     datatypes.JSON `gorm:"type:text"`
+	ModifiedRequestBody        []byte         `gorm:"type:blob"`
+	OriginalResponseStatusCode int            `gorm:"not null;default:0"` // 0 when no response rule ran
+	OriginalResponseHeaders    datatypes.JSON `gorm:"type:text"`
+	OriginalResponseBody       []byte         `gorm:"type:blob"`
+
+	// Faults injected by the proxy (AppliedFault list as JSON)
+	AppliedFaults datatypes.JSON `gorm:"type:text"`
+
+	// What redaction rules masked before storing (Redaction list as JSON)
+	Redactions datatypes.JSON `gorm:"type:text"`
+
+	// gRPC calls only. The messages are GRPCMessage lists as JSON, decoded when the proxy
+	// has a protoset, and searched instead of the binary bodies.
+	GRPCStatus           *int           `gorm:"column:grpc_status"` // nil when no status was received
+	GRPCStatusMessage    string         `gorm:"column:grpc_status_message;not null;default:''"`
+	GRPCRequestMessages  datatypes.JSON `gorm:"column:grpc_request_messages;type:text"`
+	GRPCResponseMessages datatypes.JSON `gorm:"column:grpc_response_messages;type:text"`
+}
+
+// TLSCertificateInfo summarizes one certificate of the upstream peer chain
+type TLSCertificateInfo struct {
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	SHA256       string    `json:"sha256"`
 }
 
 // BeforeCreate is a GORM hook that runs before inserting into the DB
@@ -84,6 +151,9 @@ type ProxySessionStub struct {
 	ResponseStatusCode int
 	RequestMethod      string
 	RequestPath        string
+	Route              string
+	Mocked             bool
+	ReplayedFrom       string
 	Timestamp          time.Time
 	DurationMs         int64
 	Note               string
@@ -96,16 +166,35 @@ type ProxySessionStub struct {
 
 // StartProxySession inserts a new proxy session with initial request data
 func StartProxySession(db *gorm.DB, entry *LogEntry) (*ProxySessionRow, error) {
-	requestHeadersJSON, err := headerToJSON(entry.RequestHeaders)
+	// Secrets are masked before anything is stored or published
+	requestURL := entry.redactor.redactURL(entry.RequestURL, &entry.Redactions)
+	requestHeaders := entry.storedHeaders(entry.RequestHeaders, RedactRequestHeaders)
+	requestBody := entry.redactor.redactBody(entry.RequestBody, entry.RequestHeaders, RedactRequestBody, &entry.Redactions)
+
+	requestHeadersJSON, err := headerToJSON(requestHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	requestTrailersJSON, err := headerToJSON(entry.storedHeaders(entry.RequestTrailers, RedactRequestHeaders))
+	if err != nil {
+		return nil, err
+	}
+
+	queryParamsJSON, err := queryParamsToJSON(requestURL.Query())
 	if err != nil {
 		return nil, err
 	}
 
-	queryParamsJSON, err := queryParamsToJSON(entry.RequestURL.Query())
+	redactionsJSON, err := redactionsToJSON(entry.Redactions)
 	if err != nil {
 		return nil, err
 	}
 
+	if entry.CaptureAction == CaptureNoBodies {
+		requestBody = nil
+	}
+
 	session := ProxySessionRow{
 		ConfigID:   entry.ConfigID,
 		Timestamp:  entry.Timestamp,
@@ -115,21 +204,29 @@ func StartProxySession(db *gorm.DB, entry *LogEntry) (*ProxySessionRow, error) {
 		ClientIP:   extractIP(entry.ClientAddr),
 
 		RequestMethod:  entry.RequestMethod,
-		RequestPath:    entry.RequestURL.Path,
-		RequestQuery:   entry.RequestURL.RawQuery,
+		RequestPath:    requestURL.Path,
+		RequestQuery:   requestURL.RawQuery,
 		RequestProto:   entry.RequestProto,
 		RequestHost:    entry.RequestHost,
-		RequestURLFull: entry.RequestURL.String(),
+		RequestURLFull: requestURL.String(),
+		Route:          entry.Route,
+		Upstream:       entry.Upstream,
+		Attempts:       max(entry.Attempts, 1),
 
 		RequestHeaders:  requestHeadersJSON,
 		QueryParameters: queryParamsJSON,
+		RequestTrailers: requestTrailersJSON,
 
-		RequestBody:            entry.RequestBody,
-		RequestBodySize:        len(entry.RequestBody),
-		RequestContentType:     entry.RequestHeaders.Get("Content-Type"),
-		RequestContentEncoding: entry.RequestHeaders.Get("Content-Encoding"),
+		RequestBody:            requestBody,
+		RequestBodySize:        max(entry.RequestBodySize, len(entry.RequestBody)),
+		RequestBodyTruncated:   entry.RequestBodyTruncated,
+		RequestBodyHash:        requestBodyHash(entry),
+		RequestBodyOverflow:    entry.RequestBodyOverflow,
+		RequestContentType:     requestHeaders.Get("Content-Type"),
+		RequestContentEncoding: entry.storedEncoding(requestHeaders),
 
 		ResponseStatusCode: 0, // Pending
+		Redactions:         redactionsJSON,
 	}
 
 	if err := db.Create(&session).Error; err != nil {
@@ -148,29 +245,148 @@ func FormatSessionStub(session *ProxySessionRow) map[string]any {
 			ResponseStatusCode: session.ResponseStatusCode,
 			RequestMethod:      session.RequestMethod,
 			RequestPath:        session.RequestPath,
+			Route:              session.Route,
+			Mocked:             session.Mocked,
+			ReplayedFrom:       session.ReplayedFrom,
 			Timestamp:          session.Timestamp,
 			DurationMs:         session.DurationMs,
 		},
 	}
 }
 
+// FormatSessionFailed is the notification for a session whose target could not be reached
+func FormatSessionFailed(session *ProxySessionRow) map[string]any {
+	m := FormatSessionStub(session)
+	m["type"] = "session_failed"
+	m["error_kind"] = session.ErrorKind
+	m["error_message"] = session.ErrorMessage
+	return m
+}
+
 // FinishProxySession updates an existing proxy session with response data
 func FinishProxySession(db *gorm.DB, session *ProxySessionRow, entry *LogEntry) error {
-	responseHeadersJSON, err := headerToJSON(entry.ResponseHeaders)
+	red, marks := entry.redactor, &entry.Redactions
+	responseHeaders := entry.storedHeaders(entry.ResponseHeaders, RedactResponseHeaders)
+	responseHeadersJSON, err := headerToJSON(responseHeaders)
+	if err != nil {
+		return err
+	}
+	// Streamed request bodies end with their trailers once the exchange is done
+	requestTrailersJSON, err := headerToJSON(entry.storedHeaders(entry.RequestTrailers, RedactRequestHeaders))
+	if err != nil {
+		return err
+	}
+	responseTrailersJSON, err := headerToJSON(entry.storedHeaders(entry.ResponseTrailers, RedactResponseHeaders))
 	if err != nil {
 		return err
 	}
 
 	session.DurationMs = entry.Duration.Milliseconds()
+	session.TimingDNSMs = entry.Timing.DNS.Milliseconds()
+	session.TimingConnectMs = entry.Timing.Connect.Milliseconds()
+	session.TimingTLSMs = entry.Timing.TLS.Milliseconds()
+	session.TimingTTFBMs = entry.Timing.TTFB.Milliseconds()
+	session.TimingTransferMs = entry.Timing.Transfer.Milliseconds()
 	session.ResponseStatusCode = entry.StatusCode
 	session.ResponseStatusText = http.StatusText(entry.StatusCode)
 	session.ResponseHeaders = responseHeadersJSON
-	session.ResponseBody = entry.ResponseBody
-	session.ResponseBodySize = len(entry.ResponseBody)
-	session.ResponseContentType = entry.ResponseHeaders.Get("Content-Type")
-	session.ResponseContentEncoding = entry.ResponseHeaders.Get("Content-Encoding")
+	session.RequestTrailers = requestTrailersJSON
+	session.ResponseTrailers = responseTrailersJSON
+	session.ResponseBody = red.redactBody(entry.ResponseBody, entry.ResponseHeaders, RedactResponseBody, marks)
+	session.ResponseBodySize = max(entry.ResponseBodySize, len(entry.ResponseBody))
+	session.ResponseBodyTruncated = entry.ResponseBodyTruncated
+	// Streamed request bodies are only known once the exchange is done
+	session.RequestBody = red.redactBody(entry.RequestBody, entry.RequestHeaders, RedactRequestBody, marks)
+	session.RequestBodySize = max(entry.RequestBodySize, len(entry.RequestBody))
+	session.RequestBodyTruncated = entry.RequestBodyTruncated
+	session.RequestBodyHash = requestBodyHash(entry)
+	session.ErrorKind = entry.ErrorKind
+	session.Upstream = entry.Upstream
+	session.UpstreamProto = entry.UpstreamProto
+	session.Attempts = max(entry.Attempts, 1)
+	session.Mocked = entry.Mocked
+	session.ReplayedFrom = entry.ReplayedFrom
+	session.ErrorMessage = entry.ErrorMessage
+	session.ResponseContentType = responseHeaders.Get("Content-Type")
+	session.ResponseContentEncoding = entry.storedEncoding(responseHeaders)
+
+	if len(entry.AppliedRules) > 0 {
+		appliedJSON, err := json.Marshal(entry.AppliedRules)
+		if err != nil {
+			return err
+		}
+		session.AppliedRules = datatypes.JSON(appliedJSON)
+	}
+	if len(entry.AppliedFaults) > 0 {
+		faultsJSON, err := json.Marshal(entry.AppliedFaults)
+		if err != nil {
+			return err
+		}
+		session.AppliedFaults = datatypes.JSON(faultsJSON)
+	}
+	if entry.ModifiedRequestURL != nil {
+		modifiedHeadersJSON, err := headerToJSON(entry.storedHeaders(entry.ModifiedRequestHeaders, RedactRequestHeaders))
+		if err != nil {
+			return err
+		}
+		session.ModifiedRequestURL = red.redactURL(entry.ModifiedRequestURL, marks).String()
+		session.ModifiedRequestHeaders = modifiedHeadersJSON
+		session.ModifiedRequestBody = red.redactBody(entry.ModifiedRequestBody, entry.ModifiedRequestHeaders, RedactRequestBody, marks)
+	}
+	if entry.OriginalStatusCode != 0 {
+		originalHeadersJSON, err := headerToJSON(entry.storedHeaders(entry.OriginalResponseHeaders, RedactResponseHeaders))
+		if err != nil {
+			return err
+		}
+		session.OriginalResponseStatusCode = entry.OriginalStatusCode
+		session.OriginalResponseHeaders = originalHeadersJSON
+		session.OriginalResponseBody = red.redactBody(entry.OriginalResponseBody, entry.OriginalResponseHeaders, RedactResponseBody, marks)
+	}
+	if isGRPCContentType(entry.RequestHeaders.Get("Content-Type")) {
+		if err := recordGRPC(session, entry); err != nil {
+			return err
+		}
+	}
+	for _, event := range entry.SSEEvents {
+		if entry.CaptureAction == CaptureNoBodies {
+			// The timing of events is kept, their content is not
+			event.Data = ""
+			continue
+		}
+		event.Data = string(red.redactText([]byte(event.Data), RedactResponseBody, marks))
+	}
+	// Also lists what was masked when the session started
+	redactionsJSON, err := redactionsToJSON(entry.Redactions)
+	if err != nil {
+		return err
+	}
+	session.Redactions = redactionsJSON
+
+	if entry.CaptureAction == CaptureNoBodies {
+		// Sizes are kept, the content is not
+		session.RequestBody = nil
+		session.ResponseBody = nil
+		session.ModifiedRequestBody = nil
+		session.OriginalResponseBody = nil
+	}
 
-	return db.Save(session).Error
+	if entry.UpstreamTLS != nil {
+		peerCertsJSON, err := tlsPeerCertsToJSON(entry.UpstreamTLS)
+		if err != nil {
+			return err
+		}
+		session.UpstreamTLSVersion = tls.VersionName(entry.UpstreamTLS.Version)
+		session.UpstreamTLSCipher = tls.CipherSuiteName(entry.UpstreamTLS.CipherSuite)
+		session.UpstreamTLSPeerCerts = peerCertsJSON
+	}
+
+	if err := db.Save(session).Error; err != nil {
+		return err
+	}
+	for _, event := range entry.SSEEvents {
+		event.SessionID = session.ID
+	}
+	return CreateSessionEvents(db, entry.SSEEvents)
 }
 
 // CreateProxySession inserts a new proxy session with all data
@@ -236,11 +452,41 @@ func GetErrorSessions(db *gorm.DB, configID string, limit int, offset int) ([]Pr
 	return sessions, err
 }
 
-// GetSlowSessions retrieves sessions that exceeded duration for a specific config
-func GetSlowSessions(db *gorm.DB, configID string, minDurationMs int64, limit int, offset int) ([]ProxySessionRow, error) {
+// GetFailedSessions retrieves sessions whose target could not be reached for a specific config
+func GetFailedSessions(db *gorm.DB, configID string, limit int, offset int) ([]ProxySessionRow, error) {
+	var sessions []ProxySessionRow
+	err := db.Where("config_id = ? AND error_kind != ''", configID).
+		Order("timestamp DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// SlowSessionSortColumns maps the phases slow sessions can be sorted by to their columns
+var SlowSessionSortColumns = map[string]string{
+	"duration": "duration_ms",
+	"dns":      "timing_dns_ms",
+	"connect":  "timing_connect_ms",
+	"tls":      "timing_tls_ms",
+	"ttfb":     "timing_ttfb_ms",
+	"transfer": "timing_transfer_ms",
+}
+
+// GetSlowSessions retrieves sessions that exceeded duration for a specific config,
+// slowest first by sortBy (a SlowSessionSortColumns key, empty means total duration)
+func GetSlowSessions(db *gorm.DB, configID string, minDurationMs int64, sortBy string, limit int, offset int) ([]ProxySessionRow, error) {
+	if sortBy == "" {
+		sortBy = "duration"
+	}
+	column, ok := SlowSessionSortColumns[sortBy]
+	if !ok {
+		return nil, fmt.Errorf("invalid sort phase: %s", sortBy)
+	}
+
 	var sessions []ProxySessionRow
 	err := db.Where("config_id = ? AND duration_ms > ?", configID, minDurationMs).
-		Order("duration_ms DESC").
+		Order(column + " DESC").
 		Limit(limit).
 		Offset(offset).
 		Find(&sessions).Error
@@ -258,6 +504,17 @@ func GetSessionsByPath(db *gorm.DB, configID string, path string, limit int, off
 	return sessions, err
 }
 
+// GetSessionsByRoute retrieves sessions sent through a named route for a specific config
+func GetSessionsByRoute(db *gorm.DB, configID string, route string, limit int, offset int) ([]ProxySessionRow, error) {
+	var sessions []ProxySessionRow
+	err := db.Where("config_id = ? AND route = ?", configID, route).
+		Order("timestamp DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&sessions).Error
+	return sessions, err
+}
+
 // GetSessionsByMethod retrieves sessions by HTTP method and config
 func GetSessionsByMethod(db *gorm.DB, configID string, method string, limit int, offset int) ([]ProxySessionRow, error) {
 	var sessions []ProxySessionRow
@@ -334,6 +591,26 @@ func SearchSessions(db *gorm.DB, configID string, searchText string, limit int,
 // Internal Helpers & Stats
 // ============================================================
 
+// storedHeaders returns h the way it is stored, without the omitted headers and with secrets masked
+func (e *LogEntry) storedHeaders(h http.Header, location string) http.Header {
+	for name := range h {
+		if e.headersToOmit.omits(name) {
+			// Listed with redactions, so the session tells it was not stored as received
+			addRedaction(&e.Redactions, Redaction{Location: location, Rule: RedactionRuleOmit, Target: http.CanonicalHeaderKey(name)})
+		}
+	}
+	return e.redactor.redactHeaders(e.headersToOmit.filter(h), location, &e.Redactions)
+}
+
+// storedEncoding returns the content encoding of a body the way it is stored. Bodies are
+// masked decoded, so none is left when secrets are redacted.
+func (e *LogEntry) storedEncoding(h http.Header) string {
+	if e.redactor != nil {
+		return ""
+	}
+	return h.Get("Content-Encoding")
+}
+
 func headerToJSON(headers http.Header) (datatypes.JSON, error) {
 	if len(headers) == 0 {
 		return datatypes.JSON("{}"), nil
@@ -356,6 +633,38 @@ func queryParamsToJSON(params url.Values) (datatypes.JSON, error) {
 	return datatypes.JSON(data), nil
 }
 
+func redactionsToJSON(redactions []Redaction) (datatypes.JSON, error) {
+	if len(redactions) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(redactions)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
+func tlsPeerCertsToJSON(state *tls.ConnectionState) (datatypes.JSON, error) {
+	certs := make([]TLSCertificateInfo, 0, len(state.PeerCertificates))
+	for _, cert := range state.PeerCertificates {
+		fingerprint := sha256.Sum256(cert.Raw)
+		certs = append(certs, TLSCertificateInfo{
+			Subject:      cert.Subject.String(),
+			Issuer:       cert.Issuer.String(),
+			SerialNumber: cert.SerialNumber.String(),
+			NotBefore:    cert.NotBefore,
+			NotAfter:     cert.NotAfter,
+			DNSNames:     cert.DNSNames,
+			SHA256:       hex.EncodeToString(fingerprint[:]),
+		})
+	}
+	data, err := json.Marshal(certs)
+	if err != nil {
+		return nil, err
+	}
+	return datatypes.JSON(data), nil
+}
+
 func extractIP(addr string) string {
 	if idx := strings.LastIndex(addr, ":"); idx != -1 {
 		return addr[:idx]
@@ -363,16 +672,20 @@ func extractIP(addr string) string {
 	return addr
 }
 
+// CountSessionsByMethod counts stored sessions and exchanges only counted by capture filters
 func CountSessionsByMethod(db *gorm.DB) (map[string]int64, error) {
 	type Result struct {
 		RequestMethod string
 		Count         int64
 	}
 	var results []Result
-	err := db.Model(&ProxySessionRow{}).
-		Select("request_method, count(*) as count").
-		Group("request_method").
-		Find(&results).Error
+	err := db.Raw(`
+		SELECT request_method, SUM(n) AS count FROM (
+			SELECT request_method, COUNT(*) AS n FROM proxy_sessions GROUP BY request_method
+			UNION ALL
+			SELECT request_method, SUM(count) AS n FROM proxy_session_counts GROUP BY request_method
+		) GROUP BY request_method
+	`).Scan(&results).Error
 
 	if err != nil {
 		return nil, err
@@ -385,17 +698,21 @@ func CountSessionsByMethod(db *gorm.DB) (map[string]int64, error) {
 	return counts, nil
 }
 
+// GetAverageDurationByPath averages over stored sessions and exchanges only counted by capture filters
 func GetAverageDurationByPath(db *gorm.DB) (map[string]float64, error) {
 	type Result struct {
 		RequestPath string
 		AvgDuration float64
 	}
 	var results []Result
-	err := db.Model(&ProxySessionRow{}).
-		Select("request_path, AVG(duration_ms) as avg_duration").
-		Group("request_path").
-		Order("avg_duration DESC").
-		Find(&results).Error
+	err := db.Raw(`
+		SELECT request_path, CAST(SUM(total) AS REAL) / SUM(n) AS avg_duration FROM (
+			SELECT request_path, SUM(duration_ms) AS total, COUNT(*) AS n FROM proxy_sessions GROUP BY request_path
+			UNION ALL
+			SELECT request_path, SUM(total_duration_ms) AS total, SUM(count) AS n FROM proxy_session_counts GROUP BY request_path
+		) GROUP BY request_path
+		ORDER BY avg_duration DESC
+	`).Scan(&results).Error
 
 	if err != nil {
 		return nil, err
@@ -436,6 +753,24 @@ func (s *ProxySessionRow) ParseResponseHeaders() (http.Header, error) {
 	return headers, err
 }
 
+func (s *ProxySessionRow) ParseRequestTrailers() (http.Header, error) {
+	var trailers http.Header
+	if len(s.RequestTrailers) == 0 || string(s.RequestTrailers) == "{}" {
+		return http.Header{}, nil
+	}
+	err := json.Unmarshal(s.RequestTrailers, &trailers)
+	return trailers, err
+}
+
+func (s *ProxySessionRow) ParseResponseTrailers() (http.Header, error) {
+	var trailers http.Header
+	if len(s.ResponseTrailers) == 0 || string(s.ResponseTrailers) == "{}" {
+		return http.Header{}, nil
+	}
+	err := json.Unmarshal(s.ResponseTrailers, &trailers)
+	return trailers, err
+}
+
 func (s *ProxySessionRow) ParseQueryParameters() (url.Values, error) {
 	var params url.Values
 	if len(s.QueryParameters) == 0 || string(s.QueryParameters) == "{}" {
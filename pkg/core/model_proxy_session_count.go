@@ -0,0 +1,59 @@
+package core
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ProxySessionCountRow counts the exchanges of one kind that were proxied without being
+// stored, because a capture filter only counts them
+type ProxySessionCountRow struct {
+	ConfigID           string    `gorm:"primaryKey;type:text" json:"config_id"`
+	RequestMethod      string    `gorm:"primaryKey" json:"request_method"`
+	RequestPath        string    `gorm:"primaryKey" json:"request_path"`
+	ResponseStatusCode int       `gorm:"primaryKey;autoIncrement:false" json:"response_status_code"`
+	Count              int64     `gorm:"not null;default:0" json:"count"`
+	TotalDurationMs    int64     `gorm:"not null;default:0" json:"total_duration_ms"`
+	LastSeen           time.Time `gorm:"not null" json:"last_seen"`
+}
+
+// TableName overrides the default tablename
+func (ProxySessionCountRow) TableName() string {
+	return "proxy_session_counts"
+}
+
+// CountProxySession adds a finished exchange to the counts of its config, under its path
+// with secrets masked like in stored sessions
+func CountProxySession(db *gorm.DB, entry *LogEntry) error {
+	var marks []Redaction
+	row := ProxySessionCountRow{
+		ConfigID:           entry.ConfigID,
+		RequestMethod:      entry.RequestMethod,
+		RequestPath:        entry.redactor.redactURL(entry.RequestURL, &marks).Path,
+		ResponseStatusCode: entry.StatusCode,
+		Count:              1,
+		TotalDurationMs:    entry.Duration.Milliseconds(),
+		LastSeen:           entry.Timestamp,
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "config_id"}, {Name: "request_method"}, {Name: "request_path"}, {Name: "response_status_code"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"count":             gorm.Expr("count + 1"),
+			"total_duration_ms": gorm.Expr("total_duration_ms + ?", row.TotalDurationMs),
+			"last_seen":         row.LastSeen,
+		}),
+	}).Create(&row).Error
+}
+
+// GetSessionCounts returns the counted exchanges of a config, most frequent first
+func GetSessionCounts(db *gorm.DB, configID string, limit int, offset int) ([]ProxySessionCountRow, error) {
+	var rows []ProxySessionCountRow
+	err := db.Where("config_id = ?", configID).
+		Order("count DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&rows).Error
+	return rows, err
+}
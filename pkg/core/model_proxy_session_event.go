@@ -0,0 +1,55 @@
+package core
+
+import (
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+)
+
+// ProxySessionEventRow represents a single Server-Sent Event of a streamed response
+type ProxySessionEventRow struct {
+	ID        string    `gorm:"primaryKey;type:text"`
+	SessionID string    `gorm:"not null;index:idx_events_session_seq"` // References ProxySessionRow.ID
+	Seq       int64     `gorm:"not null;index:idx_events_session_seq"` // Order of the event within the session
+	Timestamp time.Time `gorm:"not null"`                              // When the blank line ending it arrived
+	OffsetMs  int64     `gorm:"not null;default:0"`                    // Since the request started
+
+	// Fields of the event, see sseParser
+	EventID string `gorm:"column:event_id;not null;default:''"`
+	Event   string `gorm:"not null;default:''"` // Empty for the default "message" type
+	Data    string `gorm:"type:text"`           // Data lines joined with "\n"
+	Retry   *int   // Reconnection time in milliseconds, nil when not set
+}
+
+// BeforeCreate is a GORM hook to generate event ID
+func (e *ProxySessionEventRow) BeforeCreate(tx *gorm.DB) (err error) {
+	if e.ID == "" {
+		id, err := gonanoid.New(12)
+		if err != nil {
+			return err
+		}
+		e.ID = id
+	}
+	return nil
+}
+
+// TableName overrides the default tablename
+func (ProxySessionEventRow) TableName() string {
+	return "proxy_session_events"
+}
+
+// CreateSessionEvents inserts the events parsed from a session's response
+func CreateSessionEvents(db *gorm.DB, events []*ProxySessionEventRow) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return db.CreateInBatches(events, 500).Error
+}
+
+// GetSessionEvents retrieves the events of a session in the order they arrived
+func GetSessionEvents(db *gorm.DB, sessionID string) ([]ProxySessionEventRow, error) {
+	events := []ProxySessionEventRow{}
+	err := db.Where("session_id = ?", sessionID).Order("seq ASC").Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
+)
+
+// WebSocket frame directions
+const (
+	WsFrameClientToServer = "client_to_server"
+	WsFrameServerToClient = "server_to_client"
+)
+
+// ProxySessionFrameRow represents a single WebSocket frame relayed within a session
+type ProxySessionFrameRow struct {
+	ID        string    `gorm:"primaryKey;type:text"`
+	SessionID string    `gorm:"not null;index:idx_frames_session_seq"` // References ProxySessionRow.ID
+	Seq       int64     `gorm:"not null;index:idx_frames_session_seq"` // Order of the frame within the session
+	Timestamp time.Time `gorm:"not null"`
+
+	Direction string `gorm:"not null"` // WsFrameClientToServer or WsFrameServerToClient
+	Opcode    int    `gorm:"not null"`
+	Fin       bool   `gorm:"not null;default:true"`
+
+	// Unmasked payload, capped at MaxWsFrameCaptureSize
+	Payload          []byte `gorm:"type:blob"`
+	PayloadSize      int64  `gorm:"default:0"`
+	PayloadTruncated bool   `gorm:"not null;default:false"`
+}
+
+// BeforeCreate is a GORM hook to generate frame ID
+func (f *ProxySessionFrameRow) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.ID == "" {
+		id, err := gonanoid.New(12)
+		if err != nil {
+			return err
+		}
+		f.ID = id
+	}
+	return nil
+}
+
+// TableName overrides the default tablename
+func (ProxySessionFrameRow) TableName() string {
+	return "proxy_session_frames"
+}
+
+// OpcodeName returns a readable name for the frame opcode
+func (f *ProxySessionFrameRow) OpcodeName() string {
+	switch f.Opcode {
+	case 0x0:
+		return "continuation"
+	case 0x1:
+		return "text"
+	case 0x2:
+		return "binary"
+	case 0x8:
+		return "close"
+	case 0x9:
+		return "ping"
+	case 0xA:
+		return "pong"
+	default:
+		return "unknown"
+	}
+}
+
+// CreateSessionFrame inserts a captured WebSocket frame
+func CreateSessionFrame(db *gorm.DB, frame *ProxySessionFrameRow) error {
+	return db.Create(frame).Error
+}
+
+// GetSessionFrames retrieves the frames of a session in the order they were relayed
+func GetSessionFrames(db *gorm.DB, sessionID string, limit int, offset int) ([]ProxySessionFrameRow, error) {
+	var frames []ProxySessionFrameRow
+	query := db.Where("session_id = ?", sessionID).Order("seq ASC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	err := query.Find(&frames).Error
+	return frames, err
+}
+
+// FormatSessionFrame builds the websocket notification for a captured frame
+func FormatSessionFrame(configID string, frame *ProxySessionFrameRow) map[string]any {
+	return map[string]any{
+		"type":      "new_frame",
+		"config_id": configID,
+		"frame":     frame,
+	}
+}
@@ -187,3 +187,48 @@ func TestSearchSessions(t *testing.T) {
 		t.Errorf("Expected URL 'http://example.com/banana', got '%s'", results[0].RequestURLFull)
 	}
 }
+
+func TestGetSlowSessions_SortByPhase(t *testing.T) {
+	db := setupTestDB(t)
+
+	u, _ := url.Parse("http://example.com/slow")
+	phases := []UpstreamTiming{
+		{TTFB: 900 * time.Millisecond, Transfer: 100 * time.Millisecond},
+		{TTFB: 100 * time.Millisecond, Transfer: 1500 * time.Millisecond},
+	}
+	for _, timing := range phases {
+		entry := &LogEntry{
+			ConfigID:       "test-config-slow",
+			Timestamp:      time.Now(),
+			ClientAddr:     "127.0.0.1:54321",
+			RequestMethod:  "GET",
+			RequestURL:     u,
+			RequestProto:   "HTTP/1.1",
+			RequestHost:    "example.com",
+			RequestHeaders: http.Header{},
+			StatusCode:     200,
+			Duration:       timing.TTFB + timing.Transfer,
+			Timing:         timing,
+		}
+		if _, err := CreateProxySession(db, entry); err != nil {
+			t.Fatalf("CreateProxySession failed: %v", err)
+		}
+	}
+
+	sessions, err := GetSlowSessions(db, "test-config-slow", 0, "ttfb", 10, 0)
+	if err != nil {
+		t.Fatalf("GetSlowSessions failed: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].TimingTTFBMs != 900 {
+		t.Errorf("Expected the slowest TTFB first, got %+v", sessions)
+	}
+
+	sessions, _ = GetSlowSessions(db, "test-config-slow", 0, "transfer", 10, 0)
+	if len(sessions) != 2 || sessions[0].TimingTransferMs != 1500 {
+		t.Errorf("Expected the slowest transfer first, got %+v", sessions)
+	}
+
+	if _, err := GetSlowSessions(db, "test-config-slow", 0, "bogus", 10, 0); err == nil {
+		t.Error("Expected an error for an unknown sort phase")
+	}
+}
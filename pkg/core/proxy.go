@@ -3,12 +3,20 @@ package core
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
-	"net"
+	"maps"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -31,236 +39,806 @@ type LogEntry struct {
 	ResponseHeaders http.Header
 	ResponseBody    []byte
 	Duration        time.Duration
+	UpstreamTLS     *tls.ConnectionState // Set when the target was reached over TLS
+
+	// Sent after the bodies, like the grpc-status of gRPC calls
+	RequestTrailers  http.Header
+	ResponseTrailers http.Header
+
+	// Full body sizes and whether the stored bodies were cut at the capture limit
+	RequestBodySize       int
+	RequestBodyTruncated  bool
+	RequestBodyOverflow   string // How a body over the proxy's max-request-body was handled, see BodyOverflowReject
+	ResponseBodySize      int
+	ResponseBodyTruncated bool
+
+	// Set when the target could not be reached, see classifyUpstreamError
+	ErrorKind    string
+	ErrorMessage string
+
+	Timing        UpstreamTiming
+	Route         string // Name of the matched route, empty when the default target was used
+	Upstream      string // Scheme and host of the target that served the request
+	UpstreamProto string // Protocol the target answered with, like "HTTP/2.0"
+	Attempts      int    // Upstreams tried, more than 1 after failing over
+	Mocked        bool   // Answered by a mock rule, the target was not called
+
+	// RequestBodyHash is the SHA-256 of the full request body, used to match it when replaying.
+	// When empty it is computed from RequestBody, unless that is truncated.
+	RequestBodyHash string
+	ReplayedFrom    string // ID of the recorded session answered instead of the target
+
+	AppliedFaults []AppliedFault // Faults injected into this exchange
+
+	SSEEvents []*ProxySessionEventRow // Parsed from a text/event-stream response as it arrived
+
+	// How the exchange is recorded, see CaptureStore. Empty until capture filters decide.
+	CaptureAction string
+
+	// What was masked before storing the exchange, by redactor when the proxy has one
+	Redactions []Redaction
+	redactor   *redactor
+
+	headersToOmit *headerOmission     // Left out of the stored session and the console output
+	consoleOmit   map[string]struct{} // Only left out of the console output, see ProxyConfig.ConsoleHeadersToOmit
+
+	grpcDecoder *grpcDecoder // Decodes gRPC messages, nil without a protoset
+
+	// Set when rewrite rules ran. The request fields above are as the client sent them
+	// and the response fields as the client received them, these keep the other side.
+	AppliedRules            []AppliedRule
+	ModifiedRequestURL      *url.URL
+	ModifiedRequestHeaders  http.Header
+	ModifiedRequestBody     []byte
+	OriginalStatusCode      int
+	OriginalResponseHeaders http.Header
+	OriginalResponseBody    []byte
 }
 
 // ProxyConfig holds the configuration for the proxy handler
 type ProxyConfig struct {
-	ConfigID        string
-	ListenAddr      string
-	TargetURL       *url.URL
-	TruncateLogBody bool
-	DB              *gorm.DB
-	HeadersToOmit   map[string]struct{}
-	WsPublishFn     func(topic string, v any)
+	ConfigID             string
+	ListenAddr           string
+	Mode                 string
+	TargetURL            *url.URL // Empty for forward proxies, which take the target from each request
+	TruncateLogBody      bool
+	DB                   *gorm.DB
+	ConsoleHeadersToOmit map[string]struct{} // Lower case names hidden from the console output only, HeadersToOmit by default. OmitHeaders governs sessions
+	WsPublishFn          func(topic string, v any)
+	CA                   *CertAuthority      // Signs intercepted HTTPS hosts in forward mode
+	Entry                SysConfigProxyEntry // The entry this proxy was started from
+	UpstreamTLS          *tls.Config         // Client TLS settings for targets, nil uses the defaults
+	Transport            *http.Transport     // Shared by all requests so upstream connections are reused
+	StreamBodies         bool                // Relay bodies as they arrive instead of buffering them
+	MaxCaptureBytes      int                 // Max body bytes stored per session, 0 uses DefaultMaxCaptureBytes
+	MaxRequestBody       int                 // Max bytes of a buffered request body, 0 uses DefaultMaxRequestBody
+	BodyOverflow         string              // What happens to request bodies over MaxRequestBody, see BodyOverflowReject
+	Routes               []*ProxyRoute       // Checked in order before falling back to TargetURL
+	Pool                 *upstreamPool       // Balances requests over several targets, replaces TargetURL
+	Replay               *replayer           // Answers from recorded sessions in replay and fallback modes
+	Capture              *capturePolicy      // Decides how exchanges are recorded, nil stores them all
+	Redact               *redactor           // Masks secrets before sessions are stored, nil keeps them
+	GRPC                 *grpcDecoder        // Decodes gRPC messages with the entry's protoset, nil keeps them undecoded
+
+	rules  atomic.Pointer[RuleSet]        // Rewrite rules, swapped when they are edited through the API
+	faults atomic.Pointer[FaultInjector]  // Injected faults, nil for none
+	omit   atomic.Pointer[headerOmission] // Headers left out of sessions, nil for none
+
+	tunnels tunnelSet // Hijacked CONNECT tunnel connections, closed when the proxy stops
+}
+
+// SetRules replaces the rewrite rules applied by this proxy
+func (c *ProxyConfig) SetRules(rules *RuleSet) {
+	c.rules.Store(rules)
+}
+
+// SetFaults replaces the faults injected by this proxy, nil turns them off
+func (c *ProxyConfig) SetFaults(faults *FaultInjector) {
+	c.faults.Store(faults)
+}
+
+// Faults returns the faults injected by this proxy, nil when there are none
+func (c *ProxyConfig) Faults() *FaultInjector {
+	return c.faults.Load()
+}
+
+// SetOmitHeaders replaces the headers this proxy leaves out of its stored sessions, their
+// search index, what is published to the UI and the console. It lasts until the proxy is
+// restarted, unless the config is exported.
+func (c *ProxyConfig) SetOmitHeaders(names []string) {
+	c.omit.Store(newHeaderOmission(names))
+}
+
+// OmitHeaders returns the headers this proxy leaves out of its sessions, see SetOmitHeaders
+func (c *ProxyConfig) OmitHeaders() []string {
+	return c.omit.Load().Names()
+}
+
+// requestBodyLimit returns the max bytes of a buffered request body
+func (c *ProxyConfig) requestBodyLimit() int {
+	if c.MaxRequestBody > 0 {
+		return c.MaxRequestBody
+	}
+	return DefaultMaxRequestBody
+}
+
+// bodyOverflow returns what happens to request bodies over requestBodyLimit
+func (c *ProxyConfig) bodyOverflow() string {
+	if c.BodyOverflow == "" {
+		return BodyOverflowReject
+	}
+	return c.BodyOverflow
+}
+
+// captureLimit returns the max body bytes stored with a session
+func (c *ProxyConfig) captureLimit() int {
+	if c.MaxCaptureBytes > 0 {
+		return c.MaxCaptureBytes
+	}
+	return DefaultMaxCaptureBytes
 }
 
 // NewProxyHandler creates a new HTTP handler for proxying requests
 func NewProxyHandler(config *ProxyConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if !IsDaemon() {
-			fmt.Printf("\n%s[Config: %s | Listen: %s | Target: %s]%s\n",
-				ColorBold+ColorGray, config.ConfigID, config.ListenAddr, config.TargetURL.String(), ColorReset)
-		}
-
-		startTime := time.Now()
-		entry := &LogEntry{
-			ConfigID:       config.ConfigID,
-			Timestamp:      startTime,
-			ClientAddr:     r.RemoteAddr,
-			RequestMethod:  r.Method,
-			RequestURL:     r.URL,
-			RequestProto:   r.Proto,
-			RequestHost:    r.Host,
-			RequestHeaders: r.Header.Clone(),
-		}
-
-		// --- Read Request Body ---
-		var requestBodyBytes []byte
-		if r.Body != nil && r.Body != http.NoBody {
-			maxBytes := int64(10 * 1024 * 1024)
-			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
-			var err error
-			requestBodyBytes, err = io.ReadAll(r.Body)
-			if err != nil {
-				if err.Error() == "http: request body too large" {
-					log.Error().Err(err).Msg("Request body exceeds limit")
-					http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
-					return
-				} else if err != io.EOF {
-					log.Error().Err(err).Msg("Failed reading request body")
-					http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-					return
-				}
+		if config.Mode == ProxyModeForward {
+			serveForwardProxy(config, w, r)
+			return
+		}
+		if config.Mode == ProxyModeReplay {
+			// Every request is answered from recorded sessions, no target is called
+			serveProxyRequest(config, w, r, nil, nil)
+			return
+		}
+
+		target := config.TargetURL
+		route := matchRoute(config.Routes, r)
+		if route != nil {
+			target = route.Target
+		} else if config.Pool != nil {
+			target = nil // Picked from the pool by serveProxyRequest
+		} else if target == nil || target.Host == "" {
+			http.Error(w, "Not Found: no route matches this request", http.StatusNotFound)
+			return
+		}
+		serveProxyRequest(config, w, r, target, route)
+	}
+}
+
+// serveProxyRequest forwards a single request to target and records the exchange.
+// route is the route that picked target, or nil. A nil target is picked from config.Pool,
+// except in replay mode where it stays nil.
+func serveProxyRequest(config *ProxyConfig, w http.ResponseWriter, r *http.Request, target *url.URL, route *ProxyRoute) {
+	var upstream *Upstream
+	if target == nil && config.Mode != ProxyModeReplay {
+		upstream = config.Pool.pick(nil)
+		target = upstream.URL
+	}
+	defer func() {
+		if upstream != nil {
+			config.Pool.release(upstream)
+		}
+	}()
+
+	targetDisplay := "(replay)"
+	if target != nil {
+		targetDisplay = target.String()
+	}
+	if !IsDaemon() {
+		fmt.Printf("\n%s[Config: %s | Listen: %s | Target: %s]%s\n",
+			ColorBold+ColorGray, config.ConfigID, config.ListenAddr, targetDisplay, ColorReset)
+	}
+
+	startTime := time.Now()
+	entry := &LogEntry{
+		ConfigID:       config.ConfigID,
+		Timestamp:      startTime,
+		ClientAddr:     r.RemoteAddr,
+		RequestMethod:  r.Method,
+		RequestURL:     r.URL,
+		RequestProto:   r.Proto,
+		RequestHost:    r.Host,
+		RequestHeaders: r.Header.Clone(),
+		Attempts:       1,
+		redactor:       config.Redact,
+		headersToOmit:  config.omit.Load(),
+		consoleOmit:    config.ConsoleHeadersToOmit,
+		grpcDecoder:    config.GRPC,
+	}
+	if target != nil {
+		entry.Upstream = target.Scheme + "://" + target.Host
+	}
+	if route != nil {
+		entry.Route = route.Name
+	}
+
+	// gRPC messages are streamed both ways, a call may never end otherwise
+	isGRPC := isGRPCContentType(r.Header.Get("Content-Type"))
+	streamBodies := config.StreamBodies || isGRPC
+
+	// --- Read Request Body ---
+	var requestBodyBytes []byte
+	var requestCapture *captureBuffer
+	var requestHash hash.Hash
+	if r.Body != nil && r.Body != http.NoBody && streamBodies {
+		// Tee the body to the target as it is read, recording it once the exchange is done
+		requestCapture = newCaptureBuffer(config.captureLimit())
+		requestHash = sha256.New()
+		r.Body = newTeeReadCloser(r.Body, io.MultiWriter(requestCapture, requestHash))
+	} else if r.Body != nil && r.Body != http.NoBody {
+		limit := config.requestBodyLimit()
+		var err error
+		requestBodyBytes, err = io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed reading request body")
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if len(requestBodyBytes) > limit {
+			entry.RequestBodyOverflow = config.bodyOverflow()
+			if entry.RequestBodyOverflow == BodyOverflowReject {
+				rejectRequestBody(config, w, r, entry, requestBodyBytes)
+				return
 			}
-			if rc, ok := r.Body.(io.ReadCloser); ok {
-				rc.Close()
+			// Relay the read part and the rest as it arrives, like with stream-bodies
+			log.Debug().Int("limit", limit).Str("overflow", entry.RequestBodyOverflow).Msg("Request body exceeds limit, streaming it")
+			storeLimit := 0
+			if entry.RequestBodyOverflow == BodyOverflowTruncate {
+				storeLimit = min(limit, config.captureLimit())
 			}
-			r.Body = io.NopCloser(bytes.NewBuffer(requestBodyBytes))
-			entry.RequestBody = requestBodyBytes
+			requestCapture = newCaptureBuffer(storeLimit)
+			requestHash = sha256.New()
+			rest := &teeReadCloser{Reader: io.MultiReader(bytes.NewReader(requestBodyBytes), r.Body), Closer: r.Body}
+			r.Body = newTeeReadCloser(rest, io.MultiWriter(requestCapture, requestHash))
+			requestBodyBytes = nil
 		} else {
-			r.Body = nil
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewBuffer(requestBodyBytes))
+			entry.RequestTrailers = receivedTrailers(r.Trailer)
+			entry.RequestBody, entry.RequestBodySize, entry.RequestBodyTruncated = captureBody(requestBodyBytes, config.captureLimit())
+			entry.RequestBodyHash = hashBody(requestBodyBytes)
 		}
+	} else {
+		r.Body = nil
+	}
 
-		// --- Print Incoming Request ---
-		printIncomingRequest(entry)
+	// --- Print Incoming Request ---
+	printIncomingRequest(entry)
 
-		// --- Start Session in DB and Notify ---
-		var session *ProxySessionRow
-		if config.DB != nil {
-			var err error
-			// Pass config.ConfigID to link this session to the configuration row
-			session, err = StartProxySession(config.DB, entry)
-			if err != nil {
-				log.Warn().Err(err).Msg("Failed to start session in database")
-			} else {
-				log.Debug().Str("session_id", session.ID).Str("config_id", session.ConfigID).Msg("Started new proxy session")
-				m := FormatSessionStub(session)
-				config.WsPublishFn("sessions", m)
+	// --- Start Session in DB and Notify ---
+	// Unless capture filters need the response to decide, which stores it once done
+	var session *ProxySessionRow
+	entry.CaptureAction = config.Capture.decideRequest(entry.RequestMethod, entry.RequestURL.Path)
+	if entry.CaptureAction == "" && isWebSocketUpgrade(r) {
+		// Frames are recorded as they come, so upgrades cannot wait
+		entry.CaptureAction = CaptureStore
+	}
+	if config.DB != nil && entry.CaptureAction != "" && entry.CaptureAction != CaptureCount {
+		var err error
+		// Pass config.ConfigID to link this session to the configuration row
+		session, err = StartProxySession(config.DB, entry)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to start session in database")
+		} else {
+			log.Debug().Str("session_id", session.ID).Str("config_id", session.ConfigID).Msg("Started new proxy session")
+			m := FormatSessionStub(session)
+			config.WsPublishFn("sessions", m)
+		}
+	}
+
+	// --- WebSocket Upgrade: Relay Frames Instead of Bodies ---
+	if isWebSocketUpgrade(r) && target != nil {
+		tried := map[*Upstream]bool{}
+		for {
+			targetReqURL := upstreamRequestURL(target, entry.RequestURL, route)
+			upstreamConn, err := dialWsUpstream(r.Context(), targetReqURL, config.Transport, config.UpstreamTLS)
+			if err == nil {
+				if upstream != nil {
+					config.Pool.markSuccess(upstream)
+				}
+				serveWebSocketProxy(config, w, r, entry, session, targetReqURL, upstreamConn)
+				return
 			}
+			if upstream != nil && isConnectError(err) {
+				// Nothing was sent yet, so the upgrade can go to another upstream
+				if next := config.Pool.failover(upstream, tried, true); next != nil {
+					log.Warn().Err(err).Str("upstream", entry.Upstream).Str("next", next.URL.Host).Msg("Upstream unreachable, retrying on another")
+					upstream = next
+					target = next.URL
+					entry.Upstream = target.Scheme + "://" + target.Host
+					entry.Attempts++
+					continue
+				}
+			}
+			failProxySession(config, w, r, entry, session, err)
+			return
+		}
+	}
+
+	// --- Apply Request Rules ---
+	outURL, outHeaders, outBody := entry.RequestURL, entry.RequestHeaders, requestBodyBytes
+	var mock *ruleMock
+	rules := config.rules.Load()
+	if rules.has(RulePhaseRequest) {
+		msg := &ruleMessage{
+			URL:     entry.RequestURL,
+			Header:  entry.RequestHeaders.Clone(),
+			Body:    requestBodyBytes,
+			HasBody: requestCapture == nil && isIdentityEncoding(entry.RequestHeaders),
+		}
+		applied := rules.apply(RulePhaseRequest, r, msg)
+		if !pauseAtBreakpoint(config, w, r, entry, session, RulePhaseRequest, msg) {
+			return
+		}
+		if len(applied) > 0 {
+			entry.AppliedRules = append(entry.AppliedRules, applied...)
+			entry.ModifiedRequestURL = msg.URL
+			entry.ModifiedRequestHeaders = msg.Header
+			entry.ModifiedRequestBody, _, _ = captureBody(msg.Body, config.captureLimit())
+			outURL, outHeaders, outBody = msg.URL, msg.Header, msg.Body
+		}
+		mock = msg.mock
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		transport = defaultUpstreamTransport
+	}
+	client := &http.Client{Transport: transport}
+
+	// Streamed bodies are consumed by the first attempt and cannot be sent again
+	canRetry := upstream != nil && isIdempotentMethod(r.Method) && requestCapture == nil
+	tried := map[*Upstream]bool{}
+
+	// --- Inject Latency and Error Faults ---
+	var resp *http.Response
+	var timer *upstreamTimer
+	faults := config.Faults()
+	if delay := faults.delay(); delay > 0 {
+		entry.AppliedFaults = append(entry.AppliedFaults, AppliedFault{Type: FaultLatency, Detail: delay.String()})
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			failProxySession(config, w, r, entry, session, r.Context().Err())
+			return
 		}
+	}
+	if faultResp := faults.errorResponse(); faultResp != nil {
+		drainStreamedBody(r, entry, requestCapture, requestHash)
+		entry.AppliedFaults = append(entry.AppliedFaults, AppliedFault{Type: FaultError, Detail: strconv.Itoa(faultResp.StatusCode)})
+		entry.Upstream = ""
+		timer = &upstreamTimer{}
+		resp = faultResp
+	}
 
-		// --- Prepare & Send Forwarded Request ---
-		targetReqURL := *config.TargetURL
-		targetReqURL.Path = singleJoiningSlash(config.TargetURL.Path, entry.RequestURL.Path)
-		targetReqURL.RawQuery = entry.RequestURL.RawQuery
+	// --- Answer From a Mock Without Calling the Target ---
+	if resp == nil && mock != nil {
+		drainStreamedBody(r, entry, requestCapture, requestHash)
+		if upstream != nil {
+			config.Pool.release(upstream)
+			upstream = nil
+		}
+		entry.Mocked = true
+		entry.Upstream = ""
+		timer = &upstreamTimer{}
+		resp = mock.mock.response(entry.RequestMethod, outURL, outHeaders, outBody)
+	}
 
-		proxyReq, err := http.NewRequest(entry.RequestMethod, targetReqURL.String(), r.Body)
+	// --- Answer From a Recorded Session in Replay Mode ---
+	if resp == nil && config.Mode == ProxyModeReplay {
+		drainStreamedBody(r, entry, requestCapture, requestHash)
+		timer = &upstreamTimer{}
+		var err error
+		if resp, err = config.replayResponse(entry); err != nil {
+			failProxySession(config, w, r, entry, session, err)
+			return
+		}
+	}
+
+	// --- Send Request to Target, Failing Over to Other Upstreams ---
+	for resp == nil {
+		targetReqURL := upstreamRequestURL(target, outURL, route)
+
+		var body io.Reader
+		if requestCapture != nil {
+			body = r.Body
+		} else if outBody != nil {
+			body = bytes.NewReader(outBody)
+		}
+		proxyReq, err := http.NewRequest(entry.RequestMethod, targetReqURL.String(), body)
 		if err != nil {
 			log.Error().Err(err).Msg("Failed to create new request")
 			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
-
-		copyHeaders(entry.RequestHeaders, proxyReq.Header)
-		proxyReq.Host = config.TargetURL.Host
-		proxyReq.Header.Set("X-Forwarded-Host", entry.RequestHost)
-		proxyReq.Header.Set("X-Forwarded-For", getClientIP(r))
-		if r.TLS != nil {
-			proxyReq.Header.Set("X-Forwarded-Proto", "https")
-		} else {
-			proxyReq.Header.Set("X-Forwarded-Proto", "http")
+		if requestCapture != nil {
+			proxyReq.ContentLength = r.ContentLength
+			// Filled in as the client's body is read to its end
+			proxyReq.Trailer = r.Trailer
+		} else if entry.RequestTrailers != nil {
+			proxyReq.Trailer = entry.RequestTrailers.Clone()
+			proxyReq.ContentLength = -1 // Chunked, HTTP/1.1 only sends trailers then
 		}
 
+		copyHeaders(outHeaders, proxyReq.Header)
+		proxyReq.Host = target.Host
+		setForwardedHeaders(proxyReq.Header, r)
+
 		removeHopByHopHeaders(proxyReq.Header)
+		if acceptsTrailers(r.Header) {
+			// Trailers are relayed, gRPC servers expect this
+			proxyReq.Header.Set("Te", "trailers")
+		}
 
-		transport := http.DefaultTransport.(*http.Transport).Clone()
-		transport.DisableCompression = true
-		client := &http.Client{Transport: transport}
+		timer = &upstreamTimer{}
+		traceCtx := httptrace.WithClientTrace(r.Context(), timer.trace())
+		resp, err = client.Do(proxyReq.WithContext(traceCtx))
+		if err == nil {
+			entry.UpstreamProto = resp.Proto
+			if upstream != nil {
+				config.Pool.markSuccess(upstream)
+			}
+			break
+		}
 
-		// --- Send Request to Target ---
-		resp, err := client.Do(proxyReq)
-		if err != nil {
-			log.Error().Err(err).Str("target_url", config.TargetURL.String()).Msg("Failed to reach target")
-			if urlErr, ok := err.(*url.Error); ok {
-				if _, ok := urlErr.Err.(*net.OpError); ok {
-					http.Error(w, "Bad Gateway", http.StatusBadGateway)
-					return
-				}
+		if upstream != nil && isConnectError(err) {
+			if next := config.Pool.failover(upstream, tried, canRetry); next != nil {
+				log.Warn().Err(err).Str("upstream", entry.Upstream).Str("next", next.URL.Host).Msg("Upstream unreachable, retrying on another")
+				upstream = next
+				target = next.URL
+				entry.Upstream = target.Scheme + "://" + target.Host
+				entry.Attempts++
+				continue
 			}
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		}
+
+		// In fallback mode an unreachable target is answered from its recordings
+		if config.Mode == ProxyModeFallback && isConnectError(err) {
+			drainStreamedBody(r, entry, requestCapture, requestHash)
+			replayed, replayErr := config.replayResponse(entry)
+			if replayErr == nil {
+				log.Warn().Err(err).Str("replayed_from", entry.ReplayedFrom).Msg("Target unreachable, replaying a recorded session")
+				resp = replayed
+				break
+			}
+		}
+
+		entry.Timing = timer.finish()
+		failProxySession(config, w, r, entry, session, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	// --- Check for SSE ---
+	contentType := resp.Header.Get("Content-Type")
+	isSSE := strings.Contains(strings.ToLower(contentType), "text/event-stream")
+
+	// --- Read Response Body (Standard/Buffering mode) ---
+	bufferResponse := !isSSE && !streamBodies
+	var responseBodyBytes []byte
+	if bufferResponse {
+		var err error
+		responseBodyBytes, err = io.ReadAll(resp.Body)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error reading full response body")
+		}
+		// Transfer ends with the target's body, not with rules, faults or the client
+		timer.finish()
+	}
+
+	// --- Apply Response Rules ---
+	// Streamed bodies are already on their way, only the status and headers can change
+	statusCode, responseHeaders := resp.StatusCode, resp.Header
+	if rules.has(RulePhaseResponse) {
+		msg := &ruleMessage{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       responseBodyBytes,
+			HasBody:    bufferResponse && isIdentityEncoding(resp.Header),
+		}
+		if applied := rules.apply(RulePhaseResponse, r, msg); len(applied) > 0 {
+			entry.AppliedRules = append(entry.AppliedRules, applied...)
+			entry.OriginalStatusCode = resp.StatusCode
+			entry.OriginalResponseHeaders = resp.Header.Clone()
+			entry.OriginalResponseBody, _, _ = captureBody(responseBodyBytes, config.captureLimit())
+		}
+		if !pauseAtBreakpoint(config, w, r, entry, session, RulePhaseResponse, msg) {
 			return
 		}
-		defer resp.Body.Close()
+		if len(entry.AppliedRules) > 0 {
+			statusCode, responseHeaders, responseBodyBytes = msg.StatusCode, msg.Header, msg.Body
+		}
+	}
 
-		// --- Check for SSE ---
-		contentType := resp.Header.Get("Content-Type")
-		isSSE := strings.Contains(strings.ToLower(contentType), "text/event-stream")
+	// --- Throttle or Drop the Response Body ---
+	bodyLength := resp.ContentLength
+	if bufferResponse {
+		bodyLength = int64(len(responseBodyBytes))
+	}
+	var body io.Writer = w
+	fw := faults.bodyWriter(w, r.Context(), bodyLength)
+	if fw != nil {
+		body = fw
+	}
 
-		// Set response headers
-		destHeaders := w.Header()
-		copyHeaders(resp.Header, destHeaders)
-		removeHopByHopHeaders(destHeaders)
+	// Set response headers
+	destHeaders := w.Header()
+	copyHeaders(responseHeaders, destHeaders)
+	removeHopByHopHeaders(destHeaders)
+	if len(resp.Trailer) > 0 {
+		// Announce the target's trailers so HTTP/1.1 clients get a chunked body ending with them
+		destHeaders.Set("Trailer", strings.Join(slices.Sorted(maps.Keys(resp.Trailer)), ", "))
+	}
 
-		var responseBodyBytes []byte
+	// Streamed bodies are also published to the UI as they come
+	var live *liveBody
+	if isSSE || streamBodies {
+		live = newLiveBody(config, session, entry, !isIdentityEncoding(responseHeaders))
+	}
 
-		if isSSE {
-			// Extend timeout for SSE
-			rc := http.NewResponseController(w)
-			_ = rc.SetWriteDeadline(time.Now().Add(SSEResponseTimeout))
+	if isSSE {
+		// Extend timeout for SSE
+		rc := http.NewResponseController(w)
+		_ = rc.SetWriteDeadline(time.Now().Add(SSEResponseTimeout))
 
-			w.WriteHeader(resp.StatusCode)
+		w.WriteHeader(statusCode)
 
-			// Stream and capture SSE response
-			var capturedBody bytes.Buffer
-			mw := io.MultiWriter(w, &capturedBody)
+		// Stream and capture SSE response, parsing its events as they arrive
+		responseCapture := newCaptureBuffer(config.captureLimit())
+		mw := io.MultiWriter(body, responseCapture, live)
+		events := newSSEParser(startTime, !isIdentityEncoding(resp.Header))
 
-			// Small buffer for frequent flushing
-			buf := make([]byte, 4096)
-			for {
-				n, err := resp.Body.Read(buf)
-				if n > 0 {
-					_, writeErr := mw.Write(buf[:n])
-					if writeErr != nil {
+		// Small buffer for frequent flushing
+		buf := make([]byte, 4096)
+		for {
+			n, err := resp.Body.Read(buf)
+			if n > 0 {
+				events.feed(buf[:n], time.Now())
+				_, writeErr := mw.Write(buf[:n])
+				if writeErr != nil {
+					if !errors.Is(writeErr, errFaultDropped) {
 						log.Warn().Err(writeErr).Msg("Failed writing SSE chunk to client")
-						break
-					}
-					_ = rc.Flush()
-				}
-				if err != nil {
-					if err != io.EOF {
-						log.Warn().Err(err).Msg("Error reading SSE response body")
 					}
 					break
 				}
+				_ = rc.Flush()
 			}
-			responseBodyBytes = capturedBody.Bytes()
-		} else {
-			// --- Read Response Body (Standard/Buffering mode) ---
-			// Apply default response timeout
-			rc := http.NewResponseController(w)
-			_ = rc.SetWriteDeadline(time.Now().Add(DefaultResponseTimeout))
-
-			responseBodyBytes, err = io.ReadAll(resp.Body)
 			if err != nil {
-				log.Warn().Err(err).Msg("Error reading full response body")
+				if err != io.EOF {
+					log.Warn().Err(err).Msg("Error reading SSE response body")
+				}
+				break
 			}
+		}
+		timer.finish()
+		entry.ResponseBody = responseCapture.Bytes()
+		entry.ResponseBodySize = responseCapture.Size()
+		entry.ResponseBodyTruncated = responseCapture.Truncated()
+		entry.SSEEvents = events.Events()
+		live.close()
+	} else if streamBodies {
+		// --- Stream Response Body While Capturing It ---
+		w.WriteHeader(statusCode)
 
-			w.WriteHeader(resp.StatusCode)
-			if len(responseBodyBytes) > 0 {
-				_, err = w.Write(responseBodyBytes)
-				if err != nil {
-					log.Warn().Err(err).Msg("Failed writing response body to client")
-				}
+		responseCapture := newCaptureBuffer(config.captureLimit())
+		dst := io.MultiWriter(body, responseCapture, live)
+		if isGRPC {
+			// Streamed calls need each message and the headers before the first one
+			rc := http.NewResponseController(w)
+			_ = rc.Flush()
+			dst = flushWriter{Writer: dst, rc: rc}
+		}
+		if _, err := io.Copy(dst, resp.Body); err != nil && !errors.Is(err, errFaultDropped) {
+			log.Warn().Err(err).Msg("Error streaming response body")
+		}
+		timer.finish()
+		live.close()
+		entry.ResponseBody = responseCapture.Bytes()
+		entry.ResponseBodySize = responseCapture.Size()
+		entry.ResponseBodyTruncated = responseCapture.Truncated()
+	} else {
+		// --- Write Buffered Response Body ---
+		// Apply default response timeout
+		rc := http.NewResponseController(w)
+		timeout := DefaultResponseTimeout
+		if fw != nil && fw.bandwidth > 0 {
+			// Leave time for the throttled body on top of the default
+			timeout += time.Duration(len(responseBodyBytes)/fw.bandwidth+1) * time.Second
+		}
+		_ = rc.SetWriteDeadline(time.Now().Add(timeout))
+
+		w.WriteHeader(statusCode)
+		if len(responseBodyBytes) > 0 {
+			written, err := body.Write(responseBodyBytes)
+			if errors.Is(err, errFaultDropped) {
+				// Record what the client got
+				responseBodyBytes = responseBodyBytes[:written]
+			} else if err != nil {
+				log.Warn().Err(err).Msg("Failed writing response body to client")
 			}
 		}
+		entry.ResponseBody, entry.ResponseBodySize, entry.ResponseBodyTruncated = captureBody(responseBodyBytes, config.captureLimit())
+	}
 
-		entry.ResponseBody = responseBodyBytes
-		entry.StatusCode = resp.StatusCode
-		entry.ResponseHeaders = resp.Header.Clone()
-		entry.Duration = time.Since(startTime)
+	// Trailers are known once the body was read
+	if trailers := receivedTrailers(resp.Trailer); trailers != nil {
+		for name, values := range trailers {
+			destHeaders[http.TrailerPrefix+name] = values
+		}
+		entry.ResponseTrailers = trailers
+	}
 
-		printTargetResponse(entry, resp.Status, config.TruncateLogBody)
+	if requestCapture != nil {
+		entry.RequestTrailers = receivedTrailers(r.Trailer)
+		entry.RequestBody = requestCapture.Bytes()
+		entry.RequestBodySize = requestCapture.Size()
+		entry.RequestBodyTruncated = requestCapture.Truncated()
+		entry.RequestBodyHash = streamedBodyHash(requestCapture, requestHash)
+	}
 
-		// --- Finish Session in DB and Notify (Asynchronously) ---
-		if config.DB != nil && session != nil {
-			go func(s *ProxySessionRow, e *LogEntry) {
-				if err := FinishProxySession(config.DB, s, e); err != nil {
-					log.Warn().Err(err).Msg("Failed to finish session in database")
-					return
-				}
+	entry.StatusCode = statusCode
+	entry.ResponseHeaders = responseHeaders.Clone()
+	entry.Duration = time.Since(startTime)
+	entry.Timing = timer.finish()
+	entry.UpstreamTLS = resp.TLS
+	entry.AppliedFaults = append(entry.AppliedFaults, fw.faults()...)
+
+	printTargetResponse(entry, resp.Status, config.TruncateLogBody)
+
+	// --- Finish Session in DB and Notify (Asynchronously) ---
+	if config.DB != nil {
+		go func(s *ProxySessionRow, e *LogEntry) {
+			s, err := recordSession(config, s, e)
+			if err != nil {
+				log.Warn().Err(err).Msg("Failed to finish session in database")
+				return
+			}
+			if s != nil {
 				m := FormatSessionStub(s)
 				config.WsPublishFn("sessions", m)
-			}(session, entry)
-		}
+				// Viewers of the in-flight session reload it
+				config.WsPublishFn(LiveTopic(s.ID), FormatSessionEnd(s))
+			}
+		}(session, entry)
+	}
 
+	if !IsDaemon() {
 		fmt.Printf("%s=======================%s\n", ColorBold+ColorGray, ColorReset)
 	}
+
+	if fw.drops() {
+		// Cut the connection before the body is complete
+		_ = http.NewResponseController(w).Flush()
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// upstreamRequestURL maps the client's request URL onto target
+func upstreamRequestURL(target *url.URL, requestURL *url.URL, route *ProxyRoute) *url.URL {
+	targetReqURL := *target
+	upstreamPath := requestURL.Path
+	if route != nil {
+		upstreamPath = route.upstreamPath(upstreamPath)
+	}
+	targetReqURL.Path = singleJoiningSlash(target.Path, upstreamPath)
+	targetReqURL.RawQuery = requestURL.RawQuery
+	return &targetReqURL
+}
+
+// setForwardedHeaders records the original client request in the X-Forwarded-* headers
+func setForwardedHeaders(h http.Header, r *http.Request) {
+	h.Set("X-Forwarded-Host", r.Host)
+	h.Set("X-Forwarded-For", getClientIP(r))
+	if r.TLS != nil {
+		h.Set("X-Forwarded-Proto", "https")
+	} else {
+		h.Set("X-Forwarded-Proto", "http")
+	}
 }
 
-// SetupProxyConfig creates a ProxyConfig from viper settings
+// SetupProxyConfig creates a ProxyConfig from a proxy entry and viper settings
 func SetupProxyConfig(
 	configID string,
-	listenAddr string,
+	proxyEntry SysConfigProxyEntry,
 	targetURL *url.URL,
 	db *gorm.DB,
-	truncateLogBody bool,
 	wsPublishFn func(topic string, v any),
-) *ProxyConfig {
+) (*ProxyConfig, error) {
 	// Normalize headers to omit
 	normalizedHeadersToOmit := make(map[string]struct{})
 	for header := range HeadersToOmit {
 		normalizedHeadersToOmit[strings.ToLower(header)] = struct{}{}
 	}
 
-	return &ProxyConfig{
-		ConfigID:        configID,
-		ListenAddr:      listenAddr,
-		TargetURL:       targetURL,
-		TruncateLogBody: viper.GetBool("truncate-log-body"),
-		DB:              db,
-		HeadersToOmit:   normalizedHeadersToOmit,
-		WsPublishFn:     wsPublishFn,
+	config := &ProxyConfig{
+		ConfigID:             configID,
+		ListenAddr:           proxyEntry.Listen,
+		Mode:                 proxyEntry.ProxyMode(),
+		TargetURL:            targetURL,
+		TruncateLogBody:      viper.GetBool("truncate-log-body"),
+		DB:                   db,
+		ConsoleHeadersToOmit: normalizedHeadersToOmit,
+		WsPublishFn:          wsPublishFn,
+		Entry:                proxyEntry,
+		StreamBodies:         proxyEntry.StreamBodies,
+		MaxCaptureBytes:      proxyEntry.MaxCaptureBytes,
+		MaxRequestBody:       proxyEntry.MaxRequestBody,
+		BodyOverflow:         proxyEntry.RequestBodyOverflow,
+	}
+	if err := validateRequestBodyLimit(proxyEntry); err != nil {
+		return nil, err
+	}
+
+	faults, err := NewFaultInjector(proxyEntry.Faults)
+	if err != nil {
+		return nil, err
+	}
+	config.SetFaults(faults)
+	config.SetOmitHeaders(proxyEntry.OmitHeaders)
+
+	capture, err := newCapturePolicy(proxyEntry)
+	if err != nil {
+		return nil, err
+	}
+	config.Capture = capture
+
+	redact, err := newRedactor(proxyEntry.Redact)
+	if err != nil {
+		return nil, err
+	}
+	config.Redact = redact
+
+	grpc, err := newGRPCDecoder(proxyEntry.Protoset)
+	if err != nil {
+		return nil, err
+	}
+	config.GRPC = grpc
+	if config.Mode == ProxyModeReplay || config.Mode == ProxyModeFallback {
+		config.Replay = newReplayer(configID, proxyEntry)
+	}
+
+	if db != nil {
+		rules, err := LoadProxyRules(db, configID)
+		if err != nil {
+			log.Warn().Err(err).Str("config_id", configID).Msg("Failed to load rewrite rules")
+		} else {
+			config.SetRules(rules)
+		}
+	}
+
+	upstreamTLS, err := upstreamTLSConfig(proxyEntry)
+	if err != nil {
+		return nil, err
+	}
+	config.UpstreamTLS = upstreamTLS
+
+	routes, err := compileRoutes(proxyEntry.Routes)
+	if err != nil {
+		return nil, err
+	}
+	config.Routes = routes
+
+	pool, err := newUpstreamPool(proxyEntry)
+	if err != nil {
+		return nil, err
+	}
+	config.Pool = pool
+
+	transport, err := newUpstreamTransport(proxyEntry, upstreamTLS)
+	if err != nil {
+		return nil, err
+	}
+	config.Transport = transport
+
+	if config.Mode == ProxyModeForward {
+		ca, err := DefaultCA()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load certificate authority: %w", err)
+		}
+		config.CA = ca
 	}
+
+	return config, nil
 }
 
 // StartProxyServer creates and starts a proxy server from a SysConfigProxyEntry
@@ -270,10 +848,24 @@ func StartProxyServer(
 	db *gorm.DB,
 	wsPublishFn func(topic string, v any),
 ) error {
-	// Validate target URL
-	targetURLParsed, err := url.Parse(proxyEntry.Target)
-	if err != nil || targetURLParsed.Scheme == "" {
-		return fmt.Errorf("invalid target URL: %s", proxyEntry.Target)
+	// Validate mode and target URL
+	targetURLParsed := &url.URL{}
+	switch proxyEntry.ProxyMode() {
+	case ProxyModeReverse, ProxyModeFallback:
+		// With routes or several targets the default target is optional
+		if proxyEntry.Target != "" || (len(proxyEntry.Routes) == 0 && len(proxyEntry.Targets) == 0) {
+			var err error
+			targetURLParsed, err = url.Parse(proxyEntry.Target)
+			if err != nil || targetURLParsed.Scheme == "" {
+				return fmt.Errorf("invalid target URL: %s", proxyEntry.Target)
+			}
+		}
+	case ProxyModeForward:
+		// Targets are taken from each request
+	case ProxyModeReplay:
+		// Recorded sessions answer every request
+	default:
+		return fmt.Errorf("invalid proxy mode: %s", proxyEntry.Mode)
 	}
 
 	// Validate listen address
@@ -281,6 +873,12 @@ func StartProxyServer(
 		return fmt.Errorf("missing 'listen' address")
 	}
 
+	// Load listener TLS before registering, so a bad cert never leaves a dangling config
+	tlsConfig, err := listenerTLSConfig(proxyEntry)
+	if err != nil {
+		return err
+	}
+
 	// Register configuration with database
 	configID, err := RegisterConfiguration(db, proxyEntry)
 	if err != nil {
@@ -293,24 +891,41 @@ func StartProxyServer(
 	}
 
 	// Setup proxy configuration
-	proxyConfig := SetupProxyConfig(
+	proxyConfig, err := SetupProxyConfig(
 		configID,
-		proxyEntry.Listen,
+		proxyEntry,
 		targetURLParsed,
 		db,
-		proxyEntry.TruncateLogBody,
 		wsPublishFn,
 	)
+	if err != nil {
+		return err
+	}
 
 	// Store ProxyConfig in GlobalVarStore's id_to_config map
 	if configID != "" {
 		GlobalVar.AddProxyConfig(configID, proxyConfig)
 	}
 
+	targetDisplay := proxyEntry.Target
+	if len(proxyEntry.Targets) > 0 {
+		targetDisplay = fmt.Sprintf("%s (%s)", strings.Join(proxyEntry.Targets, ", "), proxyConfig.Pool.strategy)
+	}
+	if proxyConfig.Mode == ProxyModeForward {
+		targetDisplay = "(forward proxy)"
+	} else if proxyConfig.Mode == ProxyModeReplay {
+		targetDisplay = "(replay)"
+	} else if len(proxyConfig.Routes) > 0 {
+		targetDisplay = fmt.Sprintf("%s (+%d routes)", targetDisplay, len(proxyConfig.Routes))
+	}
+	listenDisplay := proxyEntry.Listen
+	if tlsConfig != nil {
+		listenDisplay += " (TLS)"
+	}
 	fmt.Printf("%sProxy server:%s  %s%s%s -> %s%s%s (ID: %s)\n",
 		ColorCyan, ColorReset,
-		ColorBold, proxyEntry.Listen, ColorReset,
-		ColorBold, proxyEntry.Target, ColorReset,
+		ColorBold, listenDisplay, ColorReset,
+		ColorBold, targetDisplay, ColorReset,
 		configID,
 	)
 
@@ -320,7 +935,9 @@ func StartProxyServer(
 		Handler:      http.HandlerFunc(NewProxyHandler(proxyConfig)),
 		ReadTimeout:  0, // Handled per-request or no timeout for proxies
 		WriteTimeout: 0, // Handled per-request or no timeout for proxies
+		TLSConfig:    tlsConfig,
 	}
+	configureListenerProtocols(proxyServer, proxyEntry)
 
 	// Store ProxyServer in GlobalVarStore's id_to_proxyserver map
 	if configID != "" {
@@ -331,13 +948,22 @@ func StartProxyServer(
 		Int("index", index).
 		Str("listen", proxyEntry.Listen).
 		Str("target", proxyEntry.Target).
+		Str("mode", proxyConfig.Mode).
+		Bool("tls", tlsConfig != nil).
+		Bool("http2", proxyEntry.HTTP2).
 		Str("config_id", configID).
 		Bool("truncate_log_body", proxyEntry.TruncateLogBody).
 		Msg("Proxy server active")
 
 	// Start proxy server in its own goroutine
 	go func(srv *http.Server, idx int) {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if srv.TLSConfig != nil {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Error().
 				Int("index", idx).
 				Err(err).
@@ -385,6 +1011,14 @@ func StopProxyServer(configID string) error {
 		}
 	}
 
+	// Release pooled upstream connections and close the tunnels the server let go of
+	if pc := GlobalVar.GetProxyConfig(configID); pc != nil {
+		pc.tunnels.closeAll()
+		if pc.Transport != nil {
+			pc.Transport.CloseIdleConnections()
+		}
+	}
+
 	// Remove the server from GlobalVarStore
 	GlobalVar.RemoveProxyServer(configID)
 
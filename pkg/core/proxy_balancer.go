@@ -0,0 +1,220 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Load balancing strategies for proxies with several targets
+const (
+	LoadBalanceRoundRobin = "round-robin"
+	LoadBalanceLeastConn  = "least-conn"
+	LoadBalanceWeighted   = "weighted"
+)
+
+// Passive health check defaults
+const (
+	DefaultUpstreamMaxFails    = 3
+	DefaultUpstreamFailTimeout = 30 * time.Second
+)
+
+// Upstream is one target of an upstream pool
+type Upstream struct {
+	URL    *url.URL
+	Weight int
+
+	active        int       // Requests in flight
+	fails         int       // Consecutive connect errors
+	downUntil     time.Time // Skipped by pick until then
+	currentWeight int       // Smooth weighted round-robin state
+}
+
+// upstreamPool spreads requests over several targets and takes failing ones out of rotation
+type upstreamPool struct {
+	mu          sync.Mutex
+	upstreams   []*Upstream
+	strategy    string
+	next        int
+	maxFails    int
+	failTimeout time.Duration
+	maxRetries  int
+}
+
+// newUpstreamPool builds the pool for an entry's targets, or returns nil when it has none
+func newUpstreamPool(entry SysConfigProxyEntry) (*upstreamPool, error) {
+	if len(entry.Targets) == 0 {
+		return nil, nil
+	}
+	if entry.Target != "" {
+		return nil, fmt.Errorf("set either 'target' or 'targets', not both")
+	}
+	if len(entry.TargetWeights) > 0 && len(entry.TargetWeights) != len(entry.Targets) {
+		return nil, fmt.Errorf("'target-weights' needs one weight per target")
+	}
+
+	strategy := entry.LoadBalance
+	switch strategy {
+	case "":
+		strategy = LoadBalanceRoundRobin
+	case LoadBalanceRoundRobin, LoadBalanceLeastConn, LoadBalanceWeighted:
+	default:
+		return nil, fmt.Errorf("invalid load-balance strategy: %s", entry.LoadBalance)
+	}
+
+	failTimeout, err := parseDurationOption("fail-timeout", entry.FailTimeout, DefaultUpstreamFailTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := &upstreamPool{
+		strategy:    strategy,
+		maxFails:    DefaultUpstreamMaxFails,
+		failTimeout: failTimeout,
+		maxRetries:  len(entry.Targets) - 1,
+	}
+	if entry.MaxFails > 0 {
+		pool.maxFails = entry.MaxFails
+	}
+	if entry.MaxRetries > 0 {
+		pool.maxRetries = entry.MaxRetries
+	} else if entry.MaxRetries < 0 {
+		pool.maxRetries = 0
+	}
+
+	for i, target := range entry.Targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid target URL: %s", target)
+		}
+		weight := 1
+		if len(entry.TargetWeights) > 0 {
+			weight = entry.TargetWeights[i]
+			if weight <= 0 {
+				return nil, fmt.Errorf("weight of target %s must be positive", target)
+			}
+		}
+		pool.upstreams = append(pool.upstreams, &Upstream{URL: u, Weight: weight})
+	}
+	return pool, nil
+}
+
+// pick chooses the next upstream, skipping excluded ones and those marked down.
+// When every candidate is down it still returns one rather than failing outright.
+// The caller must release the upstream once the request is done.
+func (p *upstreamPool) pick(exclude map[*Upstream]bool) *Upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	var healthy, fallback []*Upstream
+	for _, u := range p.upstreams {
+		if exclude[u] {
+			continue
+		}
+		fallback = append(fallback, u)
+		if now.After(u.downUntil) {
+			healthy = append(healthy, u)
+		}
+	}
+	candidates := healthy
+	if len(candidates) == 0 {
+		candidates = fallback
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	var chosen *Upstream
+	switch p.strategy {
+	case LoadBalanceLeastConn:
+		// Rotate the starting point so ties are shared
+		start := p.next % len(candidates)
+		p.next++
+		for i := range candidates {
+			u := candidates[(start+i)%len(candidates)]
+			if chosen == nil || u.active < chosen.active {
+				chosen = u
+			}
+		}
+	case LoadBalanceWeighted:
+		// Smooth weighted round-robin, spreads picks instead of bursting on the heaviest
+		total := 0
+		for _, u := range candidates {
+			u.currentWeight += u.Weight
+			total += u.Weight
+			if chosen == nil || u.currentWeight > chosen.currentWeight {
+				chosen = u
+			}
+		}
+		chosen.currentWeight -= total
+	default:
+		chosen = candidates[p.next%len(candidates)]
+		p.next++
+	}
+
+	chosen.active++
+	return chosen
+}
+
+// release marks a request picked on u as done
+func (p *upstreamPool) release(u *Upstream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u.active--
+}
+
+// markFailure records a connect error, taking u out of rotation after maxFails in a row
+func (p *upstreamPool) markFailure(u *Upstream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u.fails++
+	if u.fails >= p.maxFails {
+		u.downUntil = time.Now().Add(p.failTimeout)
+		u.fails = 0
+	}
+}
+
+// markSuccess resets the consecutive failure count of u
+func (p *upstreamPool) markSuccess(u *Upstream) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u.fails = 0
+	u.downUntil = time.Time{}
+}
+
+// failover records a connect error on u and picks another upstream to retry on, nil when
+// retry is false, retries are used up or none is left. The picked upstream replaces u,
+// which is then released.
+func (p *upstreamPool) failover(u *Upstream, tried map[*Upstream]bool, retry bool) *Upstream {
+	p.markFailure(u)
+	tried[u] = true
+	if !retry || len(tried) > p.maxRetries {
+		return nil
+	}
+	next := p.pick(tried)
+	if next != nil {
+		p.release(u)
+	}
+	return next
+}
+
+// isConnectError reports whether err happened before the request reached the target,
+// which makes it safe to try another upstream
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// isIdempotentMethod reports whether a request may be sent more than once
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package core
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestNewUpstreamPool(t *testing.T) {
+	pool, err := newUpstreamPool(SysConfigProxyEntry{Target: "http://a:80"})
+	if err != nil || pool != nil {
+		t.Errorf("Expected no pool for a single target, got %v, %v", pool, err)
+	}
+
+	invalid := []SysConfigProxyEntry{
+		{Target: "http://a:80", Targets: []string{"http://b:80"}},
+		{Targets: []string{"http://a:80", "http://b:80"}, TargetWeights: []int{1}},
+		{Targets: []string{"http://a:80"}, LoadBalance: "random"},
+		{Targets: []string{"not a url"}},
+	}
+	for _, entry := range invalid {
+		if _, err := newUpstreamPool(entry); err == nil {
+			t.Errorf("Expected an error for %+v", entry)
+		}
+	}
+}
+
+func pickHosts(pool *upstreamPool, n int) []string {
+	hosts := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		u := pool.pick(nil)
+		hosts = append(hosts, u.URL.Host)
+		pool.release(u)
+	}
+	return hosts
+}
+
+func TestUpstreamPool_Strategies(t *testing.T) {
+	rr, _ := newUpstreamPool(SysConfigProxyEntry{Targets: []string{"http://a", "http://b"}})
+	if got := pickHosts(rr, 4); got[0] != "a" || got[1] != "b" || got[2] != "a" || got[3] != "b" {
+		t.Errorf("Expected round-robin a,b,a,b, got %v", got)
+	}
+
+	weighted, _ := newUpstreamPool(SysConfigProxyEntry{
+		Targets:       []string{"http://a", "http://b"},
+		TargetWeights: []int{3, 1},
+		LoadBalance:   LoadBalanceWeighted,
+	})
+	counts := map[string]int{}
+	for _, h := range pickHosts(weighted, 8) {
+		counts[h]++
+	}
+	if counts["a"] != 6 || counts["b"] != 2 {
+		t.Errorf("Expected a 3:1 split, got %v", counts)
+	}
+
+	leastConn, _ := newUpstreamPool(SysConfigProxyEntry{Targets: []string{"http://a", "http://b"}, LoadBalance: LoadBalanceLeastConn})
+	busy := leastConn.pick(nil) // Held open
+	for i := 0; i < 3; i++ {
+		u := leastConn.pick(nil)
+		if u == busy {
+			t.Errorf("Expected least-conn to avoid the busy upstream %s", busy.URL.Host)
+		}
+		leastConn.release(u)
+	}
+}
+
+func TestUpstreamPool_PassiveHealth(t *testing.T) {
+	pool, _ := newUpstreamPool(SysConfigProxyEntry{Targets: []string{"http://a", "http://b"}, MaxFails: 2, FailTimeout: "1m"})
+	a := pool.upstreams[0]
+
+	pool.markFailure(a)
+	pool.markFailure(a)
+
+	for _, h := range pickHosts(pool, 3) {
+		if h == "a" {
+			t.Fatal("Expected upstream a to be out of rotation after 2 failures")
+		}
+	}
+
+	pool.markSuccess(a)
+	if got := pickHosts(pool, 2); got[0] != "a" && got[1] != "a" {
+		t.Errorf("Expected upstream a back in rotation, got %v", got)
+	}
+}
+
+func TestProxyHandler_FailsOverToHealthyUpstream(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := "http://" + ln.Addr().String()
+	ln.Close()
+
+	pool, err := newUpstreamPool(SysConfigProxyEntry{Targets: []string{dead, healthy.URL}})
+	if err != nil {
+		t.Fatalf("newUpstreamPool failed: %v", err)
+	}
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-failover-config",
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Pool:        pool,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "healthy" {
+		t.Fatalf("Expected the healthy upstream to answer, got %d '%s'", resp.StatusCode, string(body))
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-failover-config").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session.Upstream != healthy.URL || session.Attempts != 2 {
+		t.Errorf("Expected upstream %s after 2 attempts, got %s after %d", healthy.URL, session.Upstream, session.Attempts)
+	}
+}
+
+func TestProxyHandler_WebSocketFailsOverToHealthyUpstream(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteMessage(websocket.TextMessage, []byte("healthy"))
+	}))
+	defer healthy.Close()
+
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	dead := "http://" + ln.Addr().String()
+	ln.Close()
+
+	pool, err := newUpstreamPool(SysConfigProxyEntry{Targets: []string{dead, healthy.URL}, MaxFails: 1})
+	if err != nil {
+		t.Fatalf("newUpstreamPool failed: %v", err)
+	}
+	config := &ProxyConfig{
+		ConfigID:    "test-ws-failover-config",
+		DB:          setupTestDB(t),
+		WsPublishFn: func(topic string, v any) {},
+		Pool:        pool,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(proxy.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial through proxy failed: %v", err)
+	}
+	_, msg, err := conn.ReadMessage()
+	conn.Close()
+	if err != nil || string(msg) != "healthy" {
+		t.Fatalf("Expected the healthy upstream to answer, got %q (%v)", msg, err)
+	}
+
+	s := waitForSession(t, config)
+	if s.Upstream != healthy.URL || s.Attempts != 2 {
+		t.Errorf("Expected upstream %s after 2 attempts, got %s after %d", healthy.URL, s.Upstream, s.Attempts)
+	}
+	// The refused dial counts against the dead upstream like any other request
+	if next := pool.pick(nil); next.URL.String() != healthy.URL {
+		t.Errorf("Expected the dead upstream to be out of rotation, picked %s", next.URL)
+	}
+}
+
+func TestIsIdempotentMethod(t *testing.T) {
+	if !isIdempotentMethod(http.MethodGet) || !isIdempotentMethod(http.MethodPut) {
+		t.Error("Expected GET and PUT to be retried")
+	}
+	if isIdempotentMethod(http.MethodPost) || isIdempotentMethod(http.MethodPatch) {
+		t.Error("Expected POST and PATCH not to be retried")
+	}
+}
@@ -71,11 +71,25 @@ func ExportCurrentProxiesToConfig() error {
 	for _, id := range activeIDs {
 		pc := GlobalVar.GetProxyConfig(id)
 		if pc != nil && GlobalVar.HasProxyServer(id) {
-			activeProxies = append(activeProxies, SysConfigProxyEntry{
-				Listen:          pc.ListenAddr,
-				Target:          pc.TargetURL.String(),
-				TruncateLogBody: pc.TruncateLogBody,
-			})
+			entry := pc.Entry
+			entry.Listen = pc.ListenAddr
+			entry.Target = pc.TargetURL.String()
+			entry.TruncateLogBody = pc.TruncateLogBody
+			entry.Mode = ""
+			if pc.Mode != ProxyModeReverse {
+				entry.Mode = pc.Mode
+			}
+			// Faults may have been changed at runtime
+			entry.Faults = nil
+			if spec := pc.Faults().Spec(); spec != (SysConfigProxyFaults{}) {
+				entry.Faults = &spec
+			}
+			// So may the omitted headers
+			entry.OmitHeaders = nil
+			if names := pc.OmitHeaders(); len(names) > 0 {
+				entry.OmitHeaders = names
+			}
+			activeProxies = append(activeProxies, entry)
 		}
 	}
 
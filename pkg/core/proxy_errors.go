@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Kinds of upstream failures recorded on sessions
+const (
+	UpstreamErrorDNS               = "dns"
+	UpstreamErrorConnectionRefused = "connection_refused"
+	UpstreamErrorTimeout           = "timeout"
+	UpstreamErrorTLS               = "tls"
+	UpstreamErrorClientCancelled   = "client_cancelled"
+	UpstreamErrorAborted           = "aborted"      // Aborted at a breakpoint, the target may never have been called
+	UpstreamErrorNoRecording       = "no_recording" // Replay mode found no recorded session for the request
+	UpstreamErrorOther             = "other"
+)
+
+// StatusClientClosedRequest is recorded when the client went away before the target answered
+const StatusClientClosedRequest = 499
+
+// classifyUpstreamError maps an error from reaching the target to an error kind and the
+// status code answered to the client
+func classifyUpstreamError(ctx context.Context, err error) (string, int) {
+	if errors.Is(err, errBreakpointAborted) {
+		return UpstreamErrorAborted, http.StatusBadGateway
+	}
+	if errors.Is(err, errNoRecording) {
+		return UpstreamErrorNoRecording, http.StatusBadGateway
+	}
+
+	if errors.Is(ctx.Err(), context.Canceled) || errors.Is(err, context.Canceled) {
+		return UpstreamErrorClientCancelled, StatusClientClosedRequest
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return UpstreamErrorDNS, http.StatusBadGateway
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return UpstreamErrorTimeout, http.StatusGatewayTimeout
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return UpstreamErrorConnectionRefused, http.StatusBadGateway
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr) ||
+		errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return UpstreamErrorTLS, http.StatusBadGateway
+	}
+
+	return UpstreamErrorOther, http.StatusBadGateway
+}
+
+// failProxySession answers the client for an exchange the target never completed and
+// closes its session with the error, so it does not stay pending forever
+func failProxySession(
+	config *ProxyConfig,
+	w http.ResponseWriter,
+	r *http.Request,
+	entry *LogEntry,
+	session *ProxySessionRow,
+	err error,
+) {
+	kind, status := classifyUpstreamError(r.Context(), err)
+
+	log.Error().Err(err).Str("error_kind", kind).Str("target_url", entry.RequestURL.String()).Msg("Failed to reach target")
+
+	// Nobody is left to read an answer for a cancelled request
+	if kind != UpstreamErrorClientCancelled {
+		http.Error(w, http.StatusText(status), status)
+	}
+
+	entry.StatusCode = status
+	entry.ErrorKind = kind
+	entry.ErrorMessage = err.Error()
+	entry.Duration = time.Since(entry.Timestamp)
+
+	if config.DB == nil {
+		return
+	}
+	go func(s *ProxySessionRow, e *LogEntry) {
+		s, err := recordSession(config, s, e)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to record failed session in database")
+			return
+		}
+		if s != nil {
+			config.WsPublishFn("sessions", FormatSessionFailed(s))
+			config.WsPublishFn(LiveTopic(s.ID), FormatSessionEnd(s))
+		}
+	}(session, entry)
+}
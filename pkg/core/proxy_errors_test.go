@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClassifyUpstreamError(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		err    error
+		kind   string
+		status int
+	}{
+		{"dns", context.Background(), &net.DNSError{Err: "no such host", Name: "nope.invalid"}, UpstreamErrorDNS, http.StatusBadGateway},
+		{"timeout", context.Background(), fmt.Errorf("dial: %w", context.DeadlineExceeded), UpstreamErrorTimeout, http.StatusGatewayTimeout},
+		{"cancelled", cancelled, errors.New("request aborted"), UpstreamErrorClientCancelled, StatusClientClosedRequest},
+		{"other", context.Background(), errors.New("boom"), UpstreamErrorOther, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		kind, status := classifyUpstreamError(tt.ctx, tt.err)
+		if kind != tt.kind || status != tt.status {
+			t.Errorf("%s: expected %s/%d, got %s/%d", tt.name, tt.kind, tt.status, kind, status)
+		}
+	}
+}
+
+func TestProxyHandler_RecordsUpstreamFailure(t *testing.T) {
+	// Grab a free port and close it so connections are refused
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	deadAddr := ln.Addr().String()
+	ln.Close()
+
+	targetURL, _ := url.Parse("http://" + deadAddr)
+	db := setupTestDB(t)
+
+	published := make(chan map[string]any, 8)
+	config := &ProxyConfig{
+		ConfigID:  "test-failure-config",
+		TargetURL: targetURL,
+		DB:        db,
+		WsPublishFn: func(topic string, v any) {
+			if m, ok := v.(map[string]any); ok {
+				published <- m
+			}
+		},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL + "/down")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", resp.StatusCode)
+	}
+
+	var failedEvent map[string]any
+	timeout := time.After(2 * time.Second)
+	for failedEvent == nil {
+		select {
+		case m := <-published:
+			if m["type"] == "session_failed" {
+				failedEvent = m
+			}
+		case <-timeout:
+			t.Fatal("Expected a session_failed event")
+		}
+	}
+	if failedEvent["error_kind"] != UpstreamErrorConnectionRefused {
+		t.Errorf("Expected error kind %s, got %v", UpstreamErrorConnectionRefused, failedEvent["error_kind"])
+	}
+
+	sessions, err := GetFailedSessions(db, "test-failure-config", 10, 0)
+	if err != nil {
+		t.Fatalf("GetFailedSessions failed: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 failed session, got %d", len(sessions))
+	}
+	if sessions[0].ResponseStatusCode != http.StatusBadGateway || sessions[0].ErrorMessage == "" {
+		t.Errorf("Unexpected failed session: status %d message %q", sessions[0].ResponseStatusCode, sessions[0].ErrorMessage)
+	}
+}
@@ -0,0 +1,228 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// Kinds of faults recorded on sessions
+const (
+	FaultLatency  = "latency"
+	FaultError    = "error"
+	FaultDrop     = "drop"
+	FaultThrottle = "throttle"
+)
+
+// AppliedFault records a fault injected into an exchange
+type AppliedFault struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+// ErrProxyNotFound is returned for a config ID without a loaded proxy
+var ErrProxyNotFound = errors.New("proxy not found")
+
+// errFaultDropped stops the writes of a response whose connection is cut by a drop fault
+var errFaultDropped = errors.New("connection dropped by fault injection")
+
+// FaultInjector decides which faults hit each exchange of a proxy
+type FaultInjector struct {
+	spec          SysConfigProxyFaults
+	latency       time.Duration
+	latencyJitter time.Duration
+	errorStatus   int
+}
+
+// NewFaultInjector validates a fault spec. A nil or empty spec injects nothing.
+func NewFaultInjector(spec *SysConfigProxyFaults) (*FaultInjector, error) {
+	if spec == nil || *spec == (SysConfigProxyFaults{}) {
+		return nil, nil
+	}
+
+	fi := &FaultInjector{spec: *spec, errorStatus: spec.ErrorStatus}
+	var err error
+	if fi.latency, err = parseDurationOption("latency", spec.Latency, 0); err != nil {
+		return nil, err
+	}
+	if fi.latencyJitter, err = parseDurationOption("latency-jitter", spec.LatencyJitter, 0); err != nil {
+		return nil, err
+	}
+	if spec.ErrorPercent < 0 || spec.ErrorPercent > 100 {
+		return nil, fmt.Errorf("invalid 'error-percent': %v, must be 0 to 100", spec.ErrorPercent)
+	}
+	if spec.DropPercent < 0 || spec.DropPercent > 100 {
+		return nil, fmt.Errorf("invalid 'drop-percent': %v, must be 0 to 100", spec.DropPercent)
+	}
+	if fi.errorStatus == 0 {
+		fi.errorStatus = http.StatusServiceUnavailable
+	} else if fi.errorStatus < 400 || fi.errorStatus > 599 {
+		return nil, fmt.Errorf("invalid 'error-status': %d, must be 400 to 599", fi.errorStatus)
+	}
+	if spec.Bandwidth < 0 {
+		return nil, fmt.Errorf("invalid 'bandwidth': %d", spec.Bandwidth)
+	}
+	return fi, nil
+}
+
+// Spec returns the faults this injector was built from
+func (fi *FaultInjector) Spec() SysConfigProxyFaults {
+	if fi == nil {
+		return SysConfigProxyFaults{}
+	}
+	return fi.spec
+}
+
+// delay returns the latency to add to an exchange
+func (fi *FaultInjector) delay() time.Duration {
+	if fi == nil {
+		return 0
+	}
+	d := fi.latency
+	if fi.latencyJitter > 0 {
+		d += rand.N(fi.latencyJitter)
+	}
+	return d
+}
+
+// errorResponse returns the response answered instead of calling the target, or nil
+func (fi *FaultInjector) errorResponse() *http.Response {
+	if fi == nil || !roll(fi.spec.ErrorPercent) {
+		return nil
+	}
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	body := fmt.Sprintf("Fault injected: %d %s\n", fi.errorStatus, http.StatusText(fi.errorStatus))
+	return newMockResponse(fi.errorStatus, header, []byte(body))
+}
+
+// bodyWriter wraps the writer of a response body with the drop and throttle faults that
+// hit this exchange, or returns nil when none do. contentLength is -1 when unknown.
+func (fi *FaultInjector) bodyWriter(w http.ResponseWriter, ctx context.Context, contentLength int64) *faultWriter {
+	if fi == nil {
+		return nil
+	}
+	drop := roll(fi.spec.DropPercent)
+	if !drop && fi.spec.Bandwidth == 0 {
+		return nil
+	}
+
+	fw := &faultWriter{
+		w:         w,
+		rc:        http.NewResponseController(w),
+		ctx:       ctx,
+		dropAfter: dropNever,
+		bandwidth: fi.spec.Bandwidth,
+		start:     time.Now(),
+	}
+	if drop {
+		// Cut halfway through bodies of known length, others after their first chunk
+		fw.dropAfter = dropAfterFirstWrite
+		if contentLength >= 0 {
+			fw.dropAfter = int(contentLength / 2)
+		}
+	}
+	return fw
+}
+
+// roll reports whether an event with the given percent chance happens
+func roll(percent float64) bool {
+	return percent > 0 && rand.Float64()*100 < percent
+}
+
+const (
+	dropNever           = -1
+	dropAfterFirstWrite = -2
+)
+
+// faultWriter writes a response body at most bandwidth bytes per second, stopping with
+// errFaultDropped once dropAfter bytes are written
+type faultWriter struct {
+	w         io.Writer
+	rc        *http.ResponseController
+	ctx       context.Context
+	dropAfter int
+	bandwidth int
+	written   int
+	start     time.Time
+}
+
+func (fw *faultWriter) Write(p []byte) (int, error) {
+	if fw.dropAfter == dropAfterFirstWrite && fw.written > 0 {
+		fw.dropAfter = fw.written
+	}
+
+	total := 0
+	for len(p) > 0 {
+		chunk := p
+		if fw.dropAfter >= 0 {
+			room := fw.dropAfter - fw.written
+			if room <= 0 {
+				return total, errFaultDropped
+			}
+			chunk = chunk[:min(len(chunk), room)]
+		}
+		if fw.bandwidth > 0 {
+			// A tenth of a second worth of bytes at a time keeps the rate smooth
+			chunk = chunk[:min(len(chunk), max(fw.bandwidth/10, 1))]
+		}
+
+		n, err := fw.w.Write(chunk)
+		total += n
+		fw.written += n
+		p = p[n:]
+		if err != nil {
+			return total, err
+		}
+
+		if fw.bandwidth > 0 {
+			_ = fw.rc.Flush()
+			wait := time.Duration(float64(fw.written)/float64(fw.bandwidth)*float64(time.Second)) - time.Since(fw.start)
+			if wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-fw.ctx.Done():
+					return total, fw.ctx.Err()
+				}
+			}
+		}
+	}
+	return total, nil
+}
+
+// drops reports whether the connection is cut once the body is written
+func (fw *faultWriter) drops() bool {
+	return fw != nil && fw.dropAfter != dropNever
+}
+
+// faults returns what this writer injected, for the session
+func (fw *faultWriter) faults() []AppliedFault {
+	if fw == nil {
+		return nil
+	}
+	var applied []AppliedFault
+	if fw.bandwidth > 0 {
+		applied = append(applied, AppliedFault{Type: FaultThrottle, Detail: fmt.Sprintf("%d B/s", fw.bandwidth)})
+	}
+	if fw.drops() {
+		applied = append(applied, AppliedFault{Type: FaultDrop, Detail: fmt.Sprintf("after %d bytes", fw.written)})
+	}
+	return applied
+}
+
+// SetProxyFaults replaces the faults of the proxy for configID until it is restarted
+func SetProxyFaults(configID string, spec *SysConfigProxyFaults) (*FaultInjector, error) {
+	pc := GlobalVar.GetProxyConfig(configID)
+	if pc == nil {
+		return nil, ErrProxyNotFound
+	}
+	faults, err := NewFaultInjector(spec)
+	if err != nil {
+		return nil, err
+	}
+	pc.SetFaults(faults)
+	return faults, nil
+}
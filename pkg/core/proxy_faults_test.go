@@ -0,0 +1,118 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewFaultInjector(t *testing.T) {
+	if fi, err := NewFaultInjector(&SysConfigProxyFaults{}); fi != nil || err != nil {
+		t.Errorf("Expected no injector for an empty spec, got %v %v", fi, err)
+	}
+
+	fi, err := NewFaultInjector(&SysConfigProxyFaults{ErrorPercent: 10})
+	if err != nil || fi.errorStatus != http.StatusServiceUnavailable {
+		t.Errorf("Expected error status to default to 503, got %v %v", fi, err)
+	}
+
+	for _, spec := range []SysConfigProxyFaults{
+		{Latency: "soon"},
+		{LatencyJitter: "-1s"},
+		{ErrorPercent: 101},
+		{DropPercent: -1},
+		{ErrorPercent: 5, ErrorStatus: 200},
+		{Bandwidth: -1},
+	} {
+		if _, err := NewFaultInjector(&spec); err == nil {
+			t.Errorf("Expected %+v to be rejected", spec)
+		}
+	}
+}
+
+func TestProxyHandler_Faults(t *testing.T) {
+	targetCalls := 0
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		targetCalls++
+		w.Write([]byte(strings.Repeat("x", 400)))
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-faults-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	setFaults := func(spec SysConfigProxyFaults) {
+		fi, err := NewFaultInjector(&spec)
+		if err != nil {
+			t.Fatalf("NewFaultInjector failed: %v", err)
+		}
+		config.SetFaults(fi)
+	}
+
+	// Latency and error: the target is not called
+	setFaults(SysConfigProxyFaults{Latency: "50ms", ErrorPercent: 100, ErrorStatus: http.StatusGatewayTimeout})
+	start := time.Now()
+	resp, err := http.Get(proxy.URL + "/error")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusGatewayTimeout || time.Since(start) < 50*time.Millisecond || targetCalls != 0 {
+		t.Errorf("Expected a delayed 504 without calling the target, got %d after %v (%d calls)", resp.StatusCode, time.Since(start), targetCalls)
+	}
+
+	// Throttle: 400 bytes at 1000 B/s take about 400ms
+	setFaults(SysConfigProxyFaults{Bandwidth: 1000})
+	start = time.Now()
+	resp, err = http.Get(proxy.URL + "/throttle")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 400 || time.Since(start) < 300*time.Millisecond {
+		t.Errorf("Expected 400 throttled bytes, got %d after %v", len(body), time.Since(start))
+	}
+
+	// Drop: the connection is cut halfway through the body
+	setFaults(SysConfigProxyFaults{DropPercent: 100})
+	resp, err = http.Get(proxy.URL + "/drop")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err == nil || len(body) != 200 {
+		t.Errorf("Expected a cut body of 200 bytes, got %d (%v)", len(body), err)
+	}
+
+	want := map[string]string{"/error": FaultError, "/throttle": FaultThrottle, "/drop": FaultDrop}
+	var sessions []ProxySessionRow
+	for i := 0; i < 20; i++ {
+		db.Where("config_id = ? AND response_status_code != 0", config.ConfigID).Find(&sessions)
+		if len(sessions) == len(want) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	for _, s := range sessions {
+		var faults []AppliedFault
+		json.Unmarshal(s.AppliedFaults, &faults)
+		if len(faults) == 0 || faults[len(faults)-1].Type != want[s.RequestPath] {
+			t.Errorf("%s: expected a %s fault, got %s", s.RequestPath, want[s.RequestPath], s.AppliedFaults)
+		}
+	}
+}
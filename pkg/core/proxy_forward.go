@@ -0,0 +1,230 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// serveForwardProxy handles requests sent to a forward (explicit) proxy listener
+func serveForwardProxy(config *ProxyConfig, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		serveConnectTunnel(config, w, r)
+		return
+	}
+
+	// Plain HTTP proxy requests carry the target in the absolute request URI
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		http.Error(w, "Bad Request: forward proxy expects an absolute request URI", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Scheme != "http" && r.URL.Scheme != "https" {
+		http.Error(w, "Bad Request: unsupported scheme "+r.URL.Scheme, http.StatusBadRequest)
+		return
+	}
+
+	target := &url.URL{Scheme: r.URL.Scheme, Host: r.URL.Host}
+	serveProxyRequest(config, w, r, target, nil)
+}
+
+// serveConnectTunnel accepts a CONNECT tunnel and intercepts the traffic inside it.
+// TLS is terminated with a leaf certificate minted by the CA, so every request in the
+// tunnel is recorded like any other proxied request before being sent to the real host.
+func serveConnectTunnel(config *ProxyConfig, w http.ResponseWriter, r *http.Request) {
+	authority := r.URL.Host
+	if _, _, err := net.SplitHostPort(authority); err != nil {
+		authority = net.JoinHostPort(authority, "443")
+	}
+
+	clientConn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to hijack client connection for CONNECT")
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if !config.tunnels.add(clientConn) {
+		clientConn.Close()
+		return
+	}
+	defer config.tunnels.remove(clientConn)
+
+	if _, err := io.WriteString(clientConn, "HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		log.Warn().Err(err).Msg("Failed to acknowledge CONNECT")
+		clientConn.Close()
+		return
+	}
+
+	// Sniff the first byte to tell a TLS handshake (0x16) from plain HTTP. A client that
+	// goes silent before the tunnel is set up is dropped.
+	clientConn.SetReadDeadline(time.Now().Add(ConnectHandshakeTimeout))
+	br := bufio.NewReader(clientConn)
+	first, err := br.Peek(1)
+	if err != nil {
+		clientConn.Close()
+		return
+	}
+	tunnelConn := net.Conn(&peekedConn{Conn: clientConn, r: br})
+
+	scheme := "http"
+	var tlsState *tls.ConnectionState
+	if first[0] == 0x16 {
+		if config.CA == nil {
+			log.Error().Str("host", authority).Msg("No certificate authority available for HTTPS interception")
+			clientConn.Close()
+			return
+		}
+		host, _, _ := net.SplitHostPort(authority)
+		tlsConn := tls.Server(tunnelConn, &tls.Config{
+			NextProtos: []string{"http/1.1"},
+			// Only the host the tunnel was opened to is minted for, so a client cannot
+			// have the CA sign any name it likes
+			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if hello.ServerName != "" && !strings.EqualFold(hello.ServerName, host) {
+					return nil, fmt.Errorf("server name %q does not match the tunnel host %q", hello.ServerName, host)
+				}
+				return config.CA.LeafFor(host)
+			},
+		})
+		if err := tlsConn.Handshake(); err != nil {
+			log.Warn().Err(err).Str("host", authority).Msg("TLS handshake with client failed, is the ihpp CA trusted?")
+			clientConn.Close()
+			return
+		}
+		state := tlsConn.ConnectionState()
+		tlsState = &state
+		tunnelConn = tlsConn
+		scheme = "https"
+	}
+	clientConn.SetReadDeadline(time.Time{})
+
+	log.Debug().Str("host", authority).Str("scheme", scheme).Msg("Intercepting CONNECT tunnel")
+
+	// Requests in the tunnel go where it was opened to, whatever their Host header says
+	targetHost := authority
+	if _, port, _ := net.SplitHostPort(authority); (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		targetHost = strings.TrimSuffix(authority, ":"+port)
+	}
+
+	tunnelServer := &http.Server{
+		ReadHeaderTimeout: TunnelReadHeaderTimeout,
+		IdleTimeout:       TunnelIdleTimeout,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target := &url.URL{Scheme: scheme, Host: targetHost}
+			// Record the absolute URL so sessions show the real upstream host
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			// The connection is wrapped, so http.Server cannot see the TLS state itself
+			if r.TLS == nil {
+				r.TLS = tlsState
+			}
+			serveProxyRequest(config, w, r, target, nil)
+		}),
+	}
+	_ = tunnelServer.Serve(newSingleConnListener(tunnelConn))
+}
+
+// tunnelSet tracks hijacked CONNECT tunnel connections, which http.Server does not close
+// on Shutdown or Close
+type tunnelSet struct {
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// add tracks conn, false when the proxy is already stopped
+func (s *tunnelSet) add(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *tunnelSet) remove(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, conn)
+}
+
+// closeAll closes the tracked connections and refuses new ones
+func (s *tunnelSet) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// peekedConn is a net.Conn whose reads go through a bufio.Reader that was used for peeking
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *peekedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
+
+// singleConnListener is a net.Listener serving exactly one already established connection.
+// Accept blocks after the first call until that connection is closed, which lets
+// http.Server.Serve return once the tunnel is done.
+type singleConnListener struct {
+	conn   net.Conn
+	once   sync.Once
+	closed chan struct{}
+}
+
+func newSingleConnListener(conn net.Conn) *singleConnListener {
+	l := &singleConnListener{closed: make(chan struct{})}
+	l.conn = &notifyCloseConn{Conn: conn, onClose: func() { close(l.closed) }}
+	return l
+}
+
+func (l *singleConnListener) Accept() (net.Conn, error) {
+	var conn net.Conn
+	l.once.Do(func() {
+		conn = l.conn
+	})
+	if conn != nil {
+		return conn, nil
+	}
+	<-l.closed
+	return nil, net.ErrClosed
+}
+
+func (l *singleConnListener) Close() error {
+	return nil
+}
+
+func (l *singleConnListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// notifyCloseConn calls onClose the first time the connection is closed
+type notifyCloseConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *notifyCloseConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.onClose)
+	return err
+}
@@ -0,0 +1,280 @@
+package core
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHandler_ConnectInterception(t *testing.T) {
+	// 1. HTTPS target with a certificate the proxy itself must accept
+	mockTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("secret " + r.URL.Path))
+	}))
+	defer mockTarget.Close()
+
+	transport, err := newUpstreamTransport(SysConfigProxyEntry{}, mockTarget.Client().Transport.(*http.Transport).TLSClientConfig)
+	if err != nil {
+		t.Fatalf("newUpstreamTransport failed: %v", err)
+	}
+
+	// 2. Forward proxy with its own CA
+	ca, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-forward-config",
+		Mode:        ProxyModeForward,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		CA:          ca,
+		WsPublishFn: func(topic string, v any) {},
+		Transport:   transport,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	// 3. Client trusting the ihpp CA, using the proxy for everything
+	proxyURL, _ := url.Parse(proxy.URL)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:           http.ProxyURL(proxyURL),
+		TLSClientConfig: &tls.Config{RootCAs: roots},
+	}}
+
+	resp, err := client.Get(mockTarget.URL + "/vault")
+	if err != nil {
+		t.Fatalf("Request through forward proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if string(body) != "secret /vault" {
+		t.Errorf("Expected 'secret /vault', got '%s'", string(body))
+	}
+	if resp.TLS == nil || resp.TLS.PeerCertificates[0].CheckSignatureFrom(ca.Cert) != nil {
+		t.Error("Expected client to see a certificate minted by the ihpp CA")
+	}
+
+	// 4. The decrypted exchange is recorded with the real upstream URL
+	var session ProxySessionRow
+	var found bool
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-forward-config").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			found = true
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !found {
+		t.Fatalf("Intercepted session not recorded, last status %d", session.ResponseStatusCode)
+	}
+	if session.RequestURLFull != mockTarget.URL+"/vault" {
+		t.Errorf("Expected RequestURLFull %s/vault, got %s", mockTarget.URL, session.RequestURLFull)
+	}
+	if string(session.ResponseBody) != "secret /vault" {
+		t.Errorf("Expected captured body 'secret /vault', got '%s'", string(session.ResponseBody))
+	}
+}
+
+func TestProxyHandler_ForwardPlainHTTP(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Connection") != "" {
+			t.Errorf("Expected Proxy-Connection to be stripped")
+		}
+		w.Write([]byte("plain " + r.URL.RawQuery))
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-forward-plain",
+		Mode:        ProxyModeForward,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+
+	resp, err := client.Get(mockTarget.URL + "/items?page=2")
+	if err != nil {
+		t.Fatalf("Request through forward proxy failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "plain page=2" {
+		t.Errorf("Expected 'plain page=2', got '%s'", string(body))
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-forward-plain").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session.RequestURLFull != mockTarget.URL+"/items?page=2" {
+		t.Errorf("Expected RequestURLFull %s/items?page=2, got %s", mockTarget.URL, session.RequestURLFull)
+	}
+	if session.RequestPath != "/items" {
+		t.Errorf("Expected RequestPath /items, got %s", session.RequestPath)
+	}
+}
+
+func TestProxyHandler_ConnectIgnoresTunnelHost(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("target " + r.URL.Path))
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-forward-tunnel-host",
+		Mode:        ProxyModeForward,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	br := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", targetURL.Host, targetURL.Host)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT failed: %v", err)
+	}
+
+	// A Host header naming another server must not redirect the tunnel
+	fmt.Fprint(conn, "GET /inside HTTP/1.1\r\nHost: elsewhere.invalid\r\n\r\n")
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatalf("Request in tunnel failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "target /inside" {
+		t.Errorf("Expected the CONNECT target to answer, got %d %q", resp.StatusCode, body)
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", config.ConfigID).First(&session).Error; err == nil && session.ResponseStatusCode != 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session.RequestURLFull != mockTarget.URL+"/inside" {
+		t.Errorf("Expected RequestURLFull %s/inside, got %s", mockTarget.URL, session.RequestURLFull)
+	}
+}
+
+func TestProxyHandler_ForwardRejectsOriginForm(t *testing.T) {
+	config := &ProxyConfig{Mode: ProxyModeForward, TargetURL: &url.URL{}, WsPublishFn: func(string, any) {}}
+
+	req := httptest.NewRequest("GET", "/just/a/path", nil)
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400, got %d", w.Code)
+	}
+}
+
+func TestProxyHandler_ConnectTunnelsClosedOnStop(t *testing.T) {
+	config := &ProxyConfig{
+		ConfigID:    "test-forward-stop-config",
+		Mode:        ProxyModeForward,
+		TargetURL:   &url.URL{},
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "CONNECT example.com:80 HTTP/1.1\r\nHost: example.com:80\r\n\r\n")
+	br := bufio.NewReader(conn)
+	if resp, err := http.ReadResponse(br, nil); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the tunnel to be established, got %v", err)
+	}
+
+	// The tunnel is hijacked, so only the proxy's own tracking can close it
+	config.tunnels.closeAll()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := br.ReadByte(); err != io.EOF {
+		t.Errorf("Expected the tunnel to be closed, got %v", err)
+	}
+	if config.tunnels.add(conn) {
+		t.Error("Expected no new tunnels once the proxy is stopped")
+	}
+}
+
+func TestProxyHandler_ConnectRejectsOtherServerName(t *testing.T) {
+	ca, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+	config := &ProxyConfig{
+		ConfigID:    "test-forward-sni-config",
+		Mode:        ProxyModeForward,
+		TargetURL:   &url.URL{},
+		CA:          ca,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	handshake := func(serverName string) error {
+		conn, err := net.Dial("tcp", proxy.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com:443\r\n\r\n")
+		br := bufio.NewReader(conn)
+		if resp, err := http.ReadResponse(br, nil); err != nil || resp.StatusCode != http.StatusOK {
+			t.Fatalf("CONNECT failed: %v", err)
+		}
+		return tls.Client(conn, &tls.Config{ServerName: serverName, RootCAs: roots}).Handshake()
+	}
+
+	if err := handshake("example.com"); err != nil {
+		t.Errorf("Expected a leaf for the tunnel host, got %v", err)
+	}
+	if err := handshake("bank.test"); err == nil {
+		t.Error("Expected no leaf for a server name other than the tunnel host")
+	}
+	if _, ok := ca.leaves["bank.test"]; ok {
+		t.Error("Expected nothing minted for the other server name")
+	}
+}
@@ -0,0 +1,309 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+)
+
+// ruleMock is the mock an exchange hit while applying rules
+type ruleMock struct {
+	rule AppliedRule
+	mock *compiledMock
+}
+
+type compiledMock struct {
+	status  int
+	headers map[string][]*template.Template
+	body    *template.Template // nil for base64 bodies
+	raw     []byte
+}
+
+// mockTemplateData is what mock templates can use, e.g. {{.Query.Get "id"}} or {{.JSON "$.user.name"}}
+type mockTemplateData struct {
+	Method string
+	URL    string
+	Path   string
+	Query  url.Values
+	Header http.Header
+	Body   string
+}
+
+// JSON returns the value at a JSONPath of a JSON request body, or nil
+func (d *mockTemplateData) JSON(path string) (any, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(strings.NewReader(d.Body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, nil
+	}
+	return getJSONPath(doc, steps), nil
+}
+
+func compileMock(action RuleAction) (*compiledMock, error) {
+	if action.Status != 0 && (action.Status < 100 || action.Status > 999) {
+		return nil, fmt.Errorf("invalid status %d", action.Status)
+	}
+
+	m := &compiledMock{status: action.Status, headers: make(map[string][]*template.Template, len(action.Headers))}
+	if m.status == 0 {
+		m.status = http.StatusOK
+	}
+
+	addHeader := func(name, value string) error {
+		tmpl, err := template.New(name).Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid template for header %s: %w", name, err)
+		}
+		name = http.CanonicalHeaderKey(name)
+		m.headers[name] = append(m.headers[name], tmpl)
+		return nil
+	}
+	for name, value := range action.Headers {
+		if err := addHeader(name, value); err != nil {
+			return nil, err
+		}
+	}
+	for name, values := range action.HeaderValues {
+		for _, value := range values {
+			if err := addHeader(name, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	switch action.BodyEncoding {
+	case "":
+		tmpl, err := template.New("body").Parse(action.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body template: %w", err)
+		}
+		m.body = tmpl
+	case "base64":
+		raw, err := base64.StdEncoding.DecodeString(action.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 body: %w", err)
+		}
+		m.raw = raw
+	default:
+		return nil, fmt.Errorf("invalid body_encoding %q", action.BodyEncoding)
+	}
+
+	return m, nil
+}
+
+// response renders the mock for a request. Template errors are answered with a 500
+// so a broken mock is visible in the session instead of failing silently.
+func (m *compiledMock) response(method string, reqURL *url.URL, header http.Header, body []byte) *http.Response {
+	data := &mockTemplateData{
+		Method: method,
+		URL:    reqURL.String(),
+		Path:   reqURL.Path,
+		Query:  reqURL.Query(),
+		Header: header,
+		Body:   string(body),
+	}
+
+	var buf bytes.Buffer
+	respHeader := make(http.Header, len(m.headers))
+	for name, tmpls := range m.headers {
+		for _, tmpl := range tmpls {
+			buf.Reset()
+			if err := tmpl.Execute(&buf, data); err != nil {
+				return mockTemplateError(err)
+			}
+			respHeader.Add(name, buf.String())
+		}
+	}
+
+	respBody := m.raw
+	if m.body != nil {
+		buf.Reset()
+		if err := m.body.Execute(&buf, data); err != nil {
+			return mockTemplateError(err)
+		}
+		respBody = bytes.Clone(buf.Bytes())
+	}
+
+	return newMockResponse(m.status, respHeader, respBody)
+}
+
+func mockTemplateError(err error) *http.Response {
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	return newMockResponse(http.StatusInternalServerError, header, []byte("mock template failed: "+err.Error()))
+}
+
+func newMockResponse(status int, header http.Header, body []byte) *http.Response {
+	header.Del("Content-Length")
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
+
+func getJSONPath(node any, steps []jsonPathStep) any {
+	for _, step := range steps {
+		if step.isIndex {
+			list, ok := node.([]any)
+			if !ok || step.index >= len(list) {
+				return nil
+			}
+			node = list[step.index]
+			continue
+		}
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = obj[step.key]
+	}
+	return node
+}
+
+// ============================================================
+// Mocks from recorded traffic
+// ============================================================
+
+// MockRuleFromSession builds a mock rule replaying the response of a recorded session
+func MockRuleFromSession(db *gorm.DB, configID string, sessionID string) (*ProxyRuleRow, error) {
+	session, err := GetSessionByID(db, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordedResponseLoss(session.Redactions, session.ResponseBody, session.ResponseBodySize); err != nil {
+		return nil, fmt.Errorf("cannot mock session %s: %w", sessionID, err)
+	}
+	return newMockRule(configID, session.RequestMethod, session.RequestPath,
+		session.ResponseStatusCode, session.ResponseHeaders, session.ResponseBody)
+}
+
+// MockRuleFromBookmark builds a mock rule replaying the response saved in a bookmark
+func MockRuleFromBookmark(db *gorm.DB, configID string, bookmarkID string) (*ProxyRuleRow, error) {
+	bookmark, err := GetBookmark(db, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordedResponseLoss(bookmark.Redactions, bookmark.ResponseBody, bookmark.ResponseBodySize); err != nil {
+		return nil, fmt.Errorf("cannot mock bookmark %s: %w", bookmarkID, err)
+	}
+	return newMockRule(configID, bookmark.RequestMethod, bookmark.RequestPath,
+		bookmark.ResponseStatusCode, bookmark.ResponseHeaders, bookmark.ResponseBody)
+}
+
+// ErrAlteredRecording is returned for recorded responses that were not stored as the
+// target sent them, so serving them again would not be faithful
+var ErrAlteredRecording = errors.New("the recorded response was not stored as received")
+
+// recordedResponseLoss tells why a recorded response differs from what the target sent:
+// its body was cut by the capture limit or dropped by a no-bodies capture, secrets were
+// masked or headers omitted
+func recordedResponseLoss(redactionsJSON datatypes.JSON, body []byte, size int) error {
+	if len(body) < size {
+		return fmt.Errorf("%w: %d of %d body bytes were stored", ErrAlteredRecording, len(body), size)
+	}
+	var redactions []Redaction
+	if len(redactionsJSON) > 0 {
+		if err := json.Unmarshal(redactionsJSON, &redactions); err != nil {
+			return fmt.Errorf("failed to parse recorded redactions: %w", err)
+		}
+	}
+	for _, m := range redactions {
+		if m.Location != RedactResponseHeaders && m.Location != RedactResponseBody {
+			continue
+		}
+		if m.Rule == RedactionRuleOmit {
+			return fmt.Errorf("%w: header %s was omitted", ErrAlteredRecording, m.Target)
+		}
+		return fmt.Errorf("%w: %s %s in the %s was redacted", ErrAlteredRecording, m.Rule, m.Target, strings.ReplaceAll(m.Location, "_", " "))
+	}
+	return nil
+}
+
+func newMockRule(configID, method, path string, status int, headersJSON datatypes.JSON, body []byte) (*ProxyRuleRow, error) {
+	var recorded http.Header
+	if len(headersJSON) > 0 {
+		if err := json.Unmarshal(headersJSON, &recorded); err != nil {
+			return nil, fmt.Errorf("failed to parse recorded headers: %w", err)
+		}
+	}
+
+	action := RuleAction{Type: RuleActionMock, Status: status}
+	for name, values := range recorded {
+		switch http.CanonicalHeaderKey(name) {
+		case "Content-Length", "Date", "Transfer-Encoding", "Connection", "Keep-Alive":
+			continue
+		}
+		// Recorded values are literal, keep them from being read as templates
+		escaped := make([]string, len(values))
+		for i, v := range values {
+			escaped[i] = escapeTemplate(v)
+		}
+		switch len(escaped) {
+		case 0:
+		case 1:
+			if action.Headers == nil {
+				action.Headers = make(map[string]string)
+			}
+			action.Headers[name] = escaped[0]
+		default:
+			// Values such as Set-Cookie cannot be joined into one
+			if action.HeaderValues == nil {
+				action.HeaderValues = make(map[string][]string)
+			}
+			action.HeaderValues[name] = escaped
+		}
+	}
+
+	if utf8.Valid(body) {
+		action.Body = escapeTemplate(string(body))
+	} else {
+		action.Body = base64.StdEncoding.EncodeToString(body)
+		action.BodyEncoding = "base64"
+	}
+	if action.Status == 0 {
+		action.Status = http.StatusOK
+	}
+
+	rule := &ProxyRuleRow{
+		ConfigID: configID,
+		Name:     fmt.Sprintf("mock %s %s", method, path),
+		Enabled:  true,
+		Phase:    RulePhaseRequest,
+		Match: RuleMatch{
+			Methods:   []string{method},
+			PathRegex: "^" + regexp.QuoteMeta(path) + "$",
+		},
+		Actions: []RuleAction{action},
+	}
+	return rule, nil
+}
+
+// escapeTemplate quotes template delimiters so s renders as itself
+func escapeTemplate(s string) string {
+	if !strings.Contains(s, "{{") {
+		return s
+	}
+	return strings.ReplaceAll(s, "{{", `{{"{{"}}`)
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHandler_Mock(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Target must not be called for mocked requests, got %s", r.URL.Path)
+	}))
+	defer mockTarget.Close()
+
+	rules, err := compileRules([]ProxyRuleRow{{
+		ID: "mock", Name: "user stub", Enabled: true, Phase: RulePhaseRequest,
+		Match: RuleMatch{Methods: []string{"POST"}, PathRegex: "^/users/[0-9]+$"},
+		Actions: []RuleAction{{
+			Type:    RuleActionMock,
+			Status:  http.StatusCreated,
+			Headers: map[string]string{"Content-Type": "application/json", "X-Path": "{{.Path}}"},
+			Body:    `{"page":"{{.Query.Get "page"}}","name":"{{.JSON "$.name"}}"}`,
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("compileRules failed: %v", err)
+	}
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-mock-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	config.SetRules(rules)
+
+	req := httptest.NewRequest("POST", "/users/42?page=3", strings.NewReader(`{"name":"ada"}`))
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, req)
+
+	if w.Code != http.StatusCreated || w.Header().Get("X-Path") != "/users/42" {
+		t.Errorf("Expected mocked 201 with X-Path, got %d %v", w.Code, w.Header())
+	}
+	if w.Body.String() != `{"page":"3","name":"ada"}` {
+		t.Errorf("Unexpected mock body: %s", w.Body.String())
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-mock-config").First(&session).Error; err == nil && session.ResponseStatusCode != 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !session.Mocked || session.Upstream != "" || session.ResponseStatusCode != http.StatusCreated {
+		t.Errorf("Expected a mocked session, got mocked=%v upstream=%q status=%d", session.Mocked, session.Upstream, session.ResponseStatusCode)
+	}
+}
+
+func TestMockRuleFromSession(t *testing.T) {
+	db := setupTestDB(t)
+	session, err := CreateProxySession(db, &LogEntry{
+		ConfigID:        "cfg",
+		RequestMethod:   "GET",
+		RequestURL:      &url.URL{Path: "/tpl/{{x}}"},
+		RequestHeaders:  http.Header{},
+		StatusCode:      http.StatusTeapot,
+		ResponseHeaders: http.Header{"Content-Type": {"text/plain"}, "Content-Length": {"11"}, "Set-Cookie": {"a=1; Path=/", "b={{2}}"}},
+		ResponseBody:    []byte("raw {{.Path}}"),
+	})
+	if err != nil {
+		t.Fatalf("CreateProxySession failed: %v", err)
+	}
+
+	rule, err := MockRuleFromSession(db, "cfg", session.ID)
+	if err != nil {
+		t.Fatalf("MockRuleFromSession failed: %v", err)
+	}
+	if rule.Match.PathRegex != `^/tpl/\{\{x\}\}$` || rule.Match.Methods[0] != "GET" {
+		t.Errorf("Unexpected match: %+v", rule.Match)
+	}
+	if _, ok := rule.Actions[0].Headers["Content-Length"]; ok {
+		t.Error("Expected Content-Length to be dropped")
+	}
+
+	// The recorded body is replayed literally, not as a template
+	cr, err := compileRule(rule)
+	if err != nil {
+		t.Fatalf("compileRule failed: %v", err)
+	}
+	resp := cr.actions[0].mock.response("GET", &url.URL{Path: "/tpl/{{x}}"}, http.Header{}, nil)
+	body := make([]byte, 64)
+	n, _ := resp.Body.Read(body)
+	if resp.StatusCode != http.StatusTeapot || string(body[:n]) != "raw {{.Path}}" {
+		t.Errorf("Expected replayed 418 'raw {{.Path}}', got %d %q", resp.StatusCode, body[:n])
+	}
+	if cookies := resp.Header.Values("Set-Cookie"); len(cookies) != 2 || cookies[0] != "a=1; Path=/" || cookies[1] != "b={{2}}" {
+		t.Errorf("Expected both Set-Cookie values sent apart, got %q", cookies)
+	}
+
+	// Binary bodies are kept as base64
+	binary, _ := CreateProxySession(db, &LogEntry{
+		RequestMethod: "GET", RequestURL: &url.URL{Path: "/img"}, RequestHeaders: http.Header{},
+		StatusCode: http.StatusOK, ResponseBody: []byte{0xff, 0x00, 0xfe},
+	})
+	rule, err = MockRuleFromSession(db, "cfg", binary.ID)
+	if err != nil || rule.Actions[0].BodyEncoding != "base64" || rule.Actions[0].Body != "/wD+" {
+		t.Errorf("Expected base64 body, got %+v (%v)", rule.Actions[0], err)
+	}
+}
+
+func TestMockRuleFromSession_AlteredRecording(t *testing.T) {
+	db := setupTestDB(t)
+	red, err := newRedactor(&SysConfigRedaction{Headers: []string{"Set-Cookie"}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	for name, entry := range map[string]*LogEntry{
+		"redacted": {
+			ResponseHeaders: http.Header{"Set-Cookie": {"sid=1"}},
+			redactor:        red,
+		},
+		"omitted": {
+			ResponseHeaders: http.Header{"X-Upstream": {"node-7"}},
+			headersToOmit:   newHeaderOmission([]string{"x-upstream"}),
+		},
+		"no-bodies": {
+			ResponseBody:  []byte("hello"),
+			CaptureAction: CaptureNoBodies,
+		},
+	} {
+		entry.ConfigID, entry.RequestMethod, entry.RequestURL = "cfg", "GET", &url.URL{Path: "/" + name}
+		entry.RequestHeaders, entry.StatusCode = http.Header{}, http.StatusOK
+		session, err := CreateProxySession(db, entry)
+		if err != nil {
+			t.Fatalf("%s: CreateProxySession failed: %v", name, err)
+		}
+		if _, err := MockRuleFromSession(db, "cfg", session.ID); !errors.Is(err, ErrAlteredRecording) {
+			t.Errorf("%s: expected ErrAlteredRecording, got %v", name, err)
+		}
+	}
+}
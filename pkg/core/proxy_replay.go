@@ -0,0 +1,148 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+
+	"gorm.io/gorm"
+)
+
+// errNoRecording is the failure of a replayed request no recorded session matches
+var errNoRecording = errors.New("no recorded session matches this request")
+
+// replayer answers requests from the sessions recorded for a set of configs
+type replayer struct {
+	configIDs []string
+	matchBody bool // Also match the request body, by hash
+}
+
+func newReplayer(configID string, entry SysConfigProxyEntry) *replayer {
+	configIDs := entry.ReplayFrom
+	if len(configIDs) == 0 {
+		configIDs = []string{configID}
+	}
+	return &replayer{configIDs: configIDs, matchBody: entry.ReplayMatchBody}
+}
+
+// find returns the latest completed session recorded for a request with the same method,
+// path and query (in any parameter order), and body when matchBody is set. Recordings with
+// masked secrets in their URL match storedURL, the request URL as redacted by the proxy.
+// Sessions whose response was not stored as received are skipped, see recordedResponseLoss.
+func (rp *replayer) find(db *gorm.DB, method string, reqURL, storedURL *url.URL, bodyHash string) (*ProxySessionRow, error) {
+	query := db.Model(&ProxySessionRow{}).
+		Select("id", "request_path", "request_query", "redactions").
+		Where("config_id IN ? AND request_method = ? AND request_path IN ?", rp.configIDs, method, []string{reqURL.Path, storedURL.Path}).
+		// Only exchanges the target answered and whose whole body was kept, not pending, failed,
+		// upgraded, truncated or stored without bodies ones
+		Where("response_status_code >= 200 AND error_kind = '' AND COALESCE(length(response_body), 0) = response_body_size").
+		Where("mocked = ? AND replayed_from = ''", false)
+	if rp.matchBody {
+		query = query.Where("request_body_hash = ?", bodyHash)
+	}
+
+	var candidates []ProxySessionRow
+	if err := query.Order("timestamp DESC").Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	wantQuery, storedQuery := canonicalQuery(reqURL.RawQuery), canonicalQuery(storedURL.RawQuery)
+	for _, candidate := range candidates {
+		query := canonicalQuery(candidate.RequestQuery)
+		asReceived := candidate.RequestPath == reqURL.Path && query == wantQuery
+		asStored := candidate.RequestPath == storedURL.Path && query == storedQuery
+		if !asReceived && !asStored {
+			continue
+		}
+		// Masked values or omitted headers would be served as they were stored
+		if recordedResponseLoss(candidate.Redactions, nil, 0) == nil {
+			return GetSessionByID(db, candidate.ID)
+		}
+	}
+	return nil, errNoRecording
+}
+
+// replayResponse answers an exchange from the latest matching recorded session
+func (c *ProxyConfig) replayResponse(entry *LogEntry) (*http.Response, error) {
+	if c.DB == nil || c.Replay == nil {
+		return nil, errNoRecording
+	}
+	// Recordings are stored with secrets masked, so the request is also matched masked
+	var marks []Redaction
+	storedURL := c.Redact.redactURL(entry.RequestURL, &marks)
+	session, err := c.Replay.find(c.DB, entry.RequestMethod, entry.RequestURL, storedURL, entry.RequestBodyHash)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Replay.response(session)
+	if err != nil {
+		return nil, err
+	}
+	entry.ReplayedFrom = session.ID
+	entry.Upstream = ""
+	return resp, nil
+}
+
+// response rebuilds the response recorded with a session
+func (rp *replayer) response(session *ProxySessionRow) (*http.Response, error) {
+	header := http.Header{}
+	if len(session.ResponseHeaders) > 0 {
+		if err := json.Unmarshal(session.ResponseHeaders, &header); err != nil {
+			return nil, err
+		}
+	}
+	resp := newMockResponse(session.ResponseStatusCode, header, session.ResponseBody)
+	if trailers, err := session.ParseResponseTrailers(); err == nil && len(trailers) > 0 {
+		resp.Trailer = trailers
+	}
+	return resp, nil
+}
+
+// canonicalQuery sorts the parameters of a raw query so equal queries compare equal
+func canonicalQuery(rawQuery string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// hashBody returns the hex SHA-256 of a body, or "" when there is none
+func hashBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// requestBodyHash returns the body hash stored with a session, computing it when the
+// proxy did not and the whole body was kept
+func requestBodyHash(entry *LogEntry) string {
+	if entry.RequestBodyHash != "" || entry.RequestBodyTruncated {
+		return entry.RequestBodyHash
+	}
+	return hashBody(entry.RequestBody)
+}
+
+// drainStreamedBody reads what is left of a streamed request body, so it is still recorded
+// and its hash is known when the exchange is answered without the target
+func drainStreamedBody(r *http.Request, entry *LogEntry, capture *captureBuffer, h hash.Hash) {
+	if capture == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, r.Body)
+	entry.RequestBodyHash = streamedBodyHash(capture, h)
+}
+
+func streamedBodyHash(capture *captureBuffer, h hash.Hash) string {
+	if capture.Size() == 0 {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,196 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHandler_Replay(t *testing.T) {
+	db := setupTestDB(t)
+	recorded, err := CreateProxySession(db, &LogEntry{
+		ConfigID:        "recorded-config",
+		RequestMethod:   "POST",
+		RequestURL:      &url.URL{Path: "/todos", RawQuery: "b=2&a=1"},
+		RequestHeaders:  http.Header{},
+		RequestBody:     []byte(`{"title":"fly"}`),
+		StatusCode:      http.StatusCreated,
+		ResponseHeaders: http.Header{"Content-Type": {"application/json"}, "Content-Length": {"99"}},
+		ResponseBody:    []byte(`{"id":1}`),
+	})
+	if err != nil {
+		t.Fatalf("CreateProxySession failed: %v", err)
+	}
+
+	config := &ProxyConfig{
+		ConfigID:    "replay-config",
+		Mode:        ProxyModeReplay,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Replay:      newReplayer("replay-config", SysConfigProxyEntry{ReplayFrom: []string{"recorded-config"}, ReplayMatchBody: true}),
+	}
+	handler := NewProxyHandler(config)
+
+	// Query parameters match in any order
+	req := httptest.NewRequest("POST", "/todos?a=1&b=2", strings.NewReader(`{"title":"fly"}`))
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"id":1}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the recorded 201, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+
+	// A different body does not match when bodies are matched
+	req = httptest.NewRequest("POST", "/todos?a=1&b=2", strings.NewReader(`{"title":"sail"}`))
+	w = httptest.NewRecorder()
+	handler(w, req)
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 without a recording, got %d", w.Code)
+	}
+
+	sessions := waitForSessions(t, config, 2)
+	for _, s := range sessions {
+		switch s.ResponseStatusCode {
+		case http.StatusCreated:
+			if s.ReplayedFrom != recorded.ID || s.Upstream != "" {
+				t.Errorf("Expected a session replayed from %s, got %q (upstream %q)", recorded.ID, s.ReplayedFrom, s.Upstream)
+			}
+		case http.StatusBadGateway:
+			if s.ErrorKind != UpstreamErrorNoRecording {
+				t.Errorf("Expected error kind %s, got %s", UpstreamErrorNoRecording, s.ErrorKind)
+			}
+		}
+	}
+}
+
+func TestProxyHandler_ReplayFallback(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("live"))
+	}))
+	targetURL, _ := url.Parse(target.URL)
+
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "fallback-config",
+		Mode:        ProxyModeFallback,
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Replay:      newReplayer("fallback-config", SysConfigProxyEntry{}),
+	}
+	handler := NewProxyHandler(config)
+
+	// Recorded while the target is up
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Body.String() != "live" {
+		t.Fatalf("Expected the live answer, got %q", w.Body.String())
+	}
+	waitForSessions(t, config, 1)
+
+	// Replayed once it is gone
+	target.Close()
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "live" {
+		t.Errorf("Expected the recorded answer, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Requests never recorded still fail
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/other", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for an unrecorded request, got %d", w.Code)
+	}
+}
+
+// waitForSessions polls until n sessions of the config are finished
+func waitForSessions(t *testing.T, config *ProxyConfig, n int) []ProxySessionRow {
+	t.Helper()
+	var sessions []ProxySessionRow
+	for i := 0; i < 20; i++ {
+		config.DB.Where("config_id = ? AND response_status_code != 0", config.ConfigID).Find(&sessions)
+		if len(sessions) >= n {
+			return sessions
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("Expected %d finished sessions, got %d", n, len(sessions))
+	return nil
+}
+
+func TestProxyHandler_ReplaySkipsAlteredRecordings(t *testing.T) {
+	db := setupTestDB(t)
+	record := func(entry *LogEntry) *ProxySessionRow {
+		entry.ConfigID, entry.RequestMethod, entry.RequestURL = "recorded-config", "GET", &url.URL{Path: "/me"}
+		entry.RequestHeaders, entry.StatusCode = http.Header{}, http.StatusOK
+		s, err := CreateProxySession(db, entry)
+		if err != nil {
+			t.Fatalf("CreateProxySession failed: %v", err)
+		}
+		return s
+	}
+	red, err := newRedactor(&SysConfigRedaction{Headers: []string{"Set-Cookie"}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	clean := record(&LogEntry{Timestamp: time.Now().Add(-time.Hour), ResponseBody: []byte("clean")})
+	// Newer, but stored with a masked cookie or an omitted header
+	record(&LogEntry{Timestamp: time.Now().Add(-time.Minute), ResponseBody: []byte("redacted"),
+		ResponseHeaders: http.Header{"Set-Cookie": {"sid=1"}}, redactor: red})
+	record(&LogEntry{Timestamp: time.Now(), ResponseBody: []byte("omitted"),
+		ResponseHeaders: http.Header{"X-Upstream": {"node-7"}}, headersToOmit: newHeaderOmission([]string{"X-Upstream"})})
+
+	config := &ProxyConfig{
+		ConfigID:    "replay-altered-config",
+		Mode:        ProxyModeReplay,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Replay:      newReplayer("replay-altered-config", SysConfigProxyEntry{ReplayFrom: []string{"recorded-config"}}),
+	}
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, httptest.NewRequest("GET", "/me", nil))
+	if w.Body.String() != "clean" {
+		t.Errorf("Expected the unaltered recording %s to be replayed, got %d %q", clean.ID, w.Code, w.Body.String())
+	}
+}
+
+func TestProxyHandler_ReplayMatchesRedactedURL(t *testing.T) {
+	db := setupTestDB(t)
+	red, err := newRedactor(&SysConfigRedaction{Params: []string{"token"}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	if _, err := CreateProxySession(db, &LogEntry{
+		ConfigID:       "replay-redacted-config",
+		RequestMethod:  "GET",
+		RequestURL:     &url.URL{Path: "/feed", RawQuery: "token=s3cr3t&page=2"},
+		RequestHeaders: http.Header{},
+		StatusCode:     http.StatusOK,
+		ResponseBody:   []byte("page 2"),
+		redactor:       red,
+	}); err != nil {
+		t.Fatalf("CreateProxySession failed: %v", err)
+	}
+
+	config := &ProxyConfig{
+		ConfigID:    "replay-redacted-config",
+		Mode:        ProxyModeReplay,
+		TargetURL:   &url.URL{},
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Redact:      red,
+		Replay:      newReplayer("replay-redacted-config", SysConfigProxyEntry{}),
+	}
+	// The recording only has the masked token, which the request is matched against too
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, httptest.NewRequest("GET", "/feed?page=2&token=another", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "page 2" {
+		t.Errorf("Expected the recording with a masked token to be replayed, got %d %q", w.Code, w.Body.String())
+	}
+}
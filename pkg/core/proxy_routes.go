@@ -0,0 +1,113 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ProxyRoute is a compiled SysConfigProxyRoute
+type ProxyRoute struct {
+	Name        string
+	PathPrefix  string
+	Host        string
+	Headers     map[string]string
+	Target      *url.URL
+	StripPrefix bool
+}
+
+// compileRoutes validates route entries and parses their targets
+func compileRoutes(entries []SysConfigProxyRoute) ([]*ProxyRoute, error) {
+	routes := make([]*ProxyRoute, 0, len(entries))
+	for i, e := range entries {
+		name := e.Name
+		if name == "" {
+			name = fmt.Sprintf("route-%d", i+1)
+		}
+
+		target, err := url.Parse(e.Target)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("invalid target URL for route %s: %s", name, e.Target)
+		}
+		if e.PathPrefix != "" && !strings.HasPrefix(e.PathPrefix, "/") {
+			return nil, fmt.Errorf("path-prefix of route %s must start with '/': %s", name, e.PathPrefix)
+		}
+		if e.StripPrefix && e.PathPrefix == "" {
+			return nil, fmt.Errorf("route %s sets strip-prefix without a path-prefix", name)
+		}
+
+		routes = append(routes, &ProxyRoute{
+			Name:        name,
+			PathPrefix:  e.PathPrefix,
+			Host:        strings.ToLower(e.Host),
+			Headers:     e.Headers,
+			Target:      target,
+			StripPrefix: e.StripPrefix,
+		})
+	}
+	return routes, nil
+}
+
+// matchRoute returns the first route matching r, or nil
+func matchRoute(routes []*ProxyRoute, r *http.Request) *ProxyRoute {
+	for _, route := range routes {
+		if route.matches(r) {
+			return route
+		}
+	}
+	return nil
+}
+
+// matches reports whether r satisfies every matcher set on the route
+func (rt *ProxyRoute) matches(r *http.Request) bool {
+	if rt.PathPrefix != "" && !hasPathPrefix(r.URL.Path, rt.PathPrefix) {
+		return false
+	}
+	if rt.Host != "" && !matchHost(rt.Host, r.Host) {
+		return false
+	}
+	for name, value := range rt.Headers {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// upstreamPath returns the path to request on the target for a client path
+func (rt *ProxyRoute) upstreamPath(path string) string {
+	if !rt.StripPrefix {
+		return path
+	}
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(rt.PathPrefix, "/"))
+	if !strings.HasPrefix(rest, "/") {
+		rest = "/" + rest
+	}
+	return rest
+}
+
+// hasPathPrefix matches whole path segments, so /api matches /api and /api/users but not /apis
+func hasPathPrefix(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" {
+		return true
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// matchHost compares a request host with a pattern, which may start with "*." to match subdomains.
+// The port of the request host is ignored unless the pattern has one.
+func matchHost(pattern, host string) bool {
+	host = strings.ToLower(host)
+	if !strings.Contains(pattern, ":") {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return host == pattern
+}
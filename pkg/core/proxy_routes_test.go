@@ -0,0 +1,143 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCompileRoutes(t *testing.T) {
+	routes, err := compileRoutes([]SysConfigProxyRoute{
+		{PathPrefix: "/api", Target: "http://localhost:9000"},
+		{Name: "admin", Host: "Admin.Local", Target: "http://localhost:9001"},
+	})
+	if err != nil {
+		t.Fatalf("compileRoutes failed: %v", err)
+	}
+	if routes[0].Name != "route-1" || routes[1].Name != "admin" || routes[1].Host != "admin.local" {
+		t.Errorf("Unexpected compiled routes: %+v %+v", routes[0], routes[1])
+	}
+
+	invalid := [][]SysConfigProxyRoute{
+		{{PathPrefix: "/api", Target: "localhost:9000"}},
+		{{PathPrefix: "api", Target: "http://localhost:9000"}},
+		{{StripPrefix: true, Target: "http://localhost:9000"}},
+	}
+	for _, entries := range invalid {
+		if _, err := compileRoutes(entries); err == nil {
+			t.Errorf("Expected an error for %+v", entries)
+		}
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	routes, _ := compileRoutes([]SysConfigProxyRoute{
+		{Name: "beta", PathPrefix: "/api", Headers: map[string]string{"X-Beta": "1"}, Target: "http://beta:80"},
+		{Name: "api", PathPrefix: "/api/", Target: "http://api:80"},
+		{Name: "tenant", Host: "*.example.com", Target: "http://tenant:80"},
+	})
+
+	tests := []struct {
+		host    string
+		path    string
+		headers map[string]string
+		want    string
+	}{
+		{"localhost", "/api/users", map[string]string{"X-Beta": "1"}, "beta"},
+		{"localhost", "/api/users", nil, "api"},
+		{"localhost", "/api", nil, "api"},
+		{"localhost", "/apis", nil, ""},
+		{"acme.example.com:8080", "/", nil, "tenant"},
+		{"example.com", "/", nil, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "http://"+tt.host+tt.path, nil)
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		got := ""
+		if route := matchRoute(routes, req); route != nil {
+			got = route.Name
+		}
+		if got != tt.want {
+			t.Errorf("%s%s: expected route %q, got %q", tt.host, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestProxyHandler_Routes(t *testing.T) {
+	newTarget := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + r.URL.Path))
+		}))
+	}
+	users := newTarget("users")
+	defer users.Close()
+	fallback := newTarget("fallback")
+	defer fallback.Close()
+
+	routes, err := compileRoutes([]SysConfigProxyRoute{
+		{Name: "users", PathPrefix: "/users", Target: users.URL, StripPrefix: true},
+	})
+	if err != nil {
+		t.Fatalf("compileRoutes failed: %v", err)
+	}
+	fallbackURL, _ := url.Parse(fallback.URL)
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-routes-config",
+		TargetURL:   fallbackURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Routes:      routes,
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	for path, want := range map[string]string{
+		"/users/42": "users /42",
+		"/orders/7": "fallback /orders/7",
+	} {
+		resp, err := http.Get(proxy.URL + path)
+		if err != nil {
+			t.Fatalf("Request %s failed: %v", path, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != want {
+			t.Errorf("%s: expected '%s', got '%s'", path, want, string(body))
+		}
+	}
+
+	var sessions []ProxySessionRow
+	for i := 0; i < 20; i++ {
+		sessions, _ = GetSessionsByRoute(db, "test-routes-config", "users", 10, 0)
+		if len(sessions) == 1 && sessions[0].ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("Expected 1 session on route 'users', got %d", len(sessions))
+	}
+	// The session keeps the path the client asked for
+	if sessions[0].RequestPath != "/users/42" {
+		t.Errorf("Expected RequestPath /users/42, got %s", sessions[0].RequestPath)
+	}
+}
+
+func TestProxyHandler_NoRouteMatched(t *testing.T) {
+	routes, _ := compileRoutes([]SysConfigProxyRoute{{PathPrefix: "/only", Target: "http://localhost:1"}})
+	config := &ProxyConfig{TargetURL: &url.URL{}, Routes: routes, WsPublishFn: func(string, any) {}}
+
+	req := httptest.NewRequest("GET", "/elsewhere", nil)
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", w.Code)
+	}
+}
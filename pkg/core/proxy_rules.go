@@ -0,0 +1,504 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// Rule phases
+const (
+	RulePhaseRequest  = "request"  // Runs before the request is sent to the target
+	RulePhaseResponse = "response" // Runs before the response is written to the client
+)
+
+// Rule action types
+const (
+	RuleActionSetHeader    = "set_header"    // Sets header Name to Value
+	RuleActionRemoveHeader = "remove_header" // Removes header Name
+	RuleActionRewriteURL   = "rewrite_url"   // Regex replace on the request path and query
+	RuleActionReplaceBody  = "replace_body"  // Regex replace on the body
+	RuleActionSetJSON      = "set_json"      // Sets the JSONPath Path of a JSON body to JSON
+	RuleActionSetStatus    = "set_status"    // Overrides the response status code
+	RuleActionBreakpoint   = "breakpoint"    // Pauses the exchange until it is resumed, see BreakpointStore
+	RuleActionMock         = "mock"          // Answers with Status, Headers and Body without calling the target
+)
+
+// RuleMatch selects the exchanges a rule applies to. All set fields must match.
+// Matchers look at the request as the client sent it, except BodyRegex which
+// looks at the body of the rule's phase.
+type RuleMatch struct {
+	Methods    []string          `json:"methods,omitempty"`
+	PathPrefix string            `json:"path_prefix,omitempty"`
+	PathRegex  string            `json:"path_regex,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"` // Header name to value regex, "" only requires the header
+	BodyRegex  string            `json:"body_regex,omitempty"`
+}
+
+// RuleAction is one change made by a rule, see the RuleAction* types
+type RuleAction struct {
+	Type        string          `json:"type"`
+	Name        string          `json:"name,omitempty"`
+	Value       string          `json:"value,omitempty"`
+	Pattern     string          `json:"pattern,omitempty"`
+	Replacement string          `json:"replacement,omitempty"`
+	Path        string          `json:"path,omitempty"`
+	JSON        json.RawMessage `json:"json,omitempty"`
+	Status      int             `json:"status,omitempty"`
+	Timeout     string          `json:"timeout,omitempty"` // Breakpoint wait, DefaultBreakpointTimeout when empty
+
+	// Mock response, Headers values and Body are templates over mockTemplateData
+	Headers      map[string]string   `json:"headers,omitempty"`
+	HeaderValues map[string][]string `json:"header_values,omitempty"` // Headers sent once per value, e.g. Set-Cookie
+	Body         string              `json:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty"` // "base64" for binary bodies, which are not templated
+}
+
+// AppliedRule identifies a rule that ran on a session
+type AppliedRule struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Phase string `json:"phase"`
+}
+
+// RuleSet is the compiled, enabled rules of a proxy, in priority order
+type RuleSet struct {
+	rules []*compiledRule
+}
+
+type compiledRule struct {
+	id, name, phase string
+	methods         map[string]bool
+	pathPrefix      string
+	pathRegex       *regexp.Regexp
+	headers         map[string]*regexp.Regexp
+	bodyRegex       *regexp.Regexp
+	actions         []compiledAction
+}
+
+type compiledAction struct {
+	RuleAction
+	pattern   *regexp.Regexp
+	jsonPath  []jsonPathStep
+	jsonValue any
+	timeout   time.Duration
+	mock      *compiledMock
+}
+
+// ruleMessage is the part of a request or response that rules can read and change
+type ruleMessage struct {
+	URL        *url.URL // Request phase only
+	StatusCode int      // Response phase only
+	Header     http.Header
+	Body       []byte
+	HasBody    bool // False for streamed or encoded bodies, which body matchers and actions skip
+
+	breakpoint *ruleBreakpoint // Set when a breakpoint action ran, the exchange pauses after all rules
+	mock       *ruleMock       // Set when a mock action ran, the target is not called
+}
+
+// ruleBreakpoint is the breakpoint an exchange hit while applying rules
+type ruleBreakpoint struct {
+	rule    AppliedRule
+	timeout time.Duration
+}
+
+// ValidateProxyRule checks that a rule can be compiled
+func ValidateProxyRule(rule *ProxyRuleRow) error {
+	_, err := compileRule(rule)
+	return err
+}
+
+// compileRules builds a RuleSet from the enabled rows, which must already be sorted
+func compileRules(rows []ProxyRuleRow) (*RuleSet, error) {
+	rs := &RuleSet{}
+	for i := range rows {
+		if !rows[i].Enabled {
+			continue
+		}
+		cr, err := compileRule(&rows[i])
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", rows[i].ID, err)
+		}
+		rs.rules = append(rs.rules, cr)
+	}
+	return rs, nil
+}
+
+func compileRule(rule *ProxyRuleRow) (*compiledRule, error) {
+	if rule.Phase != RulePhaseRequest && rule.Phase != RulePhaseResponse {
+		return nil, fmt.Errorf("invalid phase %q", rule.Phase)
+	}
+	if len(rule.Actions) == 0 {
+		return nil, fmt.Errorf("rule has no actions")
+	}
+
+	cr := &compiledRule{
+		id:         rule.ID,
+		name:       rule.Name,
+		phase:      rule.Phase,
+		pathPrefix: rule.Match.PathPrefix,
+	}
+
+	if len(rule.Match.Methods) > 0 {
+		cr.methods = make(map[string]bool, len(rule.Match.Methods))
+		for _, m := range rule.Match.Methods {
+			cr.methods[strings.ToUpper(m)] = true
+		}
+	}
+
+	var err error
+	if rule.Match.PathRegex != "" {
+		if cr.pathRegex, err = regexp.Compile(rule.Match.PathRegex); err != nil {
+			return nil, fmt.Errorf("invalid path_regex: %w", err)
+		}
+	}
+	if rule.Match.BodyRegex != "" {
+		if cr.bodyRegex, err = regexp.Compile(rule.Match.BodyRegex); err != nil {
+			return nil, fmt.Errorf("invalid body_regex: %w", err)
+		}
+	}
+	if len(rule.Match.Headers) > 0 {
+		cr.headers = make(map[string]*regexp.Regexp, len(rule.Match.Headers))
+		for name, pattern := range rule.Match.Headers {
+			var re *regexp.Regexp
+			if pattern != "" {
+				if re, err = regexp.Compile(pattern); err != nil {
+					return nil, fmt.Errorf("invalid regex for header %s: %w", name, err)
+				}
+			}
+			cr.headers[http.CanonicalHeaderKey(name)] = re
+		}
+	}
+
+	for i, action := range rule.Actions {
+		ca, err := compileAction(action, rule.Phase)
+		if err != nil {
+			return nil, fmt.Errorf("action %d: %w", i, err)
+		}
+		cr.actions = append(cr.actions, ca)
+	}
+
+	return cr, nil
+}
+
+func compileAction(action RuleAction, phase string) (compiledAction, error) {
+	ca := compiledAction{RuleAction: action}
+	var err error
+
+	switch action.Type {
+	case RuleActionSetHeader, RuleActionRemoveHeader:
+		if action.Name == "" {
+			return ca, fmt.Errorf("%s requires a header name", action.Type)
+		}
+	case RuleActionRewriteURL, RuleActionReplaceBody:
+		if action.Type == RuleActionRewriteURL && phase != RulePhaseRequest {
+			return ca, fmt.Errorf("%s is only allowed in the request phase", action.Type)
+		}
+		if action.Pattern == "" {
+			return ca, fmt.Errorf("%s requires a pattern", action.Type)
+		}
+		if ca.pattern, err = regexp.Compile(action.Pattern); err != nil {
+			return ca, fmt.Errorf("invalid pattern: %w", err)
+		}
+	case RuleActionSetJSON:
+		if ca.jsonPath, err = parseJSONPath(action.Path); err != nil {
+			return ca, err
+		}
+		if len(action.JSON) == 0 {
+			return ca, fmt.Errorf("%s requires a json value", action.Type)
+		}
+		if err = json.Unmarshal(action.JSON, &ca.jsonValue); err != nil {
+			return ca, fmt.Errorf("invalid json value: %w", err)
+		}
+	case RuleActionSetStatus:
+		if phase != RulePhaseResponse {
+			return ca, fmt.Errorf("%s is only allowed in the response phase", action.Type)
+		}
+		if action.Status < 100 || action.Status > 999 {
+			return ca, fmt.Errorf("invalid status %d", action.Status)
+		}
+	case RuleActionMock:
+		if phase != RulePhaseRequest {
+			return ca, fmt.Errorf("%s is only allowed in the request phase", action.Type)
+		}
+		if ca.mock, err = compileMock(action); err != nil {
+			return ca, err
+		}
+	case RuleActionBreakpoint:
+		if ca.timeout, err = parseDurationOption("timeout", action.Timeout, DefaultBreakpointTimeout); err != nil {
+			return ca, err
+		}
+		if ca.timeout == 0 {
+			ca.timeout = DefaultBreakpointTimeout
+		}
+	default:
+		return ca, fmt.Errorf("unknown action type %q", action.Type)
+	}
+
+	return ca, nil
+}
+
+// has reports whether any rule runs in phase
+func (rs *RuleSet) has(phase string) bool {
+	if rs == nil {
+		return false
+	}
+	for _, cr := range rs.rules {
+		if cr.phase == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// apply runs the rules of phase that match r on msg, returning the ones that ran
+func (rs *RuleSet) apply(phase string, r *http.Request, msg *ruleMessage) []AppliedRule {
+	if rs == nil {
+		return nil
+	}
+
+	var applied []AppliedRule
+	bodyChanged := false
+	for _, cr := range rs.rules {
+		if cr.phase != phase || !cr.matches(r, msg) {
+			continue
+		}
+		rule := AppliedRule{ID: cr.id, Name: cr.name, Phase: cr.phase}
+		for _, action := range cr.actions {
+			if action.Type == RuleActionBreakpoint {
+				if msg.breakpoint == nil {
+					msg.breakpoint = &ruleBreakpoint{rule: rule, timeout: action.timeout}
+				}
+				continue
+			}
+			if action.Type == RuleActionMock {
+				if msg.mock == nil {
+					msg.mock = &ruleMock{rule: rule, mock: action.mock}
+				}
+				continue
+			}
+			if action.apply(msg) && (action.Type == RuleActionReplaceBody || action.Type == RuleActionSetJSON) {
+				bodyChanged = true
+			}
+		}
+		applied = append(applied, rule)
+	}
+
+	if bodyChanged && msg.Header.Get("Content-Length") != "" {
+		msg.Header.Set("Content-Length", strconv.Itoa(len(msg.Body)))
+	}
+	return applied
+}
+
+// matches reports whether the rule applies to r, with msg the message of the rule's phase
+func (cr *compiledRule) matches(r *http.Request, msg *ruleMessage) bool {
+	if cr.methods != nil && !cr.methods[r.Method] {
+		return false
+	}
+	if cr.pathPrefix != "" && !strings.HasPrefix(r.URL.Path, cr.pathPrefix) {
+		return false
+	}
+	if cr.pathRegex != nil && !cr.pathRegex.MatchString(r.URL.Path) {
+		return false
+	}
+	for name, re := range cr.headers {
+		values, ok := r.Header[name]
+		if !ok {
+			return false
+		}
+		if re != nil && !re.MatchString(strings.Join(values, ", ")) {
+			return false
+		}
+	}
+	if cr.bodyRegex != nil && (!msg.HasBody || !cr.bodyRegex.Match(msg.Body)) {
+		return false
+	}
+	return true
+}
+
+// apply makes the change on msg, reporting whether it could be made
+func (ca *compiledAction) apply(msg *ruleMessage) bool {
+	switch ca.Type {
+	case RuleActionSetHeader:
+		msg.Header.Set(ca.Name, ca.Value)
+	case RuleActionRemoveHeader:
+		msg.Header.Del(ca.Name)
+	case RuleActionRewriteURL:
+		rewritten := ca.pattern.ReplaceAllString(msg.URL.RequestURI(), ca.Replacement)
+		parsed, err := url.ParseRequestURI(rewritten)
+		if err != nil {
+			log.Warn().Err(err).Str("url", rewritten).Msg("Rule rewrote the URL to an invalid value, ignoring")
+			return false
+		}
+		u := *msg.URL
+		u.Path, u.RawPath, u.RawQuery = parsed.Path, parsed.RawPath, parsed.RawQuery
+		msg.URL = &u
+	case RuleActionReplaceBody:
+		if !msg.HasBody {
+			return false
+		}
+		msg.Body = ca.pattern.ReplaceAll(msg.Body, []byte(ca.Replacement))
+	case RuleActionSetJSON:
+		if !msg.HasBody {
+			return false
+		}
+		body, ok := setJSONBody(msg.Body, ca.jsonPath, ca.jsonValue)
+		if !ok {
+			return false
+		}
+		msg.Body = body
+	case RuleActionSetStatus:
+		msg.StatusCode = ca.Status
+	}
+	return true
+}
+
+// isIdentityEncoding reports whether a body with these headers can be rewritten as is
+func isIdentityEncoding(h http.Header) bool {
+	enc := h.Get("Content-Encoding")
+	return enc == "" || strings.EqualFold(enc, "identity")
+}
+
+// ============================================================
+// JSONPath subset: $.key, $.list[0].key, $["odd key"]
+// ============================================================
+
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parseJSONPath(path string) ([]jsonPathStep, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("invalid json path %q: must start with $", path)
+	}
+
+	var steps []jsonPathStep
+	rest := path[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if key == "" {
+				return nil, fmt.Errorf("invalid json path %q: empty key", path)
+			}
+			steps = append(steps, jsonPathStep{key: key})
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: missing ]", path)
+			}
+			inner := rest[1:end]
+			if unquoted, err := strconv.Unquote(inner); err == nil {
+				steps = append(steps, jsonPathStep{key: unquoted})
+			} else if idx, err := strconv.Atoi(inner); err == nil && idx >= 0 {
+				steps = append(steps, jsonPathStep{index: idx, isIndex: true})
+			} else {
+				return nil, fmt.Errorf("invalid json path %q: bad subscript %s", path, inner)
+			}
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q", path)
+		}
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid json path %q: replacing the whole body is done with replace_body", path)
+	}
+	return steps, nil
+}
+
+// setJSONBody sets the value at steps in a JSON document. Object keys along the path
+// must exist, except the last one which is added when missing.
+func setJSONBody(body []byte, steps []jsonPathStep, value any) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, false
+	}
+	doc, ok := setJSONPath(doc, steps, value)
+	if !ok {
+		return nil, false
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func setJSONPath(node any, steps []jsonPathStep, value any) (any, bool) {
+	if len(steps) == 0 {
+		return value, true
+	}
+	step := steps[0]
+
+	if step.isIndex {
+		list, ok := node.([]any)
+		if !ok || step.index >= len(list) {
+			return node, false
+		}
+		v, ok := setJSONPath(list[step.index], steps[1:], value)
+		if ok {
+			list[step.index] = v
+		}
+		return list, ok
+	}
+
+	obj, ok := node.(map[string]any)
+	if !ok {
+		return node, false
+	}
+	child, exists := obj[step.key]
+	if !exists && len(steps) > 1 {
+		return node, false
+	}
+	v, ok := setJSONPath(child, steps[1:], value)
+	if ok {
+		obj[step.key] = v
+	}
+	return obj, ok
+}
+
+// ============================================================
+// Loading
+// ============================================================
+
+// LoadProxyRules compiles the enabled rules stored for a config
+func LoadProxyRules(db *gorm.DB, configID string) (*RuleSet, error) {
+	rows, err := GetProxyRules(db, configID)
+	if err != nil {
+		return nil, err
+	}
+	return compileRules(rows)
+}
+
+// ReloadProxyRules refreshes the rules of the running proxy for configID, if any
+func ReloadProxyRules(db *gorm.DB, configID string) error {
+	pc := GlobalVar.GetProxyConfig(configID)
+	if pc == nil {
+		return nil
+	}
+	rules, err := LoadProxyRules(db, configID)
+	if err != nil {
+		return err
+	}
+	pc.SetRules(rules)
+	return nil
+}
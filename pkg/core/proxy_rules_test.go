@@ -0,0 +1,166 @@
+package core
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateProxyRule(t *testing.T) {
+	valid := &ProxyRuleRow{
+		Phase:   RulePhaseRequest,
+		Match:   RuleMatch{Methods: []string{"post"}, PathRegex: "^/api/"},
+		Actions: []RuleAction{{Type: RuleActionSetHeader, Name: "Authorization", Value: "Bearer dev"}},
+	}
+	if err := ValidateProxyRule(valid); err != nil {
+		t.Errorf("Expected rule to be valid, got %v", err)
+	}
+
+	invalid := []*ProxyRuleRow{
+		{Phase: "later", Actions: []RuleAction{{Type: RuleActionRemoveHeader, Name: "Cookie"}}},
+		{Phase: RulePhaseRequest},
+		{Phase: RulePhaseRequest, Match: RuleMatch{PathRegex: "("}, Actions: []RuleAction{{Type: RuleActionRemoveHeader, Name: "Cookie"}}},
+		{Phase: RulePhaseRequest, Actions: []RuleAction{{Type: RuleActionSetStatus, Status: 500}}},
+		{Phase: RulePhaseResponse, Actions: []RuleAction{{Type: RuleActionRewriteURL, Pattern: "^/v1", Replacement: "/v2"}}},
+		{Phase: RulePhaseResponse, Actions: []RuleAction{{Type: RuleActionSetJSON, Path: "user.name", JSON: json.RawMessage(`"x"`)}}},
+		{Phase: RulePhaseResponse, Actions: []RuleAction{{Type: "explode"}}},
+	}
+	for _, rule := range invalid {
+		if err := ValidateProxyRule(rule); err == nil {
+			t.Errorf("Expected an error for %+v", rule)
+		}
+	}
+}
+
+func TestSetJSONBody(t *testing.T) {
+	tests := []struct {
+		body  string
+		path  string
+		value any
+		want  string
+		ok    bool
+	}{
+		{`{"user":{"name":"a","id":7}}`, "$.user.name", "b", `{"user":{"id":7,"name":"b"}}`, true},
+		{`{"items":[{"v":1},{"v":2}]}`, "$.items[1].v", 3, `{"items":[{"v":1},{"v":3}]}`, true},
+		{`{"a":1}`, `$["odd key"]`, true, `{"a":1,"odd key":true}`, true},
+		{`{"a":1}`, "$.missing.key", 1, "", false},
+		{`{"items":[]}`, "$.items[0]", 1, "", false},
+		{`not json`, "$.a", 1, "", false},
+	}
+	for _, tt := range tests {
+		steps, err := parseJSONPath(tt.path)
+		if err != nil {
+			t.Fatalf("parseJSONPath(%s) failed: %v", tt.path, err)
+		}
+		got, ok := setJSONBody([]byte(tt.body), steps, tt.value)
+		if ok != tt.ok || string(got) != tt.want {
+			t.Errorf("%s on %s: expected (%s, %v), got (%s, %v)", tt.path, tt.body, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestProxyHandler_Rules(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"path":"` + r.URL.Path + `","auth":"` + r.Header.Get("Authorization") + `","sent":` + string(body) + `,"debug":false}`))
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	rows := []ProxyRuleRow{
+		{
+			ID: "req", Name: "auth and v2", Enabled: true, Phase: RulePhaseRequest,
+			Match: RuleMatch{PathPrefix: "/v1/"},
+			Actions: []RuleAction{
+				{Type: RuleActionSetHeader, Name: "Authorization", Value: "Bearer dev"},
+				{Type: RuleActionRewriteURL, Pattern: "^/v1/", Replacement: "/v2/"},
+				{Type: RuleActionSetJSON, Path: "$.qty", JSON: json.RawMessage(`5`)},
+			},
+		},
+		{
+			ID: "resp", Name: "debug on", Enabled: true, Phase: RulePhaseResponse,
+			Match: RuleMatch{Methods: []string{"POST"}, BodyRegex: `"debug":false`},
+			Actions: []RuleAction{
+				{Type: RuleActionSetJSON, Path: "$.debug", JSON: json.RawMessage(`true`)},
+				{Type: RuleActionRemoveHeader, Name: "Set-Cookie"},
+				{Type: RuleActionSetStatus, Status: http.StatusAccepted},
+			},
+		},
+		{
+			ID: "off", Enabled: false, Phase: RulePhaseResponse,
+			Actions: []RuleAction{{Type: RuleActionSetStatus, Status: http.StatusTeapot}},
+		},
+	}
+	for i := range rows {
+		if err := CreateProxyRule(db, &rows[i]); err != nil {
+			t.Fatalf("CreateProxyRule failed: %v", err)
+		}
+	}
+	rules, err := LoadProxyRules(db, "")
+	if err != nil {
+		t.Fatalf("LoadProxyRules failed: %v", err)
+	}
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-rules-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	config.SetRules(rules)
+
+	req := httptest.NewRequest("POST", "/v1/orders", strings.NewReader(`{"qty":1}`))
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, req)
+
+	wantBody := `{"auth":"Bearer dev","debug":true,"path":"/v2/orders","sent":{"qty":5}}`
+	if w.Code != http.StatusAccepted {
+		t.Errorf("Expected status 202, got %d", w.Code)
+	}
+	if w.Body.String() != wantBody {
+		t.Errorf("Expected body %s, got %s", wantBody, w.Body.String())
+	}
+	if w.Header().Get("Set-Cookie") != "" {
+		t.Errorf("Expected Set-Cookie to be removed")
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-rules-config").First(&session).Error; err == nil && session.ResponseStatusCode != 0 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// The client side of the exchange
+	if session.RequestPath != "/v1/orders" || string(session.RequestBody) != `{"qty":1}` {
+		t.Errorf("Expected original request to be kept, got %s %s", session.RequestPath, session.RequestBody)
+	}
+	if session.ResponseStatusCode != http.StatusAccepted || string(session.ResponseBody) != wantBody {
+		t.Errorf("Expected modified response to be stored, got %d %s", session.ResponseStatusCode, session.ResponseBody)
+	}
+
+	// The target side of the exchange
+	if session.ModifiedRequestURL != "/v2/orders" || string(session.ModifiedRequestBody) != `{"qty":5}` {
+		t.Errorf("Expected modified request to be stored, got %s %s", session.ModifiedRequestURL, session.ModifiedRequestBody)
+	}
+	if session.OriginalResponseStatusCode != http.StatusOK || !strings.Contains(string(session.OriginalResponseBody), `"debug":false`) {
+		t.Errorf("Expected original response to be kept, got %d %s", session.OriginalResponseStatusCode, session.OriginalResponseBody)
+	}
+
+	var applied []AppliedRule
+	if err := json.Unmarshal(session.AppliedRules, &applied); err != nil {
+		t.Fatalf("Failed to parse applied rules: %v", err)
+	}
+	if len(applied) != 2 || applied[0].ID != "req" || applied[1].ID != "resp" {
+		t.Errorf("Expected rules req and resp to be recorded, got %+v", applied)
+	}
+}
@@ -104,3 +104,54 @@ func TestProxyHandler_EndToEnd(t *testing.T) {
 		t.Errorf("DB: Expected request body 'request body', got '%s'", string(session.RequestBody))
 	}
 }
+
+func TestProxyHandler_Trailers(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Trailer.Get("X-Checksum") != "abc" {
+			t.Errorf("Expected the request trailer to reach the target, got %v", r.Trailer)
+		}
+		w.Header().Set("Trailer", "X-Declared")
+		w.Write([]byte("response body"))
+		w.Header().Set("X-Declared", "one")
+		w.Header().Set(http.TrailerPrefix+"X-Undeclared", "two")
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	for _, stream := range []bool{false, true} {
+		db := setupTestDB(t)
+		config := &ProxyConfig{
+			ConfigID:     "test-trailers-config",
+			TargetURL:    targetURL,
+			DB:           db,
+			WsPublishFn:  func(topic string, v any) {},
+			StreamBodies: stream,
+		}
+		proxy := httptest.NewServer(NewProxyHandler(config))
+
+		// Of unknown length, so the body is chunked and can end with trailers
+		req, _ := http.NewRequest("POST", proxy.URL+"/upload", io.MultiReader(strings.NewReader("request body")))
+		req.Trailer = http.Header{"X-Checksum": {"abc"}}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		proxy.Close()
+		if resp.Trailer.Get("X-Declared") != "one" || resp.Trailer.Get("X-Undeclared") != "two" {
+			t.Errorf("stream=%v: expected both response trailers, got %v", stream, resp.Trailer)
+		}
+
+		s := waitForSession(t, config)
+		reqT, _ := s.ParseRequestTrailers()
+		respT, _ := s.ParseResponseTrailers()
+		if reqT.Get("X-Checksum") != "abc" {
+			t.Errorf("stream=%v: expected the stored request trailer, got %s", stream, s.RequestTrailers)
+		}
+		if respT.Get("X-Declared") != "one" || respT.Get("X-Undeclared") != "two" {
+			t.Errorf("stream=%v: expected the stored response trailers, got %s", stream, s.ResponseTrailers)
+		}
+	}
+}
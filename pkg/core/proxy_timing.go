@@ -0,0 +1,98 @@
+package core
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// UpstreamTiming breaks the time spent on the target into phases. Phases that did not
+// happen, like DNS and connect on a reused connection, stay zero.
+type UpstreamTiming struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration // From the request being written to the first response byte
+	Transfer time.Duration // From the first response byte to the end of the body
+}
+
+// upstreamTimer collects phase timestamps from an httptrace.ClientTrace
+type upstreamTimer struct {
+	mu           sync.Mutex
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	finished     bool
+	timing       UpstreamTiming
+}
+
+// trace returns the hooks that feed the timer. Callbacks may fire from transport
+// goroutines, e.g. when dialing several addresses at once.
+func (t *upstreamTimer) trace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.timing.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(string, string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			t.mu.Lock()
+			if err == nil {
+				t.timing.Connect = time.Since(t.connectStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.timing.TLS = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wroteRequest = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			if !t.wroteRequest.IsZero() {
+				t.timing.TTFB = t.firstByte.Sub(t.wroteRequest)
+			}
+			t.mu.Unlock()
+		},
+	}
+}
+
+// finish marks the end of the response body and returns the collected timing. Later
+// calls return the same timing, e.g. after a breakpoint held the response.
+func (t *upstreamTimer) finish() UpstreamTiming {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.finished {
+		t.finished = true
+		if !t.firstByte.IsZero() {
+			t.timing.Transfer = time.Since(t.firstByte)
+		}
+	}
+	return t.timing
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHandler_RecordsTiming(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond) // Backend think time
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		time.Sleep(30 * time.Millisecond) // Slow body
+		w.Write([]byte("done"))
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-timing-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-timing-config").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if session.TimingTTFBMs < 50 {
+		t.Errorf("Expected TTFB to cover the backend think time, got %dms", session.TimingTTFBMs)
+	}
+	if session.TimingTransferMs < 20 {
+		t.Errorf("Expected transfer to cover the slow body, got %dms", session.TimingTransferMs)
+	}
+	if session.TimingTTFBMs+session.TimingTransferMs > session.DurationMs+1 {
+		t.Errorf("Phases (%d+%d) exceed total duration %d", session.TimingTTFBMs, session.TimingTransferMs, session.DurationMs)
+	}
+}
+
+func TestProxyHandler_TransferEndsWithTargetBody(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 400)))
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-timing-throttled",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	// 400 bytes at 1000 B/s take about 400ms to reach the client
+	fi, err := NewFaultInjector(&SysConfigProxyFaults{Bandwidth: 1000})
+	if err != nil {
+		t.Fatalf("NewFaultInjector failed: %v", err)
+	}
+	config.SetFaults(fi)
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", config.ConfigID).First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if session.DurationMs < 300 || session.TimingTransferMs >= 200 {
+		t.Errorf("Expected the throttled write outside transfer, got transfer %dms of %dms", session.TimingTransferMs, session.DurationMs)
+	}
+}
@@ -0,0 +1,125 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+)
+
+// listenerTLSConfig builds the TLS config a proxy listener serves with, or nil for plain HTTP.
+// An explicit tls-cert/tls-key pair wins over auto-tls, which signs certificates with the ihpp CA.
+func listenerTLSConfig(entry SysConfigProxyEntry) (*tls.Config, error) {
+	if (entry.TLSCert == "") != (entry.TLSKey == "") {
+		return nil, fmt.Errorf("both 'tls-cert' and 'tls-key' must be set")
+	}
+
+	if entry.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(entry.TLSCert, entry.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		return &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			NextProtos:   []string{"http/1.1"},
+		}, nil
+	}
+
+	if !entry.AutoTLS {
+		return nil, nil
+	}
+
+	ca, err := DefaultCA()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate authority: %w", err)
+	}
+	return autoTLSConfig(ca, entry.Listen), nil
+}
+
+// autoTLSConfig serves a CA signed certificate for whatever name the client asks for,
+// falling back to the listen host (or localhost when listening on all interfaces)
+func autoTLSConfig(ca *CertAuthority, listen string) *tls.Config {
+	fallbackHost := "localhost"
+	if host, _, err := net.SplitHostPort(listen); err == nil && host != "" && host != "0.0.0.0" && host != "::" {
+		fallbackHost = host
+	}
+
+	return &tls.Config{
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			if hello.ServerName != "" {
+				return ca.LeafFor(hello.ServerName)
+			}
+			return ca.LeafFor(fallbackHost)
+		},
+	}
+}
+
+// upstreamTLSConfig builds the client TLS config used towards targets, or nil when the
+// entry sets no upstream TLS options and the system defaults apply
+func upstreamTLSConfig(entry SysConfigProxyEntry) (*tls.Config, error) {
+	if entry.UpstreamCABundle == "" && entry.UpstreamClientCert == "" && entry.UpstreamClientKey == "" &&
+		entry.UpstreamServerName == "" && !entry.UpstreamInsecureSkipVerify {
+		return nil, nil
+	}
+	if entry.UpstreamServerName != "" && entry.ProxyMode() == ProxyModeForward {
+		// Forward proxies share their transport between all destinations
+		return nil, fmt.Errorf("'upstream-server-name' cannot be used in forward mode, where each request has its own target")
+	}
+	if hosts := upstreamHostnames(entry); len(hosts) > 1 && entry.UpstreamServerName != "" {
+		// The transport is shared between targets, one name cannot be verified for all
+		return nil, fmt.Errorf("'upstream-server-name' cannot be used with targets on different hosts (%d found)", len(hosts))
+	}
+
+	cfg := &tls.Config{
+		ServerName:         entry.UpstreamServerName,
+		InsecureSkipVerify: entry.UpstreamInsecureSkipVerify,
+	}
+
+	if entry.UpstreamCABundle != "" {
+		pemData, err := os.ReadFile(entry.UpstreamCABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read upstream CA bundle: %w", err)
+		}
+		// Trust the bundle on top of the system roots
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pemData) {
+			return nil, fmt.Errorf("no certificates found in upstream CA bundle %s", entry.UpstreamCABundle)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (entry.UpstreamClientCert == "") != (entry.UpstreamClientKey == "") {
+		return nil, fmt.Errorf("both 'upstream-client-cert' and 'upstream-client-key' must be set")
+	}
+	if entry.UpstreamClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(entry.UpstreamClientCert, entry.UpstreamClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load upstream client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// upstreamHostnames returns the distinct hostnames of the target, targets and routes of entry
+func upstreamHostnames(entry SysConfigProxyEntry) map[string]struct{} {
+	targets := append([]string{entry.Target}, entry.Targets...)
+	for _, route := range entry.Routes {
+		targets = append(targets, route.Target)
+	}
+
+	hosts := make(map[string]struct{})
+	for _, target := range targets {
+		if u, err := url.Parse(target); err == nil && u.Hostname() != "" {
+			hosts[u.Hostname()] = struct{}{}
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,201 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListenerTLSConfig(t *testing.T) {
+	cfg, err := listenerTLSConfig(SysConfigProxyEntry{Listen: ":0"})
+	if err != nil || cfg != nil {
+		t.Errorf("Expected no TLS config for a plain listener, got %v, %v", cfg, err)
+	}
+
+	if _, err := listenerTLSConfig(SysConfigProxyEntry{Listen: ":0", TLSCert: "cert.pem"}); err == nil {
+		t.Error("Expected an error when tls-key is missing")
+	}
+
+	if _, err := listenerTLSConfig(SysConfigProxyEntry{Listen: ":0", TLSCert: "missing.pem", TLSKey: "missing-key.pem"}); err == nil {
+		t.Error("Expected an error for unreadable certificate files")
+	}
+}
+
+func TestProxyHandler_TLSListener(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Proto")))
+	}))
+	defer mockTarget.Close()
+
+	ca, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-tls-listener",
+		TargetURL:   targetURL,
+		DB:          setupTestDB(t),
+		WsPublishFn: func(topic string, v any) {},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	srv := &http.Server{
+		Handler:   NewProxyHandler(config),
+		TLSConfig: autoTLSConfig(ca, ln.Addr().String()),
+	}
+	go srv.ServeTLS(ln, "", "")
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+	resp, err := client.Get("https://" + ln.Addr().String() + "/secure")
+	if err != nil {
+		t.Fatalf("HTTPS request to proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if string(body) != "https" {
+		t.Errorf("Expected X-Forwarded-Proto 'https', got '%s'", string(body))
+	}
+}
+
+func TestUpstreamTLSConfig(t *testing.T) {
+	cfg, err := upstreamTLSConfig(SysConfigProxyEntry{})
+	if err != nil || cfg != nil {
+		t.Errorf("Expected no upstream TLS config by default, got %v, %v", cfg, err)
+	}
+
+	cfg, err = upstreamTLSConfig(SysConfigProxyEntry{UpstreamServerName: "internal.local", UpstreamInsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("upstreamTLSConfig failed: %v", err)
+	}
+	if cfg.ServerName != "internal.local" || !cfg.InsecureSkipVerify {
+		t.Errorf("Unexpected upstream TLS config: %+v", cfg)
+	}
+
+	if _, err := upstreamTLSConfig(SysConfigProxyEntry{Mode: ProxyModeForward, UpstreamServerName: "internal.local"}); err == nil {
+		t.Error("Expected an error for upstream-server-name in forward mode")
+	}
+	if _, err := upstreamTLSConfig(SysConfigProxyEntry{
+		Target:             "https://api.internal:8443",
+		Targets:            []string{"https://api.internal:9443"},
+		UpstreamServerName: "internal.local",
+	}); err != nil {
+		t.Errorf("Expected upstream-server-name for targets on one host, got %v", err)
+	}
+	if _, err := upstreamTLSConfig(SysConfigProxyEntry{
+		Target:             "https://api.internal",
+		Routes:             []SysConfigProxyRoute{{PathPrefix: "/auth", Target: "https://auth.internal"}},
+		UpstreamServerName: "internal.local",
+	}); err == nil {
+		t.Error("Expected an error for upstream-server-name with targets on several hosts")
+	}
+
+	if _, err := upstreamTLSConfig(SysConfigProxyEntry{UpstreamClientCert: "client.pem"}); err == nil {
+		t.Error("Expected an error when upstream-client-key is missing")
+	}
+
+	emptyBundle := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(emptyBundle, []byte("not a certificate"), 0644)
+	if _, err := upstreamTLSConfig(SysConfigProxyEntry{UpstreamCABundle: emptyBundle}); err == nil {
+		t.Error("Expected an error for a CA bundle without certificates")
+	}
+}
+
+func TestProxyHandler_UpstreamTLS(t *testing.T) {
+	mockTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer mockTarget.Close()
+
+	// Trust the self-signed target through a CA bundle file
+	bundle := filepath.Join(t.TempDir(), "bundle.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockTarget.Certificate().Raw})
+	if err := os.WriteFile(bundle, certPEM, 0644); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+
+	newProxy := func(configID string, entry SysConfigProxyEntry) *httptest.Server {
+		upstreamTLS, err := upstreamTLSConfig(entry)
+		if err != nil {
+			t.Fatalf("upstreamTLSConfig failed: %v", err)
+		}
+		transport, err := newUpstreamTransport(entry, upstreamTLS)
+		if err != nil {
+			t.Fatalf("newUpstreamTransport failed: %v", err)
+		}
+		return httptest.NewServer(NewProxyHandler(&ProxyConfig{
+			ConfigID:    configID,
+			TargetURL:   targetURL,
+			DB:          db,
+			WsPublishFn: func(topic string, v any) {},
+			UpstreamTLS: upstreamTLS,
+			Transport:   transport,
+		}))
+	}
+
+	// 1. Without the bundle the self-signed target is rejected
+	untrusted := newProxy("test-upstream-untrusted", SysConfigProxyEntry{})
+	defer untrusted.Close()
+	resp, err := http.Get(untrusted.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		t.Error("Expected an untrusted upstream certificate to fail")
+	}
+
+	// 2. With the bundle the request succeeds and the handshake is recorded
+	trusted := newProxy("test-upstream-trusted", SysConfigProxyEntry{UpstreamCABundle: bundle})
+	defer trusted.Close()
+	resp, err = http.Get(trusted.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "secret" {
+		t.Fatalf("Expected 'secret', got '%s'", string(body))
+	}
+
+	var session ProxySessionRow
+	for i := 0; i < 20; i++ {
+		if err := db.Where("config_id = ?", "test-upstream-trusted").First(&session).Error; err == nil && session.ResponseStatusCode == 200 {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if session.UpstreamTLSVersion == "" || session.UpstreamTLSCipher == "" {
+		t.Errorf("Expected TLS version and cipher to be recorded, got %q %q", session.UpstreamTLSVersion, session.UpstreamTLSCipher)
+	}
+
+	var chain []TLSCertificateInfo
+	if err := json.Unmarshal(session.UpstreamTLSPeerCerts, &chain); err != nil || len(chain) == 0 {
+		t.Fatalf("Expected a recorded peer chain, got %s (%v)", string(session.UpstreamTLSPeerCerts), err)
+	}
+	if chain[0].SHA256 == "" {
+		t.Error("Expected the leaf fingerprint to be recorded")
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// Defaults for the upstream transport, tuned for proxying to a handful of hosts
+const (
+	DefaultUpstreamMaxIdleConns        = 100
+	DefaultUpstreamMaxIdleConnsPerHost = 100
+	DefaultUpstreamIdleConnTimeout     = 90 * time.Second
+	DefaultUpstreamDialTimeout         = 30 * time.Second
+	DefaultUpstreamTLSHandshakeTimeout = 10 * time.Second
+)
+
+// defaultUpstreamTransport serves proxy configs built without SetupProxyConfig
+var defaultUpstreamTransport, _ = newUpstreamTransport(SysConfigProxyEntry{}, nil)
+
+// newUpstreamTransport builds the transport a proxy shares across all its requests,
+// so connections to the target are pooled and reused
+func newUpstreamTransport(entry SysConfigProxyEntry, upstreamTLS *tls.Config) (*http.Transport, error) {
+	idleConnTimeout, err := parseDurationOption("idle-conn-timeout", entry.IdleConnTimeout, DefaultUpstreamIdleConnTimeout)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout, err := parseDurationOption("dial-timeout", entry.DialTimeout, DefaultUpstreamDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tlsHandshakeTimeout, err := parseDurationOption("tls-handshake-timeout", entry.TLSHandshakeTimeout, DefaultUpstreamTLSHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	responseHeaderTimeout, err := parseDurationOption("response-header-timeout", entry.ResponseHeaderTimeout, 0)
+	if err != nil {
+		return nil, err
+	}
+	if entry.UpstreamH2C && entry.DisableUpstreamHTTP2 {
+		return nil, fmt.Errorf("'upstream-h2c' and 'disable-upstream-http2' cannot both be set")
+	}
+
+	maxIdleConns := DefaultUpstreamMaxIdleConns
+	if entry.MaxIdleConns > 0 {
+		maxIdleConns = entry.MaxIdleConns
+	}
+	maxIdleConnsPerHost := DefaultUpstreamMaxIdleConnsPerHost
+	if entry.MaxIdleConnsPerHost > 0 {
+		maxIdleConnsPerHost = entry.MaxIdleConnsPerHost
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   maxIdleConnsPerHost,
+		IdleConnTimeout:       idleConnTimeout,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ResponseHeaderTimeout: responseHeaderTimeout,
+		ExpectContinueTimeout: 1 * time.Second,
+		// Bodies are recorded and relayed as the target sent them
+		DisableCompression: true,
+		ForceAttemptHTTP2:  !entry.DisableUpstreamHTTP2,
+	}
+	if upstreamTLS != nil {
+		transport.TLSClientConfig = upstreamTLS.Clone()
+	}
+	if entry.DisableUpstreamHTTP2 {
+		// A non-nil empty map keeps the transport from negotiating h2
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if entry.UpstreamH2C {
+		// Without HTTP/1 the transport speaks HTTP/2 with prior knowledge to http:// targets
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	}
+
+	return transport, nil
+}
+
+// configureListenerProtocols sets the protocols a proxy listener accepts. Without 'http2' it
+// stays on HTTP/1.1, as hijacking (WebSocket, CONNECT) is not possible over HTTP/2.
+func configureListenerProtocols(srv *http.Server, entry SysConfigProxyEntry) {
+	if entry.HTTP2 {
+		// HTTP/2 over TLS is negotiated with ALPN, h2c needs prior knowledge
+		srv.Protocols = new(http.Protocols)
+		srv.Protocols.SetHTTP1(true)
+		srv.Protocols.SetHTTP2(true)
+		srv.Protocols.SetUnencryptedHTTP2(true)
+		if srv.TLSConfig != nil && !slices.Contains(srv.TLSConfig.NextProtos, "h2") {
+			// The server picks the first protocol the client also offers, so h2 goes first
+			srv.TLSConfig = srv.TLSConfig.Clone()
+			srv.TLSConfig.NextProtos = append([]string{"h2"}, srv.TLSConfig.NextProtos...)
+		}
+	} else if srv.TLSConfig != nil {
+		srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+	}
+}
+
+// parseDurationOption parses a duration config value, returning def when it is empty
+func parseDurationOption(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid '%s' duration: %s", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,213 @@
+package core
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newCountingTarget starts a target server that counts the TCP connections it accepts
+func newCountingTarget(tb testing.TB) (*httptest.Server, *atomic.Int64) {
+	var conns atomic.Int64
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	target.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	target.Start()
+	tb.Cleanup(target.Close)
+	return target, &conns
+}
+
+func TestNewUpstreamTransport(t *testing.T) {
+	transport, err := newUpstreamTransport(SysConfigProxyEntry{}, nil)
+	if err != nil {
+		t.Fatalf("newUpstreamTransport failed: %v", err)
+	}
+	if transport.MaxIdleConnsPerHost != DefaultUpstreamMaxIdleConnsPerHost || !transport.DisableCompression || !transport.ForceAttemptHTTP2 {
+		t.Errorf("Unexpected default transport: %+v", transport)
+	}
+
+	transport, err = newUpstreamTransport(SysConfigProxyEntry{
+		MaxIdleConnsPerHost:   8,
+		ResponseHeaderTimeout: "5s",
+		DisableUpstreamHTTP2:  true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("newUpstreamTransport failed: %v", err)
+	}
+	if transport.MaxIdleConnsPerHost != 8 || transport.ResponseHeaderTimeout != 5*time.Second {
+		t.Errorf("Expected configured pool size and timeout, got %d %v", transport.MaxIdleConnsPerHost, transport.ResponseHeaderTimeout)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("Expected HTTP/2 to be disabled")
+	}
+
+	if _, err := newUpstreamTransport(SysConfigProxyEntry{DialTimeout: "soon"}, nil); err == nil {
+		t.Error("Expected an error for an invalid duration")
+	}
+}
+
+func TestProxyHandler_ReusesUpstreamConnections(t *testing.T) {
+	target, conns := newCountingTarget(t)
+	targetURL, _ := url.Parse(target.URL)
+
+	transport, _ := newUpstreamTransport(SysConfigProxyEntry{}, nil)
+	proxy := httptest.NewServer(NewProxyHandler(&ProxyConfig{
+		ConfigID:    "test-keepalive",
+		TargetURL:   targetURL,
+		WsPublishFn: func(topic string, v any) {},
+		Transport:   transport,
+	}))
+	defer proxy.Close()
+
+	const requests = 20
+	for i := 0; i < requests; i++ {
+		resp, err := http.Get(proxy.URL)
+		if err != nil {
+			t.Fatalf("Request %d failed: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	if n := conns.Load(); n != 1 {
+		t.Errorf("Expected sequential requests to share 1 upstream connection, got %d", n)
+	}
+}
+
+func TestProxyHandler_HTTP2(t *testing.T) {
+	echoProto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	ca, err := LoadOrCreateCA(t.TempDir())
+	if err != nil {
+		t.Fatalf("LoadOrCreateCA failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Cert)
+
+	tlsTarget := httptest.NewUnstartedServer(echoProto)
+	tlsTarget.EnableHTTP2 = true
+	tlsTarget.StartTLS()
+	defer tlsTarget.Close()
+
+	h2cTarget := httptest.NewUnstartedServer(echoProto)
+	configureListenerProtocols(h2cTarget.Config, SysConfigProxyEntry{HTTP2: true})
+	h2cTarget.Start()
+	defer h2cTarget.Close()
+
+	h2cClient := &http.Transport{Protocols: new(http.Protocols)}
+	h2cClient.Protocols.SetUnencryptedHTTP2(true)
+
+	tests := []struct {
+		name   string
+		target string
+		entry  SysConfigProxyEntry
+		tls    bool
+		client *http.Transport
+	}{
+		{"h2 over TLS", tlsTarget.URL, SysConfigProxyEntry{HTTP2: true, UpstreamInsecureSkipVerify: true}, true,
+			&http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true}},
+		{"h2c", h2cTarget.URL, SysConfigProxyEntry{HTTP2: true, UpstreamH2C: true}, false, h2cClient},
+	}
+	for _, tt := range tests {
+		upstreamTLS, err := upstreamTLSConfig(tt.entry)
+		if err != nil {
+			t.Fatalf("%s: upstreamTLSConfig failed: %v", tt.name, err)
+		}
+		transport, err := newUpstreamTransport(tt.entry, upstreamTLS)
+		if err != nil {
+			t.Fatalf("%s: newUpstreamTransport failed: %v", tt.name, err)
+		}
+		targetURL, _ := url.Parse(tt.target)
+		config := &ProxyConfig{
+			ConfigID:    "test-http2-" + tt.name,
+			TargetURL:   targetURL,
+			DB:          setupTestDB(t),
+			WsPublishFn: func(topic string, v any) {},
+			Transport:   transport,
+		}
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Listen failed: %v", err)
+		}
+		srv := &http.Server{Handler: NewProxyHandler(config)}
+		scheme := "http"
+		if tt.tls {
+			srv.TLSConfig = autoTLSConfig(ca, ln.Addr().String())
+			scheme = "https"
+		}
+		configureListenerProtocols(srv, tt.entry)
+		if tt.tls {
+			go srv.ServeTLS(ln, "", "")
+		} else {
+			go srv.Serve(ln)
+		}
+		defer srv.Close()
+
+		resp, err := (&http.Client{Transport: tt.client}).Get(scheme + "://" + ln.Addr().String() + "/")
+		if err != nil {
+			t.Fatalf("%s: request failed: %v", tt.name, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.Proto != "HTTP/2.0" || string(body) != "HTTP/2.0" {
+			t.Errorf("%s: expected HTTP/2 on both sides, got %s to the proxy and %s to the target", tt.name, resp.Proto, body)
+		}
+
+		s := waitForSession(t, config)
+		if s.RequestProto != "HTTP/2.0" || s.UpstreamProto != "HTTP/2.0" {
+			t.Errorf("%s: expected HTTP/2.0 recorded, got %q and %q", tt.name, s.RequestProto, s.UpstreamProto)
+		}
+	}
+
+	if _, err := newUpstreamTransport(SysConfigProxyEntry{UpstreamH2C: true, DisableUpstreamHTTP2: true}, nil); err == nil {
+		t.Error("Expected upstream-h2c and disable-upstream-http2 to conflict")
+	}
+}
+
+func BenchmarkProxyHandler_KeepAlive(b *testing.B) {
+	b.Setenv("IHPP_DAEMON", "1") // Silence console output
+
+	target, conns := newCountingTarget(b)
+	targetURL, _ := url.Parse(target.URL)
+
+	transport, _ := newUpstreamTransport(SysConfigProxyEntry{}, nil)
+	proxy := httptest.NewServer(NewProxyHandler(&ProxyConfig{
+		ConfigID:    "bench-keepalive",
+		TargetURL:   targetURL,
+		WsPublishFn: func(topic string, v any) {},
+		Transport:   transport,
+	}))
+	defer proxy.Close()
+
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 64}}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			resp, err := client.Get(proxy.URL)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	})
+	b.StopTimer()
+
+	b.ReportMetric(float64(conns.Load()), "upstream-conns")
+}
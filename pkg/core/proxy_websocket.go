@@ -0,0 +1,402 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// isWebSocketUpgrade reports whether the request asks to switch to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, v := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveWebSocketProxy performs the upgrade handshake over upstreamConn, dialed to the target
+// with dialWsUpstream, hijacks the client connection and relays frames in both directions,
+// recording each of them
+func serveWebSocketProxy(
+	config *ProxyConfig,
+	w http.ResponseWriter,
+	r *http.Request,
+	entry *LogEntry,
+	session *ProxySessionRow,
+	targetURL *url.URL,
+	upstreamConn net.Conn,
+) {
+	defer upstreamConn.Close()
+	if tlsConn, ok := upstreamConn.(*tls.Conn); ok {
+		state := tlsConn.ConnectionState()
+		entry.UpstreamTLS = &state
+	}
+
+	// --- Forward Handshake ---
+	proxyReq, err := http.NewRequest(entry.RequestMethod, targetURL.String(), nil)
+	if err != nil {
+		failProxySession(config, w, r, entry, session, fmt.Errorf("failed to create WebSocket upgrade request: %w", err))
+		return
+	}
+	copyHeaders(entry.RequestHeaders, proxyReq.Header)
+	proxyReq.Host = targetURL.Host
+	setForwardedHeaders(proxyReq.Header, r)
+	removeHopByHopHeaders(proxyReq.Header)
+	proxyReq.Header.Set("Connection", "Upgrade")
+	proxyReq.Header.Set("Upgrade", "websocket")
+	// Compressed frames cannot be inspected, so extensions are not negotiated
+	proxyReq.Header.Del("Sec-WebSocket-Extensions")
+
+	if err := proxyReq.Write(upstreamConn); err != nil {
+		failProxySession(config, w, r, entry, session, err)
+		return
+	}
+
+	upstreamReader := bufio.NewReader(upstreamConn)
+	resp, err := http.ReadResponse(upstreamReader, proxyReq)
+	if err != nil {
+		failProxySession(config, w, r, entry, session, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	entry.StatusCode = resp.StatusCode
+	entry.UpstreamProto = resp.Proto
+	entry.ResponseHeaders = resp.Header.Clone()
+
+	// --- Target Refused the Upgrade: Relay as a Plain Response ---
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		responseBodyBytes, err := io.ReadAll(resp.Body)
+		if err != nil {
+			log.Warn().Err(err).Msg("Error reading WebSocket refusal body")
+		}
+		destHeaders := w.Header()
+		copyHeaders(resp.Header, destHeaders)
+		removeHopByHopHeaders(destHeaders)
+		w.WriteHeader(resp.StatusCode)
+		if len(responseBodyBytes) > 0 {
+			_, _ = w.Write(responseBodyBytes)
+		}
+		entry.ResponseBody = responseBodyBytes
+		entry.Duration = time.Since(entry.Timestamp)
+		printTargetResponse(entry, resp.Status, config.TruncateLogBody)
+		finishWsSession(config, session, entry)
+		return
+	}
+
+	// --- Hijack Client and Complete Handshake ---
+	clientConn, clientBuf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		// HTTP/2 connections cannot be hijacked
+		failProxySession(config, w, r, entry, session, fmt.Errorf("failed to hijack client connection for WebSocket: %w", err))
+		return
+	}
+	defer clientConn.Close()
+	// Deadlines set on the server side must not cut a long lived socket
+	_ = clientConn.SetDeadline(time.Time{})
+
+	fmt.Fprintf(clientBuf, "HTTP/1.1 %s\r\n", resp.Status)
+	_ = resp.Header.Write(clientBuf)
+	_, _ = clientBuf.WriteString("\r\n")
+	if err := clientBuf.Flush(); err != nil {
+		log.Warn().Err(err).Msg("Failed writing WebSocket handshake to client")
+		return
+	}
+
+	entry.Duration = time.Since(entry.Timestamp)
+	printTargetResponse(entry, resp.Status, config.TruncateLogBody)
+	finishWsSession(config, session, entry)
+
+	// --- Pump Frames ---
+	recorder := newWsFrameRecorder(config, session, entry)
+	errc := make(chan error, 2)
+	go func() {
+		errc <- pumpWsFrames(clientBuf.Reader, upstreamConn, WsFrameClientToServer, recorder)
+	}()
+	go func() {
+		errc <- pumpWsFrames(upstreamReader, clientConn, WsFrameServerToClient, recorder)
+	}()
+
+	if err := <-errc; err != nil && err != io.EOF && !isClosedConnError(err) {
+		log.Debug().Err(err).Msg("WebSocket relay ended")
+	}
+	// Unblock the other direction
+	clientConn.Close()
+	upstreamConn.Close()
+	<-errc
+	recorder.close()
+	for _, m := range recorder.redactions {
+		addRedaction(&entry.Redactions, m)
+	}
+
+	// Session duration covers the whole lifetime of the socket
+	entry.Duration = time.Since(entry.Timestamp)
+	finishWsSession(config, session, entry)
+}
+
+// finishWsSession stores the handshake outcome on the session and notifies listeners
+func finishWsSession(config *ProxyConfig, session *ProxySessionRow, entry *LogEntry) {
+	if config.DB == nil || session == nil {
+		return
+	}
+	if err := FinishProxySession(config.DB, session, entry); err != nil {
+		log.Warn().Err(err).Msg("Failed to finish WebSocket session in database")
+		return
+	}
+	config.WsPublishFn("sessions", FormatSessionStub(session))
+}
+
+// dialWsUpstream opens a raw connection to the target, negotiating TLS for https targets
+// with the proxy's upstream TLS settings when given. The dial and TLS handshake timeouts
+// of transport apply, the defaults without one.
+func dialWsUpstream(ctx context.Context, targetURL *url.URL, transport *http.Transport, baseTLS *tls.Config) (net.Conn, error) {
+	secure := targetURL.Scheme == "https" || targetURL.Scheme == "wss"
+
+	addr := targetURL.Host
+	if targetURL.Port() == "" {
+		if secure {
+			addr = net.JoinHostPort(targetURL.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(targetURL.Hostname(), "80")
+		}
+	}
+
+	dial := (&net.Dialer{Timeout: DefaultUpstreamDialTimeout}).DialContext
+	handshakeTimeout := DefaultUpstreamTLSHandshakeTimeout
+	if transport != nil {
+		if transport.DialContext != nil {
+			dial = transport.DialContext
+		}
+		handshakeTimeout = transport.TLSHandshakeTimeout
+	}
+
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil || !secure {
+		return conn, err
+	}
+
+	tlsConfig := &tls.Config{}
+	if baseTLS != nil {
+		tlsConfig = baseTLS.Clone()
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = targetURL.Hostname()
+	}
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	if handshakeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, handshakeTimeout)
+		defer cancel()
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// pumpWsFrames relays frames from src to dst until either side fails
+func pumpWsFrames(src *bufio.Reader, dst io.Writer, direction string, recorder *wsFrameRecorder) error {
+	bw := bufio.NewWriter(dst)
+	for {
+		frame, err := relayWsFrame(src, bw)
+		if err != nil {
+			return err
+		}
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+		frame.Direction = direction
+		recorder.record(frame)
+	}
+}
+
+// relayWsFrame copies exactly one RFC 6455 frame from src to dst without altering it,
+// returning the frame metadata with its (unmasked) payload captured up to MaxWsFrameCaptureSize
+func relayWsFrame(src *bufio.Reader, dst io.Writer) (*ProxySessionFrameRow, error) {
+	var header [14]byte
+	if _, err := io.ReadFull(src, header[:2]); err != nil {
+		return nil, err
+	}
+	n := 2
+
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0F)
+	masked := header[1]&0x80 != 0
+	payloadLen := int64(header[1] & 0x7F)
+
+	switch payloadLen {
+	case 126:
+		if _, err := io.ReadFull(src, header[n:n+2]); err != nil {
+			return nil, err
+		}
+		payloadLen = int64(binary.BigEndian.Uint16(header[n : n+2]))
+		n += 2
+	case 127:
+		if _, err := io.ReadFull(src, header[n:n+8]); err != nil {
+			return nil, err
+		}
+		payloadLen = int64(binary.BigEndian.Uint64(header[n : n+8]))
+		n += 8
+		if payloadLen < 0 {
+			return nil, errWsPayloadLength
+		}
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err := io.ReadFull(src, header[n:n+4]); err != nil {
+			return nil, err
+		}
+		copy(maskKey[:], header[n:n+4])
+		n += 4
+	}
+
+	if _, err := dst.Write(header[:n]); err != nil {
+		return nil, err
+	}
+
+	captureLen := min(payloadLen, int64(MaxWsFrameCaptureSize))
+	payload := make([]byte, captureLen)
+	if _, err := io.ReadFull(src, payload); err != nil {
+		return nil, err
+	}
+	if _, err := dst.Write(payload); err != nil {
+		return nil, err
+	}
+	if rest := payloadLen - captureLen; rest > 0 {
+		if _, err := io.CopyN(dst, src, rest); err != nil {
+			return nil, err
+		}
+	}
+
+	// Unmask only after the original bytes were relayed
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+
+	return &ProxySessionFrameRow{
+		Timestamp:        time.Now(),
+		Opcode:           opcode,
+		Fin:              fin,
+		Payload:          payload,
+		PayloadSize:      payloadLen,
+		PayloadTruncated: captureLen < payloadLen,
+	}, nil
+}
+
+// errWsPayloadLength ends a relay on a 64-bit payload length with its most significant
+// bit set, which RFC 6455 forbids
+var errWsPayloadLength = errors.New("invalid WebSocket frame payload length")
+
+// isClosedConnError reports whether err comes from using a connection we already closed
+func isClosedConnError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// wsFrameRecorder persists and publishes frames off the relay path so a slow
+// database never stalls the socket more than the channel buffer allows
+type wsFrameRecorder struct {
+	config   *ProxyConfig
+	session  *ProxySessionRow
+	redactor *redactor
+	noBodies bool // Payloads are left out, see CaptureNoBodies
+	frames   chan *ProxySessionFrameRow
+	done     chan struct{}
+	mu       sync.Mutex
+	seq      int64
+
+	// Opcode of the fragmented message in progress in each direction, whose
+	// continuation frames are masked like its first frame
+	fragmented map[string]int
+
+	// What was masked in text frames, only read once closed
+	redactions []Redaction
+}
+
+func newWsFrameRecorder(config *ProxyConfig, session *ProxySessionRow, entry *LogEntry) *wsFrameRecorder {
+	rec := &wsFrameRecorder{
+		config:     config,
+		session:    session,
+		redactor:   entry.redactor,
+		noBodies:   entry.CaptureAction == CaptureNoBodies,
+		frames:     make(chan *ProxySessionFrameRow, 256),
+		done:       make(chan struct{}),
+		fragmented: make(map[string]int),
+	}
+	go rec.run()
+	return rec
+}
+
+func (rec *wsFrameRecorder) record(frame *ProxySessionFrameRow) {
+	rec.mu.Lock()
+	rec.seq++
+	frame.Seq = rec.seq
+	opcode := frame.Opcode
+	switch {
+	case opcode == 0x0:
+		opcode = rec.fragmented[frame.Direction]
+		if frame.Fin {
+			delete(rec.fragmented, frame.Direction)
+		}
+	case opcode < 0x8 && !frame.Fin:
+		// Control frames may come between the fragments of a message
+		rec.fragmented[frame.Direction] = opcode
+	}
+	if rec.noBodies {
+		frame.Payload = nil
+	} else if opcode == 0x1 {
+		// Masked before the console or the database see it
+		frame.Payload = rec.redactor.redactFrame(frame.Payload, &rec.redactions)
+	}
+	rec.mu.Unlock()
+
+	printWebSocketFrame(frame)
+
+	if rec.session != nil {
+		frame.SessionID = rec.session.ID
+	}
+	rec.frames <- frame
+}
+
+func (rec *wsFrameRecorder) run() {
+	defer close(rec.done)
+	for frame := range rec.frames {
+		if rec.config.DB == nil || rec.session == nil {
+			continue
+		}
+		if err := CreateSessionFrame(rec.config.DB, frame); err != nil {
+			log.Warn().Err(err).Str("session_id", frame.SessionID).Msg("Failed to store WebSocket frame")
+			continue
+		}
+		rec.config.WsPublishFn("frames", FormatSessionFrame(rec.config.ConfigID, frame))
+	}
+}
+
+// close waits until all recorded frames have been handled
+func (rec *wsFrameRecorder) close() {
+	close(rec.frames)
+	<-rec.done
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestRelayWsFrame_UnmasksCapture(t *testing.T) {
+	// Masked text frame "Hi" with mask key 0x01020304
+	raw := []byte{0x81, 0x82, 0x01, 0x02, 0x03, 0x04, 'H' ^ 0x01, 'i' ^ 0x02}
+
+	var out bytes.Buffer
+	frame, err := relayWsFrame(bufio.NewReader(bytes.NewReader(raw)), &out)
+	if err != nil {
+		t.Fatalf("relayWsFrame failed: %v", err)
+	}
+
+	if !bytes.Equal(out.Bytes(), raw) {
+		t.Errorf("Expected frame to be relayed unchanged, got %v", out.Bytes())
+	}
+	if frame.Opcode != 1 || !frame.Fin {
+		t.Errorf("Expected final text frame, got opcode %d fin %v", frame.Opcode, frame.Fin)
+	}
+	if string(frame.Payload) != "Hi" {
+		t.Errorf("Expected unmasked payload 'Hi', got '%s'", string(frame.Payload))
+	}
+	if frame.PayloadSize != 2 || frame.PayloadTruncated {
+		t.Errorf("Expected payload size 2 untruncated, got %d truncated=%v", frame.PayloadSize, frame.PayloadTruncated)
+	}
+}
+
+func TestWsFrameRecorder_MasksFragments(t *testing.T) {
+	red, err := newRedactor(&SysConfigRedaction{Patterns: []string{`secret\d+`}})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+	config := &ProxyConfig{ConfigID: "test-ws-fragments"}
+
+	rec := newWsFrameRecorder(config, nil, &LogEntry{redactor: red})
+	frames := []*ProxySessionFrameRow{
+		{Direction: WsFrameClientToServer, Opcode: 0x1, Payload: []byte("a secret1")},
+		{Direction: WsFrameServerToClient, Opcode: 0x2, Payload: []byte("secret2")},
+		{Direction: WsFrameClientToServer, Opcode: 0x9, Fin: true, Payload: []byte("ping")},
+		{Direction: WsFrameServerToClient, Opcode: 0x0, Fin: true, Payload: []byte("secret3")},
+		{Direction: WsFrameClientToServer, Opcode: 0x0, Fin: true, Payload: []byte("and secret4")},
+	}
+	for _, frame := range frames {
+		rec.record(frame)
+	}
+	rec.close()
+
+	for i, want := range []string{"a [REDACTED]", "secret2", "ping", "secret3", "and [REDACTED]"} {
+		if got := string(frames[i].Payload); got != want {
+			t.Errorf("Frame %d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	rec = newWsFrameRecorder(config, nil, &LogEntry{redactor: red, CaptureAction: CaptureNoBodies})
+	frame := &ProxySessionFrameRow{Direction: WsFrameClientToServer, Opcode: 0x2, Fin: true, Payload: []byte("data"), PayloadSize: 4}
+	rec.record(frame)
+	rec.close()
+	if frame.Payload != nil || frame.PayloadSize != 4 {
+		t.Errorf("Expected the payload dropped and its size kept, got %q (%d bytes)", frame.Payload, frame.PayloadSize)
+	}
+}
+
+func TestProxyHandler_WebSocket(t *testing.T) {
+	// 1. Echo WebSocket target
+	upgrader := websocket.Upgrader{}
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Target upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, append([]byte("echo: "), msg...)); err != nil {
+				return
+			}
+		}
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+
+	published := make(chan string, 16)
+	config := &ProxyConfig{
+		ConfigID:  "test-ws-config",
+		TargetURL: targetURL,
+		DB:        db,
+		WsPublishFn: func(topic string, v any) {
+			published <- topic
+		},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+
+	// 2. Talk through the proxy
+	wsURL := "ws" + strings.TrimPrefix(proxy.URL, "http") + "/chat"
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial through proxy failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_, msg, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	if string(msg) != "echo: hello" {
+		t.Errorf("Expected 'echo: hello', got '%s'", string(msg))
+	}
+	conn.Close()
+
+	// 3. Verify session and frames
+	var session ProxySessionRow
+	var frames []ProxySessionFrameRow
+	for i := 0; i < 40; i++ {
+		if err := db.Where("config_id = ?", "test-ws-config").First(&session).Error; err == nil {
+			frames, _ = GetSessionFrames(db, session.ID, 0, 0)
+			if len(frames) >= 2 {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if session.ResponseStatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("Expected session status 101, got %d", session.ResponseStatusCode)
+	}
+	if len(frames) < 2 {
+		t.Fatalf("Expected at least 2 frames, got %d", len(frames))
+	}
+	if frames[0].Direction != WsFrameClientToServer || string(frames[0].Payload) != "hello" {
+		t.Errorf("Unexpected first frame: %s %q", frames[0].Direction, string(frames[0].Payload))
+	}
+	if frames[1].Direction != WsFrameServerToClient || string(frames[1].Payload) != "echo: hello" {
+		t.Errorf("Unexpected second frame: %s %q", frames[1].Direction, string(frames[1].Payload))
+	}
+
+	sawFrameTopic := false
+	for len(published) > 0 {
+		if <-published == "frames" {
+			sawFrameTopic = true
+		}
+	}
+	if !sawFrameTopic {
+		t.Error("Expected frames to be published on the 'frames' topic")
+	}
+}
+
+func TestProxyHandler_WebSocketHijackFails(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err == nil {
+			conn.Close()
+		}
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	db := setupTestDB(t)
+	config := &ProxyConfig{
+		ConfigID:    "test-ws-hijack-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+
+	// A recorder cannot be hijacked, like an HTTP/2 connection
+	req := httptest.NewRequest("GET", "/chat", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	w := httptest.NewRecorder()
+	NewProxyHandler(config)(w, req)
+
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502, got %d", w.Code)
+	}
+	s := waitForSession(t, config)
+	if s.ResponseStatusCode != http.StatusBadGateway || !strings.Contains(s.ErrorMessage, "hijack") {
+		t.Errorf("Expected the session to fail with the hijack error, got %d %q", s.ResponseStatusCode, s.ErrorMessage)
+	}
+}
+
+func TestDialWsUpstream_TLSHandshakeTimeout(t *testing.T) {
+	// A target that accepts connections but never answers the TLS handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	transport, err := newUpstreamTransport(SysConfigProxyEntry{TLSHandshakeTimeout: "50ms"}, nil)
+	if err != nil {
+		t.Fatalf("newUpstreamTransport failed: %v", err)
+	}
+	start := time.Now()
+	_, err = dialWsUpstream(context.Background(), &url.URL{Scheme: "https", Host: ln.Addr().String()}, transport, nil)
+	if err == nil || time.Since(start) > 2*time.Second {
+		t.Errorf("Expected the handshake to time out after the proxy's tls-handshake-timeout, got %v after %s", err, time.Since(start))
+	}
+}
+
+func TestProxyHandler_WebSocketInvalidPayloadLength(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(mt, msg)
+		}
+	}))
+	defer mockTarget.Close()
+
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-ws-length-config",
+		TargetURL:   targetURL,
+		DB:          setupTestDB(t),
+		WsPublishFn: func(topic string, v any) {},
+	}
+	proxy := httptest.NewServer(NewProxyHandler(config))
+	defer proxy.Close()
+	wsURL := "ws" + strings.TrimPrefix(proxy.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial through proxy failed: %v", err)
+	}
+	defer conn.Close()
+	// Masked binary frame with a 64-bit length whose most significant bit is set
+	raw := []byte{0x82, 0xFF, 0x80, 0, 0, 0, 0, 0, 0, 1, 1, 2, 3, 4}
+	if _, err := conn.UnderlyingConn().Write(raw); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Error("Expected the proxy to close the socket")
+	} else if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Error("Expected the proxy to close the socket, the read timed out")
+	}
+
+	// The proxy is still serving
+	other, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("Dial after the invalid frame failed: %v", err)
+	}
+	defer other.Close()
+	if err := other.WriteMessage(websocket.TextMessage, []byte("still up")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if _, msg, err := other.ReadMessage(); err != nil || string(msg) != "still up" {
+		t.Errorf("Expected 'still up' echoed, got %q (%v)", msg, err)
+	}
+}
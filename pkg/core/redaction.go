@@ -0,0 +1,513 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+)
+
+// Where a redaction happened, recorded on sessions
+const (
+	RedactRequestURL      = "request_url"
+	RedactRequestHeaders  = "request_headers"
+	RedactRequestBody     = "request_body"
+	RedactResponseHeaders = "response_headers"
+	RedactResponseBody    = "response_body"
+	RedactFrames          = "frames"
+)
+
+// DefaultRedactionMask replaces redacted values unless the config sets another mask
+const DefaultRedactionMask = "[REDACTED]"
+
+// RedactionRuleOmit marks a header left out by omit-headers rather than masked
+const RedactionRuleOmit = "omit"
+
+// Bodies with a content encoding are masked decoded, and stored that way. Those that
+// cannot be decoded are not stored at all, nor are JSON bodies that do not parse when
+// JSON paths are masked.
+const (
+	RedactionRuleDecoded     = "decoded"
+	RedactionRuleUndecodable = "undecodable"
+	RedactionRuleUnparsable  = "unparsable"
+)
+
+// RedactionRuleRawGRPC marks a raw gRPC body left out because protobuf cannot be masked,
+// only its decoded messages are stored
+const RedactionRuleRawGRPC = "raw-grpc"
+
+// Redaction records what was masked in a session, not the masked value itself
+type Redaction struct {
+	Location string `json:"location"` // See RedactRequestURL
+	Rule     string `json:"rule"`     // "header", "cookie", "param", "json-path", "pattern", "omit", "decoded", "undecodable", "unparsable" or "raw-grpc"
+	Target   string `json:"target"`   // The header, cookie or parameter name, JSON path, pattern or content encoding
+}
+
+// redactor masks secrets in exchanges before they are stored or published
+type redactor struct {
+	headers   map[string]bool // Canonical header names
+	cookies   map[string]bool
+	params    map[string]bool
+	jsonPaths []redactJSONPath
+	patterns  []*redactPattern
+	mask      string
+	maxMatch  int // Bytes of the longest pattern match, -1 when it has no bound
+}
+
+type redactJSONPath struct {
+	path  string
+	steps []jsonPathStep
+}
+
+type redactPattern struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match []byte) bool // Extra check of a match, nil accepts all
+}
+
+// builtinRedactPatterns can be named in 'patterns' instead of writing the regexp.
+// Patterns with groups only mask the groups, which keeps e.g. the "Bearer " scheme.
+var builtinRedactPatterns = map[string]*redactPattern{
+	"bearer-token": {
+		name: "bearer-token",
+		re:   regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`),
+	},
+	"jwt": {
+		name: "jwt",
+		re:   regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+	},
+	"credit-card": {
+		name:  "credit-card",
+		re:    regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		valid: luhnValid,
+	},
+	"aws-access-key": {
+		name: "aws-access-key",
+		re:   regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	},
+}
+
+// newRedactor compiles the redaction rules of a proxy, nil when nothing is redacted
+func newRedactor(spec *SysConfigRedaction) (*redactor, error) {
+	if spec == nil {
+		return nil, nil
+	}
+
+	r := &redactor{mask: spec.Mask}
+	if r.mask == "" {
+		r.mask = DefaultRedactionMask
+	}
+	for _, name := range spec.Headers {
+		r.headers = addRedactName(r.headers, http.CanonicalHeaderKey(strings.TrimSpace(name)))
+	}
+	for _, name := range spec.Cookies {
+		r.cookies = addRedactName(r.cookies, strings.TrimSpace(name))
+	}
+	for _, name := range spec.Params {
+		r.params = addRedactName(r.params, strings.TrimSpace(name))
+	}
+	for _, path := range spec.JSONPaths {
+		steps, err := parseJSONPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("redaction: %w", err)
+		}
+		r.jsonPaths = append(r.jsonPaths, redactJSONPath{path: path, steps: steps})
+	}
+	for _, pattern := range spec.Patterns {
+		if builtin, ok := builtinRedactPatterns[pattern]; ok {
+			r.patterns = append(r.patterns, builtin)
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, &redactPattern{name: pattern, re: re})
+	}
+	for _, p := range r.patterns {
+		n := regexpMaxLen(p.re)
+		if n < 0 || r.maxMatch < 0 {
+			r.maxMatch = -1
+		} else {
+			r.maxMatch = max(r.maxMatch, n)
+		}
+	}
+
+	if r.headers == nil && r.cookies == nil && r.params == nil && len(r.jsonPaths) == 0 && len(r.patterns) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func addRedactName(names map[string]bool, name string) map[string]bool {
+	if name == "" {
+		return names
+	}
+	if names == nil {
+		names = make(map[string]bool)
+	}
+	names[name] = true
+	return names
+}
+
+// addRedaction records a redaction once
+func addRedaction(marks *[]Redaction, m Redaction) {
+	for _, existing := range *marks {
+		if existing == m {
+			return
+		}
+	}
+	*marks = append(*marks, m)
+}
+
+// redactHeaders returns a copy of h with the configured headers, cookies and patterns masked
+func (r *redactor) redactHeaders(h http.Header, location string, marks *[]Redaction) http.Header {
+	if r == nil || len(h) == 0 {
+		return h
+	}
+
+	out := h.Clone()
+	for name, values := range out {
+		canonical := http.CanonicalHeaderKey(name)
+		for i, v := range values {
+			if r.headers[canonical] {
+				values[i] = r.mask
+				addRedaction(marks, Redaction{Location: location, Rule: "header", Target: canonical})
+				continue
+			}
+			if r.cookies != nil && (canonical == "Cookie" || canonical == "Set-Cookie") {
+				v = r.maskCookies(v, canonical == "Set-Cookie", location, marks)
+			}
+			values[i] = string(r.maskPatterns([]byte(v), location, marks))
+		}
+	}
+	return out
+}
+
+// maskCookies masks the configured cookie values of a Cookie header, or of the cookie a
+// Set-Cookie header sets (its attributes are left alone)
+func (r *redactor) maskCookies(v string, setCookie bool, location string, marks *[]Redaction) string {
+	parts := strings.Split(v, ";")
+	for i, part := range parts {
+		if setCookie && i > 0 {
+			break
+		}
+		eq := strings.IndexByte(part, '=')
+		if eq < 0 {
+			continue
+		}
+		name := strings.TrimSpace(part[:eq])
+		if r.cookies[name] {
+			parts[i] = part[:eq+1] + r.mask
+			addRedaction(marks, Redaction{Location: location, Rule: "cookie", Target: name})
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// redactURL returns a copy of u with the configured query parameters and patterns masked
+func (r *redactor) redactURL(u *url.URL, marks *[]Redaction) *url.URL {
+	if r == nil || u == nil {
+		return u
+	}
+
+	out := *u
+	if r.params != nil && out.RawQuery != "" {
+		query := out.Query()
+		if r.maskValues(query, RedactRequestURL, marks) {
+			out.RawQuery = query.Encode()
+		}
+	}
+	if len(r.patterns) > 0 {
+		out.RawQuery = string(r.maskPatterns([]byte(out.RawQuery), RedactRequestURL, marks))
+		if path := string(r.maskPatterns([]byte(out.Path), RedactRequestURL, marks)); path != out.Path {
+			out.Path = path
+			out.RawPath = ""
+		}
+	}
+	return &out
+}
+
+// maskValues masks the configured parameters in values, reporting whether any was found
+func (r *redactor) maskValues(values url.Values, location string, marks *[]Redaction) bool {
+	masked := false
+	for name, vs := range values {
+		if !r.params[name] {
+			continue
+		}
+		for i := range vs {
+			vs[i] = r.mask
+		}
+		masked = true
+		addRedaction(marks, Redaction{Location: location, Rule: "param", Target: name})
+	}
+	return masked
+}
+
+// redactBody returns body with the configured JSON paths, form fields and patterns masked.
+// Bodies with a content encoding are returned decoded, or nil when they cannot be decoded
+// (e.g. because they were truncated) so that no unmasked content gets through. For the same
+// reason, JSON bodies (by their type or their first character) that do not parse are
+// dropped when JSON paths are masked, and gRPC bodies always are (see recordGRPC for
+// their masked messages).
+func (r *redactor) redactBody(body []byte, h http.Header, location string, marks *[]Redaction) []byte {
+	if r == nil || len(body) == 0 {
+		return body
+	}
+	if contentType := h.Get("Content-Type"); isGRPCContentType(contentType) {
+		addRedaction(marks, Redaction{Location: location, Rule: RedactionRuleRawGRPC, Target: contentType})
+		return nil
+	}
+	if !isIdentityEncoding(h) {
+		encoding := strings.ToLower(strings.TrimSpace(h.Get("Content-Encoding")))
+		decoded, err := decodeContent(body, encoding)
+		if err != nil {
+			addRedaction(marks, Redaction{Location: location, Rule: RedactionRuleUndecodable, Target: encoding})
+			return nil
+		}
+		addRedaction(marks, Redaction{Location: location, Rule: RedactionRuleDecoded, Target: encoding})
+		body = decoded
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") || looksLikeJSON(body):
+		masked, ok := r.maskJSON(body, location, marks)
+		if !ok && len(r.jsonPaths) > 0 {
+			addRedaction(marks, Redaction{Location: location, Rule: RedactionRuleUnparsable, Target: "json"})
+			return nil
+		}
+		body = masked
+	case mediaType == "application/x-www-form-urlencoded" && r.params != nil:
+		if form, err := url.ParseQuery(string(body)); err == nil && r.maskValues(form, location, marks) {
+			body = []byte(form.Encode())
+		}
+	}
+	return r.maskPatterns(body, location, marks)
+}
+
+// redactFrame returns the payload of a text WebSocket frame with the configured JSON paths
+// and patterns masked
+func (r *redactor) redactFrame(payload []byte, marks *[]Redaction) []byte {
+	return r.redactText(payload, RedactFrames, marks)
+}
+
+// redactText returns a JSON or text document with the configured JSON paths and patterns masked
+func (r *redactor) redactText(text []byte, location string, marks *[]Redaction) []byte {
+	if r == nil || len(text) == 0 {
+		return text
+	}
+	masked, _ := r.maskJSON(text, location, marks)
+	return r.maskPatterns(masked, location, marks)
+}
+
+// looksLikeJSON reports whether body starts like a JSON object or array
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimLeft(body, " \t\r\n")
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')
+}
+
+// maskJSON masks the configured paths of a JSON document. It is returned unchanged when it
+// does not parse, e.g. because it was truncated, which ok reports.
+func (r *redactor) maskJSON(body []byte, location string, marks *[]Redaction) (out []byte, ok bool) {
+	if len(r.jsonPaths) == 0 {
+		return body, true
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return body, false
+	}
+
+	masked := false
+	for _, p := range r.jsonPaths {
+		if maskJSONPath(doc, p.steps, r.mask) {
+			masked = true
+			addRedaction(marks, Redaction{Location: location, Rule: "json-path", Target: p.path})
+		}
+	}
+	if !masked {
+		return body, true
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return body, false
+	}
+	return out, true
+}
+
+// maskJSONPath replaces the value at steps with mask when it exists
+func maskJSONPath(node any, steps []jsonPathStep, mask string) bool {
+	step := steps[0]
+
+	var child any
+	var set func(v any)
+	if step.isIndex {
+		list, ok := node.([]any)
+		if !ok || step.index >= len(list) {
+			return false
+		}
+		child = list[step.index]
+		set = func(v any) { list[step.index] = v }
+	} else {
+		obj, ok := node.(map[string]any)
+		if !ok {
+			return false
+		}
+		if child, ok = obj[step.key]; !ok {
+			return false
+		}
+		set = func(v any) { obj[step.key] = v }
+	}
+
+	if len(steps) == 1 {
+		set(mask)
+		return true
+	}
+	return maskJSONPath(child, steps[1:], mask)
+}
+
+// maskPatterns masks the pattern matches in text, only their groups when they have some.
+// Binary content is left alone.
+func (r *redactor) maskPatterns(text []byte, location string, marks *[]Redaction) []byte {
+	if len(r.patterns) == 0 || len(text) == 0 || !utf8.Valid(text) {
+		return text
+	}
+
+	for _, p := range r.patterns {
+		var out []byte
+		last := 0
+		for _, m := range p.re.FindAllSubmatchIndex(text, -1) {
+			if p.valid != nil && !p.valid(text[m[0]:m[1]]) {
+				continue
+			}
+			spans := [][2]int{{m[0], m[1]}}
+			if len(m) > 2 {
+				spans = spans[:0]
+				for i := 2; i+1 < len(m); i += 2 {
+					if m[i] >= 0 {
+						spans = append(spans, [2]int{m[i], m[i+1]})
+					}
+				}
+			}
+			for _, span := range spans {
+				if span[0] < last {
+					continue // Nested in a group already masked
+				}
+				out = append(out, text[last:span[0]]...)
+				out = append(out, r.mask...)
+				last = span[1]
+			}
+		}
+		if out == nil {
+			continue
+		}
+		text = append(out, text[last:]...)
+		addRedaction(marks, Redaction{Location: location, Rule: "pattern", Target: p.name})
+	}
+	return text
+}
+
+// patternCut moves cut back until it splits no pattern match in text, nor a UTF-8
+// sequence, so that text[:cut] is masked as it would be as part of text
+func (r *redactor) patternCut(text []byte, cut int) int {
+	for moved := true; moved; {
+		moved = false
+		for cut > 0 && cut < len(text) && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		for _, p := range r.patterns {
+			for _, m := range p.re.FindAllIndex(text, -1) {
+				if m[0] < cut && cut < m[1] {
+					cut, moved = m[0], true
+				}
+			}
+		}
+	}
+	return cut
+}
+
+// regexpMaxLen returns the bytes of the longest match of re, -1 when it has no bound
+func regexpMaxLen(re *regexp.Regexp) int {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil {
+		return -1
+	}
+	return syntaxMaxLen(parsed.Simplify())
+}
+
+func syntaxMaxLen(re *syntax.Regexp) int {
+	switch re.Op {
+	case syntax.OpLiteral:
+		n := 0
+		for _, c := range re.Rune {
+			if re.Flags&syntax.FoldCase != 0 {
+				n += utf8.UTFMax
+			} else {
+				n += utf8.RuneLen(c)
+			}
+		}
+		return n
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return 0
+		}
+		return utf8.RuneLen(min(re.Rune[len(re.Rune)-1], utf8.MaxRune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return utf8.UTFMax
+	case syntax.OpCapture, syntax.OpQuest:
+		return syntaxMaxLen(re.Sub[0])
+	case syntax.OpStar, syntax.OpPlus:
+		return -1
+	case syntax.OpRepeat:
+		n := syntaxMaxLen(re.Sub[0])
+		if re.Max < 0 || n < 0 {
+			return -1
+		}
+		return re.Max * n
+	case syntax.OpConcat, syntax.OpAlternate:
+		total := 0
+		for _, sub := range re.Sub {
+			n := syntaxMaxLen(sub)
+			switch {
+			case n < 0:
+				return -1
+			case re.Op == syntax.OpConcat:
+				total += n
+			default:
+				total = max(total, n)
+			}
+		}
+		return total
+	}
+	return 0 // Empty matches and assertions
+}
+
+// luhnValid reports whether the digits of a card number candidate pass the Luhn check
+func luhnValid(match []byte) bool {
+	sum, n := 0, 0
+	for i := len(match) - 1; i >= 0; i-- {
+		c := match[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
@@ -0,0 +1,184 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRedactor(t *testing.T) {
+	r, err := newRedactor(&SysConfigRedaction{
+		Headers:   []string{"x-api-key"},
+		Cookies:   []string{"sid"},
+		Params:    []string{"token"},
+		JSONPaths: []string{"$.password", "$.cards[0].number"},
+		Patterns:  []string{"bearer-token", "credit-card"},
+	})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	var marks []Redaction
+	h := r.redactHeaders(http.Header{
+		"X-Api-Key":     {"secret"},
+		"Authorization": {"Bearer abc.def"},
+		"Cookie":        {"theme=dark; sid=s3cr3t"},
+		"Set-Cookie":    {"sid=s3cr3t; Path=/; HttpOnly"},
+	}, RedactRequestHeaders, &marks)
+	want := http.Header{
+		"X-Api-Key":     {"[REDACTED]"},
+		"Authorization": {"Bearer [REDACTED]"},
+		"Cookie":        {"theme=dark; sid=[REDACTED]"},
+		"Set-Cookie":    {"sid=[REDACTED]; Path=/; HttpOnly"},
+	}
+	for name := range want {
+		if h.Get(name) != want.Get(name) {
+			t.Errorf("%s: expected %q, got %q", name, want.Get(name), h.Get(name))
+		}
+	}
+
+	u := r.redactURL(&url.URL{Path: "/search", RawQuery: "q=go&token=abc"}, &marks)
+	if u.Query().Get("token") != "[REDACTED]" || u.Query().Get("q") != "go" {
+		t.Errorf("Expected the token param to be masked, got %q", u.RawQuery)
+	}
+
+	body := r.redactBody(
+		[]byte(`{"user":"ann","password":"hunter2","cards":[{"number":"4111111111111111"}],"order":"1234567890123","note":"paid with 4111 1111 1111 1111"}`),
+		http.Header{"Content-Type": {"application/json"}}, RedactRequestBody, &marks)
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatalf("Expected JSON, got %s", body)
+	}
+	if doc["password"] != "[REDACTED]" || doc["user"] != "ann" || strings.Contains(string(body), "4111") {
+		t.Errorf("Expected password and card masked, got %s", body)
+	}
+	if doc["order"] != "1234567890123" {
+		t.Errorf("Expected a number failing the Luhn check to be kept, got %s", body)
+	}
+
+	form := r.redactBody([]byte("user=ann&token=abc"), http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}, RedactRequestBody, &marks)
+	if string(form) != "token=%5BREDACTED%5D&user=ann" {
+		t.Errorf("Expected the token field masked, got %s", form)
+	}
+
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte(`{"password":"hunter2"}`))
+	zw.Close()
+	gzipHeader := http.Header{"Content-Type": {"application/json"}, "Content-Encoding": {"gzip"}}
+	if got := r.redactBody(gzipped.Bytes(), gzipHeader, RedactResponseBody, &marks); string(got) != `{"password":"[REDACTED]"}` {
+		t.Errorf("Expected encoded bodies to be masked decoded, got %q", got)
+	}
+	if got := r.redactBody(gzipped.Bytes()[:gzipped.Len()/2], gzipHeader, RedactRequestBody, &marks); got != nil {
+		t.Errorf("Expected a body that cannot be decoded to be dropped, got %q", got)
+	}
+
+	// A JSON body cut by max-capture-bytes cannot have its paths masked, nor can JSON sent as text
+	if got := r.redactBody([]byte(`{"user":"ann","password":"hunt`), http.Header{"Content-Type": {"application/json"}}, RedactResponseBody, &marks); got != nil {
+		t.Errorf("Expected a truncated JSON body to be dropped, got %q", got)
+	}
+	if got := r.redactBody([]byte(`{"password":"hunter2"}`), http.Header{"Content-Type": {"text/plain"}}, RedactResponseBody, &marks); string(got) != `{"password":"[REDACTED]"}` {
+		t.Errorf("Expected JSON with another type to be masked, got %q", got)
+	}
+	if got := r.redactBody([]byte("plain text"), http.Header{"Content-Type": {"text/plain"}}, RedactResponseBody, &marks); string(got) != "plain text" {
+		t.Errorf("Expected text to be kept, got %q", got)
+	}
+
+	seen := map[Redaction]bool{}
+	for _, m := range marks {
+		if seen[m] {
+			t.Errorf("Duplicate redaction %+v", m)
+		}
+		seen[m] = true
+	}
+	for _, m := range []Redaction{
+		{RedactRequestHeaders, "header", "X-Api-Key"},
+		{RedactRequestHeaders, "cookie", "sid"},
+		{RedactRequestHeaders, "pattern", "bearer-token"},
+		{RedactRequestURL, "param", "token"},
+		{RedactRequestBody, "json-path", "$.password"},
+		{RedactRequestBody, "pattern", "credit-card"},
+		{RedactResponseBody, RedactionRuleDecoded, "gzip"},
+		{RedactResponseBody, "json-path", "$.password"},
+		{RedactRequestBody, RedactionRuleUndecodable, "gzip"},
+		{RedactResponseBody, RedactionRuleUnparsable, "json"},
+	} {
+		if !seen[m] {
+			t.Errorf("Expected redaction %+v, got %+v", m, marks)
+		}
+	}
+
+	if r, err := newRedactor(&SysConfigRedaction{Mask: "***"}); r != nil || err != nil {
+		t.Errorf("Expected no redactor without rules, got %v %v", r, err)
+	}
+	for _, spec := range []SysConfigRedaction{
+		{JSONPaths: []string{"password"}},
+		{Patterns: []string{"(unclosed"}},
+	} {
+		if _, err := newRedactor(&spec); err == nil {
+			t.Errorf("Expected %+v to be rejected", spec)
+		}
+	}
+}
+
+func TestProxyHandler_Redaction(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			t.Errorf("Expected the target to get the real key, got %q", r.Header.Get("X-Api-Key"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token":"t0k3n","name":"ann"}`))
+	}))
+	defer mockTarget.Close()
+
+	redact, err := newRedactor(&SysConfigRedaction{
+		Headers:   []string{"X-Api-Key"},
+		JSONPaths: []string{"$.token"},
+		Mask:      "***",
+	})
+	if err != nil {
+		t.Fatalf("newRedactor failed: %v", err)
+	}
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-redaction-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+		Redact:      redact,
+	}
+	handler := NewProxyHandler(config)
+
+	req := httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("X-Api-Key", "secret")
+	w := httptest.NewRecorder()
+	handler(w, req)
+	if !strings.Contains(w.Body.String(), "t0k3n") {
+		t.Errorf("Expected the client to get the real body, got %s", w.Body.String())
+	}
+
+	s := waitForSession(t, config)
+	if strings.Contains(string(s.RequestHeaders), "secret") || !strings.Contains(string(s.RequestHeaders), "***") {
+		t.Errorf("Expected the stored key masked, got %s", s.RequestHeaders)
+	}
+	if string(s.ResponseBody) != `{"name":"ann","token":"***"}` {
+		t.Errorf("Expected the stored token masked, got %s", s.ResponseBody)
+	}
+	var marks []Redaction
+	json.Unmarshal(s.Redactions, &marks)
+	if len(marks) != 2 || marks[0].Target != "X-Api-Key" || marks[1].Target != "$.token" {
+		t.Errorf("Expected the header and JSON path redactions, got %s", s.Redactions)
+	}
+
+	found, err := SearchSessions(db, config.ConfigID, "t0k3n", 10, 0)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Expected the masked token to be missing from the search index, got %d sessions (%v)", len(found), err)
+	}
+}
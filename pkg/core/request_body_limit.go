@@ -0,0 +1,61 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// What happens to a buffered request body over the proxy's max-request-body
+const (
+	BodyOverflowReject   = "reject"   // Answered with 413, the target is not called (default)
+	BodyOverflowPass     = "pass"     // Relayed in full, the body is not stored
+	BodyOverflowTruncate = "truncate" // Relayed in full, only its first max-request-body bytes are stored
+)
+
+func validateRequestBodyLimit(entry SysConfigProxyEntry) error {
+	if entry.MaxRequestBody < 0 {
+		return fmt.Errorf("invalid 'max-request-body': %d", entry.MaxRequestBody)
+	}
+	switch entry.RequestBodyOverflow {
+	case "", BodyOverflowReject, BodyOverflowPass, BodyOverflowTruncate:
+		return nil
+	}
+	return fmt.Errorf("invalid 'request-body-overflow': %q, use %q, %q or %q",
+		entry.RequestBodyOverflow, BodyOverflowReject, BodyOverflowPass, BodyOverflowTruncate)
+}
+
+// rejectRequestBody answers a request whose body is over the limit with 413 and records it,
+// keeping the part of the body that was read
+func rejectRequestBody(config *ProxyConfig, w http.ResponseWriter, r *http.Request, entry *LogEntry, read []byte) {
+	log.Warn().Int("limit", config.requestBodyLimit()).Str("path", entry.RequestURL.Path).Msg("Request body exceeds limit, rejecting it")
+
+	body := http.StatusText(http.StatusRequestEntityTooLarge) + "\n"
+	http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+
+	entry.RequestBody, _, _ = captureBody(read[:config.requestBodyLimit()], config.captureLimit())
+	entry.RequestBodySize = max(int(r.ContentLength), len(read))
+	entry.RequestBodyTruncated = true
+	entry.StatusCode = http.StatusRequestEntityTooLarge
+	entry.ResponseHeaders = w.Header().Clone()
+	entry.ResponseBody = []byte(body)
+	entry.ResponseBodySize = len(body)
+	entry.Duration = time.Since(entry.Timestamp)
+	printIncomingRequest(entry)
+
+	if config.DB == nil {
+		return
+	}
+	go func(e *LogEntry) {
+		s, err := recordSession(config, nil, e)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to record rejected session in database")
+			return
+		}
+		if s != nil {
+			config.WsPublishFn("sessions", FormatSessionStub(s))
+		}
+	}(entry)
+}
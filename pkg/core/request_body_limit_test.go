@@ -0,0 +1,67 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxyHandler_RequestBodyOverflow(t *testing.T) {
+	var received int
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = len(body)
+		w.Write([]byte("ok"))
+	}))
+	defer mockTarget.Close()
+
+	body := strings.Repeat("x", 100)
+	tests := []struct {
+		overflow   string
+		wantStatus int
+		wantTarget int
+		wantStored int
+	}{
+		{"", http.StatusRequestEntityTooLarge, 0, 40},
+		{BodyOverflowPass, http.StatusOK, 100, 0},
+		{BodyOverflowTruncate, http.StatusOK, 100, 40},
+	}
+	for _, tt := range tests {
+		if err := validateRequestBodyLimit(SysConfigProxyEntry{RequestBodyOverflow: tt.overflow}); err != nil {
+			t.Fatalf("%q: unexpected error %v", tt.overflow, err)
+		}
+
+		received = 0
+		targetURL, _ := url.Parse(mockTarget.URL)
+		config := &ProxyConfig{
+			ConfigID:       "test-overflow-" + tt.overflow,
+			TargetURL:      targetURL,
+			DB:             setupTestDB(t),
+			WsPublishFn:    func(topic string, v any) {},
+			MaxRequestBody: 40,
+			BodyOverflow:   tt.overflow,
+		}
+		w := httptest.NewRecorder()
+		NewProxyHandler(config)(w, httptest.NewRequest("POST", "/upload", strings.NewReader(body)))
+		if w.Code != tt.wantStatus || received != tt.wantTarget {
+			t.Errorf("%q: expected %d with %d bytes at the target, got %d with %d", tt.overflow, tt.wantStatus, tt.wantTarget, w.Code, received)
+		}
+
+		s := waitForSession(t, config)
+		wantOverflow := tt.overflow
+		if wantOverflow == "" {
+			wantOverflow = BodyOverflowReject
+		}
+		if s.RequestBodyOverflow != wantOverflow || len(s.RequestBody) != tt.wantStored || s.RequestBodySize != 100 || !s.RequestBodyTruncated {
+			t.Errorf("%q: expected %s with %d stored of 100 bytes, got %q with %d of %d (truncated %v)",
+				tt.overflow, wantOverflow, tt.wantStored, s.RequestBodyOverflow, len(s.RequestBody), s.RequestBodySize, s.RequestBodyTruncated)
+		}
+	}
+
+	if err := validateRequestBodyLimit(SysConfigProxyEntry{RequestBodyOverflow: "drop"}); err == nil {
+		t.Error("Expected an unknown overflow to be rejected")
+	}
+}
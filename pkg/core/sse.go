@@ -0,0 +1,134 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+)
+
+// sseParser splits a text/event-stream into events as its chunks arrive, following the
+// HTML event stream format: "field: value" lines, a blank line ending each event
+type sseParser struct {
+	start   time.Time // Offsets are measured from here
+	started bool      // Past the byte order mark the stream may start with
+	line    []byte    // Incomplete line carried over to the next chunk
+	afterCR bool      // The last line ended with \r, a \n right after it belongs to it
+
+	pending bool // A field of the current event was set
+	hasData bool
+	data    bytes.Buffer
+	current ProxySessionEventRow
+
+	events []*ProxySessionEventRow // Up to MaxSSEEvents
+}
+
+// newSSEParser returns nil when the stream is compressed, its events cannot be read
+func newSSEParser(start time.Time, compressed bool) *sseParser {
+	if compressed {
+		return nil
+	}
+	return &sseParser{start: start}
+}
+
+// feed parses a chunk of the stream that arrived at now
+func (p *sseParser) feed(chunk []byte, now time.Time) {
+	if p == nil {
+		return
+	}
+	if !p.started {
+		p.started = true
+		chunk = bytes.TrimPrefix(chunk, []byte("\xEF\xBB\xBF"))
+	}
+
+	for len(chunk) > 0 {
+		if p.afterCR {
+			p.afterCR = false
+			if chunk[0] == '\n' {
+				chunk = chunk[1:]
+				continue
+			}
+		}
+		i := bytes.IndexAny(chunk, "\r\n")
+		if i < 0 {
+			p.appendLine(chunk)
+			return
+		}
+		p.appendLine(chunk[:i])
+		p.afterCR = chunk[i] == '\r'
+		chunk = chunk[i+1:]
+		p.processLine(now)
+		p.line = p.line[:0]
+	}
+}
+
+// appendLine adds to the current line, dropping what goes past MaxSSELineSize
+func (p *sseParser) appendLine(b []byte) {
+	if room := MaxSSELineSize - len(p.line); room > 0 {
+		p.line = append(p.line, b[:min(len(b), room)]...)
+	}
+}
+
+func (p *sseParser) processLine(now time.Time) {
+	if len(p.line) == 0 {
+		p.dispatch(now)
+		return
+	}
+	if p.line[0] == ':' {
+		return // Comment, often sent to keep the connection alive
+	}
+
+	field, value, _ := bytes.Cut(p.line, []byte(":"))
+	value = bytes.TrimPrefix(value, []byte(" "))
+	switch string(field) {
+	case "data":
+		if p.hasData {
+			p.data.WriteByte('\n')
+		}
+		p.data.Write(value)
+		p.hasData = true
+	case "event":
+		p.current.Event = string(value)
+	case "id":
+		if bytes.IndexByte(value, 0) >= 0 {
+			return
+		}
+		p.current.EventID = string(value)
+	case "retry":
+		retry, err := strconv.Atoi(string(value))
+		if err != nil || retry < 0 || bytes.ContainsAny(value, "+-") {
+			return
+		}
+		p.current.Retry = &retry
+	default:
+		return // Unknown fields are ignored
+	}
+	p.pending = true
+}
+
+// dispatch ends the current event, keeping it when any field was set
+func (p *sseParser) dispatch(now time.Time) {
+	if !p.pending {
+		return
+	}
+	event := p.current
+	event.Data = p.data.String()
+	p.current, p.pending, p.hasData = ProxySessionEventRow{}, false, false
+	p.data.Reset()
+
+	if len(p.events) >= MaxSSEEvents {
+		return
+	}
+	event.Seq = int64(len(p.events) + 1)
+	event.Timestamp = now
+	event.OffsetMs = now.Sub(p.start).Milliseconds()
+	p.events = append(p.events, &event)
+}
+
+// Events returns the complete events, one left unfinished when the stream ended is not
+// part of them, as clients drop it too
+func (p *sseParser) Events() []*ProxySessionEventRow {
+	if p == nil {
+		return nil
+	}
+	return p.events
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestSSEParser(t *testing.T) {
+	start := time.Now()
+	p := newSSEParser(start, false)
+
+	// Chunks split lines and line endings anywhere
+	chunks := []string{
+		"\xEF\xBB\xBF: keep-alive\n\nid: 1\nevent: tok",
+		"en\ndata: hel",
+		"lo\ndata: world\r",
+		"\n\r\n",
+		"retry: 3000\nretry: x\nunknown: y\n\n",
+		"data\n\ndata: dropped at the end",
+	}
+	for i, chunk := range chunks {
+		p.feed([]byte(chunk), start.Add(time.Duration(i)*10*time.Millisecond))
+	}
+
+	events := p.Events()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	first := events[0]
+	if first.EventID != "1" || first.Event != "token" || first.Data != "hello\nworld" || first.Retry != nil {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if first.Seq != 1 || first.OffsetMs != 30 {
+		t.Errorf("Expected the first event to end with the 4th chunk, got seq %d at %dms", first.Seq, first.OffsetMs)
+	}
+	if events[1].Retry == nil || *events[1].Retry != 3000 || events[1].Data != "" {
+		t.Errorf("Expected a retry only event, got %+v", events[1])
+	}
+	if events[2].Data != "" || events[2].OffsetMs != 50 {
+		t.Errorf("Expected an empty data event, got %+v", events[2])
+	}
+}
+
+func TestProxyHandler_SSEEvents(t *testing.T) {
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		for i := 1; i <= 3; i++ {
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprintf(w, "id: %d\ndata: {\"token\":\"t%d\"}\n\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-sse-events-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+	handler := NewProxyHandler(config)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/v1/completions", nil))
+
+	s := waitForSession(t, config)
+	var events []ProxySessionEventRow
+	for i := 0; i < 20 && len(events) < 3; i++ {
+		events, _ = GetSessionEvents(db, s.ID)
+		time.Sleep(50 * time.Millisecond)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 stored events, got %d", len(events))
+	}
+	for i, e := range events {
+		if e.EventID != fmt.Sprint(i+1) || e.Data != fmt.Sprintf(`{"token":"t%d"}`, i+1) {
+			t.Errorf("Unexpected event %d: %+v", i, e)
+		}
+		if i > 0 && e.OffsetMs <= events[i-1].OffsetMs {
+			t.Errorf("Expected offsets to grow, got %d after %d", e.OffsetMs, events[i-1].OffsetMs)
+		}
+	}
+	if events[0].OffsetMs < 20 {
+		t.Errorf("Expected the first event after the target's delay, got %dms", events[0].OffsetMs)
+	}
+}
+
+func TestProxyHandler_SSECompressed(t *testing.T) {
+	var stream bytes.Buffer
+	zw := gzip.NewWriter(&stream)
+	fmt.Fprint(zw, "id: 1\ndata: hello\n\n")
+	zw.Close()
+
+	mockTarget := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusOK)
+		w.Write(stream.Bytes())
+	}))
+	defer mockTarget.Close()
+
+	db := setupTestDB(t)
+	targetURL, _ := url.Parse(mockTarget.URL)
+	config := &ProxyConfig{
+		ConfigID:    "test-sse-compressed-config",
+		TargetURL:   targetURL,
+		DB:          db,
+		WsPublishFn: func(topic string, v any) {},
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/v1/completions", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	NewProxyHandler(config)(w, req)
+
+	if !bytes.Equal(w.Body.Bytes(), stream.Bytes()) {
+		t.Errorf("Expected the compressed stream to be relayed unchanged, got %q", w.Body.Bytes())
+	}
+	s := waitForSession(t, config)
+	if events, _ := GetSessionEvents(db, s.ID); len(events) != 0 {
+		t.Errorf("Expected no events parsed from a compressed stream, got %+v", events)
+	}
+}
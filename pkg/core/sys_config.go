@@ -11,11 +11,137 @@ type SysConfig struct {
 	Proxies           []SysConfigProxyEntry `mapstructure:"proxies" json:"proxies" toml:"proxies"`
 }
 
+// Proxy modes
+const (
+	// ProxyModeReverse forwards every request to the fixed target (default)
+	ProxyModeReverse = "reverse"
+	// ProxyModeForward acts as an explicit proxy, taking the target from each request
+	ProxyModeForward = "forward"
+	// ProxyModeReplay answers every request from recorded sessions, never calling a target
+	ProxyModeReplay = "replay"
+	// ProxyModeFallback proxies like reverse mode, replaying recorded sessions when the target is unreachable
+	ProxyModeFallback = "fallback"
+)
+
 // ProxyEntry represents a single proxy configuration
 type SysConfigProxyEntry struct {
 	Listen          string `mapstructure:"listen" json:"listen" toml:"listen"`
 	Target          string `mapstructure:"target" json:"target" toml:"target"`
+	Mode            string `mapstructure:"mode" json:"mode,omitempty" toml:"mode,omitempty"`
 	TruncateLogBody bool   `mapstructure:"truncate-log-body" json:"truncate_log_body" toml:"truncate-log-body"`
-	Active          bool   `mapstructure:"-" json:"active" toml:"-"`
-	Error           string `mapstructure:"-" json:"error" toml:"-"`
+	TLSCert         string `mapstructure:"tls-cert" json:"tls_cert,omitempty" toml:"tls-cert,omitempty"`
+	TLSKey          string `mapstructure:"tls-key" json:"tls_key,omitempty" toml:"tls-key,omitempty"`
+	AutoTLS         bool   `mapstructure:"auto-tls" json:"auto_tls,omitempty" toml:"auto-tls,omitempty"`
+	HTTP2           bool   `mapstructure:"http2" json:"http2,omitempty" toml:"http2,omitempty"` // Accept HTTP/2 over TLS and h2c on plain listeners
+
+	UpstreamCABundle           string `mapstructure:"upstream-ca-bundle" json:"upstream_ca_bundle,omitempty" toml:"upstream-ca-bundle,omitempty"`
+	UpstreamClientCert         string `mapstructure:"upstream-client-cert" json:"upstream_client_cert,omitempty" toml:"upstream-client-cert,omitempty"`
+	UpstreamClientKey          string `mapstructure:"upstream-client-key" json:"upstream_client_key,omitempty" toml:"upstream-client-key,omitempty"`
+	UpstreamServerName         string `mapstructure:"upstream-server-name" json:"upstream_server_name,omitempty" toml:"upstream-server-name,omitempty"`
+	UpstreamInsecureSkipVerify bool   `mapstructure:"upstream-insecure-skip-verify" json:"upstream_insecure_skip_verify,omitempty" toml:"upstream-insecure-skip-verify,omitempty"`
+
+	// Upstream connection pool, durations use Go syntax like "30s"
+	MaxIdleConns          int    `mapstructure:"max-idle-conns" json:"max_idle_conns,omitempty" toml:"max-idle-conns,omitempty"`
+	MaxIdleConnsPerHost   int    `mapstructure:"max-idle-conns-per-host" json:"max_idle_conns_per_host,omitempty" toml:"max-idle-conns-per-host,omitempty"`
+	IdleConnTimeout       string `mapstructure:"idle-conn-timeout" json:"idle_conn_timeout,omitempty" toml:"idle-conn-timeout,omitempty"`
+	DialTimeout           string `mapstructure:"dial-timeout" json:"dial_timeout,omitempty" toml:"dial-timeout,omitempty"`
+	TLSHandshakeTimeout   string `mapstructure:"tls-handshake-timeout" json:"tls_handshake_timeout,omitempty" toml:"tls-handshake-timeout,omitempty"`
+	ResponseHeaderTimeout string `mapstructure:"response-header-timeout" json:"response_header_timeout,omitempty" toml:"response-header-timeout,omitempty"`
+	DisableUpstreamHTTP2  bool   `mapstructure:"disable-upstream-http2" json:"disable_upstream_http2,omitempty" toml:"disable-upstream-http2,omitempty"`
+	UpstreamH2C           bool   `mapstructure:"upstream-h2c" json:"upstream_h2c,omitempty" toml:"upstream-h2c,omitempty"` // Cleartext HTTP/2 to http:// targets
+
+	// Body handling
+	StreamBodies    bool `mapstructure:"stream-bodies" json:"stream_bodies,omitempty" toml:"stream-bodies,omitempty"`
+	MaxCaptureBytes int  `mapstructure:"max-capture-bytes" json:"max_capture_bytes,omitempty" toml:"max-capture-bytes,omitempty"`
+
+	// Buffered request bodies over MaxRequestBody bytes are handled by RequestBodyOverflow,
+	// "reject" (default), "pass" or "truncate"
+	MaxRequestBody      int    `mapstructure:"max-request-body" json:"max_request_body,omitempty" toml:"max-request-body,omitempty"`
+	RequestBodyOverflow string `mapstructure:"request-body-overflow" json:"request_body_overflow,omitempty" toml:"request-body-overflow,omitempty"`
+
+	// Several targets balanced by LoadBalance, used instead of Target
+	Targets       []string `mapstructure:"targets" json:"targets,omitempty" toml:"targets,omitempty"`
+	TargetWeights []int    `mapstructure:"target-weights" json:"target_weights,omitempty" toml:"target-weights,omitempty"`
+	LoadBalance   string   `mapstructure:"load-balance" json:"load_balance,omitempty" toml:"load-balance,omitempty"`
+	MaxFails      int      `mapstructure:"max-fails" json:"max_fails,omitempty" toml:"max-fails,omitempty"`
+	FailTimeout   string   `mapstructure:"fail-timeout" json:"fail_timeout,omitempty" toml:"fail-timeout,omitempty"`
+	MaxRetries    int      `mapstructure:"max-retries" json:"max_retries,omitempty" toml:"max-retries,omitempty"`
+
+	// Routes send matching requests to their own target, others go to Target
+	Routes []SysConfigProxyRoute `mapstructure:"routes" json:"routes,omitempty" toml:"routes,omitempty"`
+
+	// Replay and fallback modes answer from the sessions of these config IDs, default this proxy's own
+	ReplayFrom      []string `mapstructure:"replay-from" json:"replay_from,omitempty" toml:"replay-from,omitempty"`
+	ReplayMatchBody bool     `mapstructure:"replay-match-body" json:"replay_match_body,omitempty" toml:"replay-match-body,omitempty"`
+
+	// Faults injected into the traffic to test how clients cope, can be changed at runtime
+	Faults *SysConfigProxyFaults `mapstructure:"faults" json:"faults,omitempty" toml:"faults,omitempty"`
+
+	// Capture filters decide how each exchange is recorded, the first matching one wins.
+	// Exchanges no filter matches use CaptureDefault, which defaults to "store".
+	CaptureFilters []SysConfigCaptureFilter `mapstructure:"capture-filters" json:"capture_filters,omitempty" toml:"capture-filters,omitempty"`
+	CaptureDefault string                   `mapstructure:"capture-default" json:"capture_default,omitempty" toml:"capture-default,omitempty"`
+
+	// Headers left out of stored sessions and the console output, can be changed at runtime
+	OmitHeaders []string `mapstructure:"omit-headers" json:"omit_headers,omitempty" toml:"omit-headers,omitempty"`
+
+	// Secrets masked in sessions before they are stored
+	Redact *SysConfigRedaction `mapstructure:"redact" json:"redact,omitempty" toml:"redact,omitempty"`
+
+	// Protobuf descriptor set (.protoset) decoding the messages of gRPC calls to JSON
+	Protoset string `mapstructure:"protoset" json:"protoset,omitempty" toml:"protoset,omitempty"`
+
+	Active bool   `mapstructure:"-" json:"active" toml:"-"`
+	Error  string `mapstructure:"-" json:"error" toml:"-"`
+}
+
+// SysConfigProxyRoute sends requests matching all of its set matchers to Target
+type SysConfigProxyRoute struct {
+	Name        string            `mapstructure:"name" json:"name,omitempty" toml:"name,omitempty"`
+	PathPrefix  string            `mapstructure:"path-prefix" json:"path_prefix,omitempty" toml:"path-prefix,omitempty"`
+	Host        string            `mapstructure:"host" json:"host,omitempty" toml:"host,omitempty"`
+	Headers     map[string]string `mapstructure:"headers" json:"headers,omitempty" toml:"headers,omitempty"`
+	Target      string            `mapstructure:"target" json:"target" toml:"target"`
+	StripPrefix bool              `mapstructure:"strip-prefix" json:"strip_prefix,omitempty" toml:"strip-prefix,omitempty"`
+}
+
+// SysConfigCaptureFilter applies Action to exchanges matching all of its set matchers
+type SysConfigCaptureFilter struct {
+	Name         string   `mapstructure:"name" json:"name,omitempty" toml:"name,omitempty"`
+	Paths        []string `mapstructure:"paths" json:"paths,omitempty" toml:"paths,omitempty"`                         // Globs, "*" stays within a segment and "**" crosses them
+	Methods      []string `mapstructure:"methods" json:"methods,omitempty" toml:"methods,omitempty"`                   // Request methods
+	ContentTypes []string `mapstructure:"content-types" json:"content_types,omitempty" toml:"content-types,omitempty"` // Response media types, like "image/*"
+	StatusCodes  []string `mapstructure:"status-codes" json:"status_codes,omitempty" toml:"status-codes,omitempty"`    // Like "404", "5xx" or "300-399"
+	MinBodySize  int      `mapstructure:"min-body-size" json:"min_body_size,omitempty" toml:"min-body-size,omitempty"` // Request or response body of at least this many bytes
+	MaxBodySize  int      `mapstructure:"max-body-size" json:"max_body_size,omitempty" toml:"max-body-size,omitempty"` // Request and response bodies of at most this many bytes
+	Action       string   `mapstructure:"action" json:"action" toml:"action"`                                          // "store", "no-bodies" or "count"
+}
+
+// SysConfigRedaction lists what is masked in sessions before they are stored or published
+type SysConfigRedaction struct {
+	Headers   []string `mapstructure:"headers" json:"headers,omitempty" toml:"headers,omitempty"`          // Header values masked whole
+	Cookies   []string `mapstructure:"cookies" json:"cookies,omitempty" toml:"cookies,omitempty"`          // Cookie values in Cookie and Set-Cookie
+	Params    []string `mapstructure:"params" json:"params,omitempty" toml:"params,omitempty"`             // Query parameters and form fields
+	JSONPaths []string `mapstructure:"json-paths" json:"json_paths,omitempty" toml:"json-paths,omitempty"` // Values in JSON bodies, like "$.user.password"
+	Patterns  []string `mapstructure:"patterns" json:"patterns,omitempty" toml:"patterns,omitempty"`       // Regexps or built-in names like "bearer-token", masked anywhere
+	Mask      string   `mapstructure:"mask" json:"mask,omitempty" toml:"mask,omitempty"`                   // Replacement, defaults to "[REDACTED]"
+}
+
+// SysConfigProxyFaults describes the faults injected into a proxy's exchanges.
+// Percentages are 0 to 100 and durations use Go syntax like "250ms".
+type SysConfigProxyFaults struct {
+	Latency       string  `mapstructure:"latency" json:"latency,omitempty" toml:"latency,omitempty"`                      // Added before each request is answered
+	LatencyJitter string  `mapstructure:"latency-jitter" json:"latency_jitter,omitempty" toml:"latency-jitter,omitempty"` // Random extra latency, up to this
+	ErrorPercent  float64 `mapstructure:"error-percent" json:"error_percent,omitempty" toml:"error-percent,omitempty"`    // Requests answered with ErrorStatus instead of the target
+	ErrorStatus   int     `mapstructure:"error-status" json:"error_status,omitempty" toml:"error-status,omitempty"`       // Defaults to 503
+	DropPercent   float64 `mapstructure:"drop-percent" json:"drop_percent,omitempty" toml:"drop-percent,omitempty"`       // Responses whose connection is cut mid-body
+	Bandwidth     int     `mapstructure:"bandwidth" json:"bandwidth,omitempty" toml:"bandwidth,omitempty"`                // Max response bytes per second, 0 is unlimited
+}
+
+// ProxyMode returns the entry's mode, defaulting to reverse proxying
+func (e SysConfigProxyEntry) ProxyMode() string {
+	if e.Mode == "" {
+		return ProxyModeReverse
+	}
+	return e.Mode
 }
@@ -1,6 +1,10 @@
 package core
 
 import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
@@ -8,6 +12,8 @@ import (
 	"strings"
 	"unicode"
 	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
 )
 
 func IsDev() bool {
@@ -84,6 +90,62 @@ func removeHopByHopHeaders(header http.Header) {
 	}
 }
 
+// acceptsTrailers reports whether a client announced it handles trailers with "TE: trailers"
+func acceptsTrailers(h http.Header) bool {
+	for _, v := range h.Values("Te") {
+		for _, part := range strings.Split(v, ",") {
+			if name, _, _ := strings.Cut(strings.TrimSpace(part), ";"); strings.EqualFold(name, "trailers") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// receivedTrailers returns the trailers that arrived with a body, nil for none.
+// Announced trailers that never came have no values.
+func receivedTrailers(trailer http.Header) http.Header {
+	var out http.Header
+	for name, values := range trailer {
+		if len(values) == 0 {
+			continue
+		}
+		if out == nil {
+			out = http.Header{}
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// decodeContent returns body decoded from a gzip, br or deflate content encoding
+func decodeContent(body []byte, encoding string) ([]byte, error) {
+	var reader io.Reader
+	switch encoding {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("gzip reader init failed: %w", err)
+		}
+		defer gzipReader.Close()
+		reader = gzipReader
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	case "deflate":
+		flateReader := flate.NewReader(bytes.NewReader(body))
+		defer flateReader.Close()
+		reader = flateReader
+	default:
+		return nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("decompression read failed (%s): %w", encoding, err)
+	}
+	return decoded, nil
+}
+
 // getClientIP extracts the client IP address from request headers or RemoteAddr
 func getClientIP(r *http.Request) string {
 	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
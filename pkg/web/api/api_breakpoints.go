@@ -0,0 +1,86 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+)
+
+// handleGetBreakpoints lists the exchanges paused at a breakpoint
+// GET /api/breakpoints?config_id=...
+func (h *ApiHandler) handleGetBreakpoints(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	breakpoints := core.Breakpoints.List(r.URL.Query().Get("config_id"))
+	writeJSON(w, http.StatusOK, map[string]any{
+		"breakpoints": breakpoints,
+		"count":       len(breakpoints),
+	})
+}
+
+// handleGetBreakpoint returns a single paused exchange
+// GET /api/breakpoints/{id}
+func (h *ApiHandler) handleGetBreakpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bp, err := core.Breakpoints.Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Breakpoint not found", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, bp)
+}
+
+// handleResumeBreakpoint continues a paused exchange, optionally with an edited message
+// POST /api/breakpoints/{id}/resume
+func (h *ApiHandler) handleResumeBreakpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// An empty body resumes the exchange unchanged
+	var edit *core.BreakpointEdit
+	if err := json.NewDecoder(r.Body).Decode(&edit); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := core.Breakpoints.Resume(id, edit); err != nil {
+		if errors.Is(err, core.ErrBreakpointNotFound) {
+			http.Error(w, "Breakpoint not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid edit", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "outcome": core.BreakpointResumed})
+}
+
+// handleAbortBreakpoint fails a paused exchange
+// POST /api/breakpoints/{id}/abort
+func (h *ApiHandler) handleAbortBreakpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := core.Breakpoints.Abort(id); err != nil {
+		http.Error(w, "Breakpoint not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{"id": id, "outcome": core.BreakpointAborted})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleBreakpoints(t *testing.T) {
+	handler := NewHandler(&ApiConfig{DB: setupTestDB(t)})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	req := httptest.NewRequest("GET", "/api/breakpoints?config_id=none", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var list struct {
+		Count int `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if w.Code != http.StatusOK || list.Count != 0 {
+		t.Errorf("Expected empty list, got %d %s", w.Code, w.Body.String())
+	}
+
+	for _, path := range []string{"/api/breakpoints/missing/resume", "/api/breakpoints/missing/abort"} {
+		req := httptest.NewRequest("POST", path, strings.NewReader(""))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+
+	req = httptest.NewRequest("POST", "/api/breakpoints/missing/resume", strings.NewReader("{"))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid body, got %d", w.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+)
+
+// handleCACert serves the ihpp CA certificate so clients can trust intercepted HTTPS traffic
+// GET /api/ca/cert
+func (h *ApiHandler) handleCACert(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ca, err := core.DefaultCA()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to load certificate authority", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Header().Set("Content-Disposition", `attachment; filename="ihpp-ca.pem"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(ca.CertPEM)
+}
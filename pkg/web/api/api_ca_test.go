@@ -0,0 +1,32 @@
+package api
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleCACert(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	handler := NewHandler(&ApiConfig{DB: nil})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	req := httptest.NewRequest("GET", "/api/ca/cert", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/x-pem-file" {
+		t.Errorf("Expected PEM content type, got %s", ct)
+	}
+
+	block, _ := pem.Decode(w.Body.Bytes())
+	if block == nil || block.Type != "CERTIFICATE" {
+		t.Errorf("Expected a PEM encoded certificate, got %q", w.Body.String())
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
@@ -127,6 +128,7 @@ func (h *ApiHandler) handleConfigs(w http.ResponseWriter, r *http.Request) {
 		ConfigRow           any       `json:"config_row"`
 		ParsedConfig        any       `json:"parsed_config"`
 		IsProxyServerActive bool      `json:"is_proxyserver_active"`
+		Mode                string    `json:"mode,omitempty"`
 		TargetURL           string    `json:"target_url,omitempty"`
 		TruncateLogBody     bool      `json:"truncate_log_body"`
 		SessionCount        int64     `json:"session_count"`
@@ -162,7 +164,11 @@ func (h *ApiHandler) handleConfigs(w http.ResponseWriter, r *http.Request) {
 
 		// Add runtime proxy config details if available
 		if proxyConfig != nil {
+			fullConfig.Mode = proxyConfig.Mode
 			fullConfig.TargetURL = proxyConfig.TargetURL.String()
+			if len(proxyConfig.Entry.Targets) > 0 {
+				fullConfig.TargetURL = strings.Join(proxyConfig.Entry.Targets, ", ")
+			}
 			fullConfig.TruncateLogBody = proxyConfig.TruncateLogBody
 		} else {
 			// Fallback for non-active configs: try to extract target_url from parsedJSON
@@ -205,6 +211,14 @@ func (h *ApiHandler) handleDeleteConfig(w http.ResponseWriter, r *http.Request)
 		if err := tx.Where("config_id = ?", id).Delete(&core.ProxySessionRow{}).Error; err != nil {
 			return err
 		}
+		// Delete counts of exchanges that were not stored
+		if err := tx.Where("config_id = ?", id).Delete(&core.ProxySessionCountRow{}).Error; err != nil {
+			return err
+		}
+		// Delete rewrite rules
+		if err := tx.Where("config_id = ?", id).Delete(&core.ProxyRuleRow{}).Error; err != nil {
+			return err
+		}
 		// Delete config
 		if err := tx.Delete(&core.ProxyConfigRow{}, "id = ?", id).Error; err != nil {
 			return err
@@ -258,7 +272,9 @@ func (h *ApiHandler) handleConfigDetail(w http.ResponseWriter, r *http.Request)
 	// Add runtime details if available
 	if proxyConfig != nil {
 		response["runtime_config"] = map[string]any{
+			"mode":              proxyConfig.Mode,
 			"target_url":        proxyConfig.TargetURL.String(),
+			"targets":           proxyConfig.Entry.Targets,
 			"truncate_log_body": proxyConfig.TruncateLogBody,
 		}
 	}
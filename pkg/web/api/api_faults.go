@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+	"github.com/rs/zerolog/log"
+)
+
+// handleGetFaults returns the faults a proxy injects
+// GET /api/proxyserver/{id}/faults
+func (h *ApiHandler) handleGetFaults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	pc := core.GlobalVar.GetProxyConfig(configID)
+	if pc == nil {
+		http.Error(w, "Proxy not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"faults":    pc.Faults().Spec(),
+	})
+}
+
+// handleSetFaults replaces the faults a proxy injects, until it is restarted
+// POST /api/proxyserver/{id}/faults
+func (h *ApiHandler) handleSetFaults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var spec core.SysConfigProxyFaults
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.setFaults(w, r.PathValue("id"), &spec)
+}
+
+// handleDeleteFaults stops a proxy from injecting faults
+// DELETE /api/proxyserver/{id}/faults
+func (h *ApiHandler) handleDeleteFaults(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.setFaults(w, r.PathValue("id"), nil)
+}
+
+func (h *ApiHandler) setFaults(w http.ResponseWriter, configID string, spec *core.SysConfigProxyFaults) {
+	faults, err := core.SetProxyFaults(configID, spec)
+	if err != nil {
+		if errors.Is(err, core.ErrProxyNotFound) {
+			http.Error(w, "Proxy not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Invalid faults", err)
+		return
+	}
+
+	log.Info().Str("config_id", configID).Interface("faults", faults.Spec()).Msg("Updated injected faults")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"faults":    faults.Spec(),
+	})
+}
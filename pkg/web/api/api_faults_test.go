@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+)
+
+func TestHandleFaults(t *testing.T) {
+	handler := NewHandler(&ApiConfig{DB: setupTestDB(t)})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	pc := &core.ProxyConfig{ConfigID: "faults-config"}
+	core.GlobalVar.AddProxyConfig(pc.ConfigID, pc)
+	defer core.GlobalVar.RemoveProxyConfig(pc.ConfigID)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/proxyserver/faults-config/faults", `{"latency": "100ms", "error_percent": 5}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if spec := pc.Faults().Spec(); spec.Latency != "100ms" || spec.ErrorPercent != 5 {
+		t.Errorf("Expected the faults to be applied, got %+v", spec)
+	}
+
+	w = do("GET", "/api/proxyserver/faults-config/faults", "")
+	var got struct {
+		Faults core.SysConfigProxyFaults `json:"faults"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &got)
+	if got.Faults.Latency != "100ms" {
+		t.Errorf("Unexpected faults: %s", w.Body.String())
+	}
+
+	if w := do("POST", "/api/proxyserver/faults-config/faults", `{"drop_percent": 150}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid faults, got %d", w.Code)
+	}
+	if w := do("POST", "/api/proxyserver/missing/faults", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown proxy, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/api/proxyserver/faults-config/faults", ""); w.Code != http.StatusOK || pc.Faults() != nil {
+		t.Errorf("Expected faults to be cleared, got %d", w.Code)
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+	"github.com/rs/zerolog/log"
+)
+
+// handleGetOmitHeaders returns the headers a proxy leaves out of its sessions
+// GET /api/proxyserver/{id}/omit-headers
+func (h *ApiHandler) handleGetOmitHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	pc := core.GlobalVar.GetProxyConfig(configID)
+	if pc == nil {
+		http.Error(w, "Proxy not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"headers":   pc.OmitHeaders(),
+	})
+}
+
+// handleSetOmitHeaders replaces the headers a proxy leaves out of its sessions. The change is
+// runtime-only and lost on restart unless the config is exported (POST /api/proxyserver/export)
+// POST /api/proxyserver/{id}/omit-headers
+func (h *ApiHandler) handleSetOmitHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Headers []string `json:"headers"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	h.setOmitHeaders(w, r.PathValue("id"), req.Headers)
+}
+
+// handleDeleteOmitHeaders makes a proxy store all headers again, until it is restarted
+// DELETE /api/proxyserver/{id}/omit-headers
+func (h *ApiHandler) handleDeleteOmitHeaders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.setOmitHeaders(w, r.PathValue("id"), nil)
+}
+
+func (h *ApiHandler) setOmitHeaders(w http.ResponseWriter, configID string, names []string) {
+	headers, err := core.SetProxyOmitHeaders(configID, names)
+	if err != nil {
+		if errors.Is(err, core.ErrProxyNotFound) {
+			http.Error(w, "Proxy not found", http.StatusNotFound)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, "Failed to update omitted headers", err)
+		return
+	}
+
+	log.Info().Str("config_id", configID).Strs("headers", headers).Msg("Updated omitted headers")
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"headers":   headers,
+	})
+}
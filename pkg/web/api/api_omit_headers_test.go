@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+)
+
+func TestHandleOmitHeaders(t *testing.T) {
+	handler := NewHandler(&ApiConfig{DB: setupTestDB(t)})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	pc := &core.ProxyConfig{ConfigID: "omit-config"}
+	core.GlobalVar.AddProxyConfig(pc.ConfigID, pc)
+	defer core.GlobalVar.RemoveProxyConfig(pc.ConfigID)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/proxyserver/omit-config/omit-headers", `{"headers": ["x-forwarded-for", "Cf-Ray"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if names := pc.OmitHeaders(); len(names) != 2 || names[0] != "X-Forwarded-For" {
+		t.Errorf("Expected the headers to be applied, got %v", names)
+	}
+
+	w = do("GET", "/api/proxyserver/omit-config/omit-headers", "")
+	if !strings.Contains(w.Body.String(), `"headers":["X-Forwarded-For","Cf-Ray"]`) {
+		t.Errorf("Unexpected omitted headers: %s", w.Body.String())
+	}
+
+	if w := do("POST", "/api/proxyserver/missing/omit-headers", `{}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown proxy, got %d", w.Code)
+	}
+
+	w = do("DELETE", "/api/proxyserver/omit-config/omit-headers", "")
+	if w.Code != http.StatusOK || len(pc.OmitHeaders()) != 0 || !strings.Contains(w.Body.String(), `"headers":[]`) {
+		t.Errorf("Expected omitted headers to be cleared, got %d: %s", w.Code, w.Body.String())
+	}
+}
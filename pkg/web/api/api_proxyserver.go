@@ -125,7 +125,7 @@ func (h *ApiHandler) handleProxyServerCreate(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if entry.Listen == "" || entry.Target == "" {
+	if entry.Listen == "" || (entry.Target == "" && len(entry.Targets) == 0 && len(entry.Routes) == 0 && (entry.ProxyMode() == core.ProxyModeReverse || entry.ProxyMode() == core.ProxyModeFallback)) {
 		writeError(w, http.StatusBadRequest, "Missing listen or target", nil)
 		return
 	}
@@ -47,3 +47,33 @@ func TestHandleProxyServer(t *testing.T) {
 	// But it's asynchronous or started in a goroutine?
 	// Let's just check it doesn't panic and returns a valid status.
 }
+
+func TestHandleProxyServerCreate_Targets(t *testing.T) {
+	db := setupTestDB(t)
+	handler := NewHandler(&ApiConfig{DB: db})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	// A load balanced proxy has no single target
+	body, _ := json.Marshal(map[string]any{
+		"listen":  ":0",
+		"targets": []string{"http://example.com", "http://example.org"},
+	})
+	req := httptest.NewRequest("POST", "/api/proxyserver/create", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for a proxy with only targets, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// Without any target it is still rejected
+	body, _ = json.Marshal(map[string]any{"listen": ":0"})
+	req = httptest.NewRequest("POST", "/api/proxyserver/create", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 without a target, got %d", w.Code)
+	}
+}
@@ -47,24 +47,49 @@ func (h *ApiHandler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/configs/{id}/sessions", h.handleSessionsByConfig)
 	mux.HandleFunc("DELETE /api/configs/{id}", h.handleDeleteConfig)
 
+	// Certificate authority used for HTTPS interception
+	mux.HandleFunc("GET /api/ca/cert", h.handleCACert)
+
 	// Proxy server control endpoints
 	mux.HandleFunc("/api/proxyserver/create", h.handleProxyServerCreate)
 	mux.HandleFunc("/api/proxyserver/export", h.handleProxyServerExport)
 	mux.HandleFunc("/api/proxyserver/{id}/start", h.handleProxyServerStart)
 	mux.HandleFunc("/api/proxyserver/{id}/stop", h.handleProxyServerStop)
+	mux.HandleFunc("GET /api/proxyserver/{id}/rules", h.handleGetRules)
+	mux.HandleFunc("POST /api/proxyserver/{id}/rules", h.handleCreateRule)
+	mux.HandleFunc("GET /api/proxyserver/{id}/rules/{rule_id}", h.handleGetRule)
+	mux.HandleFunc("PATCH /api/proxyserver/{id}/rules/{rule_id}", h.handleUpdateRule)
+	mux.HandleFunc("DELETE /api/proxyserver/{id}/rules/{rule_id}", h.handleDeleteRule)
+	mux.HandleFunc("POST /api/proxyserver/{id}/rules/from-session/{session_id}", h.handleCreateMockRule)
+	mux.HandleFunc("POST /api/proxyserver/{id}/rules/from-bookmark/{bookmark_id}", h.handleCreateMockRule)
+	mux.HandleFunc("GET /api/proxyserver/{id}/faults", h.handleGetFaults)
+	mux.HandleFunc("POST /api/proxyserver/{id}/faults", h.handleSetFaults)
+	mux.HandleFunc("DELETE /api/proxyserver/{id}/faults", h.handleDeleteFaults)
+	mux.HandleFunc("GET /api/proxyserver/{id}/omit-headers", h.handleGetOmitHeaders)
+	mux.HandleFunc("POST /api/proxyserver/{id}/omit-headers", h.handleSetOmitHeaders)
+	mux.HandleFunc("DELETE /api/proxyserver/{id}/omit-headers", h.handleDeleteOmitHeaders)
+
+	// Exchanges paused at a breakpoint rule
+	mux.HandleFunc("GET /api/breakpoints", h.handleGetBreakpoints)
+	mux.HandleFunc("GET /api/breakpoints/{id}", h.handleGetBreakpoint)
+	mux.HandleFunc("POST /api/breakpoints/{id}/resume", h.handleResumeBreakpoint)
+	mux.HandleFunc("POST /api/breakpoints/{id}/abort", h.handleAbortBreakpoint)
 
 	// Scoped Session Handlers (Contextual to a Config ID)
 	mux.HandleFunc("/api/sessions/recent/{config_id}", h.handleRecentSessions)
 	mux.HandleFunc("/api/sessions/errors/{config_id}", h.handleErrorSessions)
+	mux.HandleFunc("/api/sessions/failed/{config_id}", h.handleFailedSessions)
 	mux.HandleFunc("/api/sessions/slow/{config_id}", h.handleSlowSessions)
 
 	// Scoped Query Handlers
 	mux.HandleFunc("/api/sessions/by-path/{config_id}", h.handleSessionsByPath)
+	mux.HandleFunc("/api/sessions/by-route/{config_id}", h.handleSessionsByRoute)
 	mux.HandleFunc("/api/sessions/by-method/{config_id}", h.handleSessionsByMethod)
 	mux.HandleFunc("/api/sessions/by-header/{config_id}", h.handleSessionsWithHeader)
 	mux.HandleFunc("/api/sessions/by-header-value/{config_id}", h.handleSessionsByHeaderValue)
 	mux.HandleFunc("/api/sessions/by-query-param/{config_id}", h.handleSessionsWithQueryParam)
 	mux.HandleFunc("/api/sessions/search/{config_id}", h.handleSearchSessions)
+	mux.HandleFunc("/api/sessions/frames/{session_id}", h.handleSessionFrames)
 
 	// General Session Handlers
 	mux.HandleFunc("POST /api/sessions/batch", h.handleBatchSessions)
@@ -73,6 +98,7 @@ func (h *ApiHandler) RegisterRoutes(mux *http.ServeMux) {
 	// Global Statistics
 	mux.HandleFunc("/api/stats/methods", h.handleMethodStats)
 	mux.HandleFunc("/api/stats/duration-by-path", h.handleDurationByPath)
+	mux.HandleFunc("/api/stats/counts/{config_id}", h.handleSessionCounts)
 
 	// HttpReq
 	mux.HandleFunc("/api/httpreq", h.handleHttpReq)
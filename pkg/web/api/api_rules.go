@@ -0,0 +1,253 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+)
+
+// rulePayload is the body of rule create and update requests.
+// Fields left out keep their current value on update.
+type rulePayload struct {
+	Name     *string           `json:"name"`
+	Enabled  *bool             `json:"enabled"`
+	Priority *int              `json:"priority"`
+	Phase    *string           `json:"phase"`
+	Match    *core.RuleMatch   `json:"match"`
+	Actions  []core.RuleAction `json:"actions"`
+}
+
+func (p *rulePayload) applyTo(rule *core.ProxyRuleRow) {
+	if p.Name != nil {
+		rule.Name = *p.Name
+	}
+	if p.Enabled != nil {
+		rule.Enabled = *p.Enabled
+	}
+	if p.Priority != nil {
+		rule.Priority = *p.Priority
+	}
+	if p.Phase != nil {
+		rule.Phase = *p.Phase
+	}
+	if p.Match != nil {
+		rule.Match = *p.Match
+	}
+	if p.Actions != nil {
+		rule.Actions = p.Actions
+	}
+}
+
+// handleGetRules lists the rewrite rules of a proxy config
+// GET /api/proxyserver/{id}/rules
+func (h *ApiHandler) handleGetRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	rules, err := core.GetProxyRules(h.db, configID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch rules", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"rules":     rules,
+		"count":     len(rules),
+	})
+}
+
+// handleCreateRule adds a rewrite rule to a proxy config
+// POST /api/proxyserver/{id}/rules
+func (h *ApiHandler) handleCreateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	if !h.configExists(w, configID) {
+		return
+	}
+
+	var payload rulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := &core.ProxyRuleRow{ConfigID: configID, Enabled: true}
+	payload.applyTo(rule)
+	if err := core.ValidateProxyRule(rule); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rule", err)
+		return
+	}
+	if err := core.CreateProxyRule(h.db, rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create rule", err)
+		return
+	}
+
+	h.reloadRules(configID)
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// handleGetRule returns a single rewrite rule
+// GET /api/proxyserver/{id}/rules/{rule_id}
+func (h *ApiHandler) handleGetRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rule, err := core.GetProxyRule(h.db, r.PathValue("id"), r.PathValue("rule_id"))
+	if err != nil {
+		writeRuleLookupError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// handleUpdateRule changes the fields given in the body of a rewrite rule
+// PATCH /api/proxyserver/{id}/rules/{rule_id}
+func (h *ApiHandler) handleUpdateRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	rule, err := core.GetProxyRule(h.db, configID, r.PathValue("rule_id"))
+	if err != nil {
+		writeRuleLookupError(w, err)
+		return
+	}
+
+	var payload rulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	payload.applyTo(rule)
+	if err := core.ValidateProxyRule(rule); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rule", err)
+		return
+	}
+	if err := core.SaveProxyRule(h.db, rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update rule", err)
+		return
+	}
+
+	h.reloadRules(configID)
+	writeJSON(w, http.StatusOK, rule)
+}
+
+// handleDeleteRule removes a rewrite rule
+// DELETE /api/proxyserver/{id}/rules/{rule_id}
+func (h *ApiHandler) handleDeleteRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	if err := core.DeleteProxyRule(h.db, configID, r.PathValue("rule_id")); err != nil {
+		writeRuleLookupError(w, err)
+		return
+	}
+
+	h.reloadRules(configID)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleCreateMockRule adds a mock rule replaying a recorded response
+// POST /api/proxyserver/{id}/rules/from-session/{session_id}
+// POST /api/proxyserver/{id}/rules/from-bookmark/{bookmark_id}
+func (h *ApiHandler) handleCreateMockRule(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("id")
+	if !h.configExists(w, configID) {
+		return
+	}
+
+	var rule *core.ProxyRuleRow
+	var err error
+	if bookmarkID := r.PathValue("bookmark_id"); bookmarkID != "" {
+		rule, err = core.MockRuleFromBookmark(h.db, configID, bookmarkID)
+	} else {
+		rule, err = core.MockRuleFromSession(h.db, configID, r.PathValue("session_id"))
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Session or bookmark not found", http.StatusNotFound)
+			return
+		}
+		if errors.Is(err, core.ErrAlteredRecording) {
+			writeError(w, http.StatusConflict, "Recorded response was altered when stored", err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Failed to build mock", err)
+		return
+	}
+
+	// Optional overrides, e.g. a name or a broader match
+	var payload rulePayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	payload.applyTo(rule)
+	if err := core.ValidateProxyRule(rule); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid rule", err)
+		return
+	}
+	if err := core.CreateProxyRule(h.db, rule); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create rule", err)
+		return
+	}
+
+	h.reloadRules(configID)
+	writeJSON(w, http.StatusCreated, rule)
+}
+
+// reloadRules applies the stored rules to the running proxy, if any
+func (h *ApiHandler) reloadRules(configID string) {
+	if err := core.ReloadProxyRules(h.db, configID); err != nil {
+		log.Error().Err(err).Str("config_id", configID).Msg("Failed to reload rewrite rules")
+	}
+}
+
+// configExists reports whether configID is a known config, answering 404 or 500 when not
+func (h *ApiHandler) configExists(w http.ResponseWriter, configID string) bool {
+	configRow, err := core.GetConfigRowByID(h.db, configID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Database error", err)
+		return false
+	}
+	if configRow == nil {
+		http.Error(w, "Config not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+func writeRuleLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Rule not found", http.StatusNotFound)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, "Database error", err)
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/liyu1981/inspect-http-proxy-plus/pkg/core"
+)
+
+func TestHandleRules(t *testing.T) {
+	db := setupTestDB(t)
+	handler := NewHandler(&ApiConfig{DB: db})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	config := core.ProxyConfigRow{ID: "rules-config", SourcePath: "test", ConfigJSON: "{}"}
+	db.Create(&config)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader([]byte(body)))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// 1. Create
+	w := do("POST", "/api/proxyserver/rules-config/rules", `{
+		"name": "strip cookie",
+		"phase": "request",
+		"match": {"path_prefix": "/api"},
+		"actions": [{"type": "remove_header", "name": "Cookie"}]
+	}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var created core.ProxyRuleRow
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID == "" || !created.Enabled || created.Match.PathPrefix != "/api" {
+		t.Errorf("Unexpected created rule: %+v", created)
+	}
+
+	// 2. Invalid rules and unknown configs are rejected
+	if w := do("POST", "/api/proxyserver/rules-config/rules", `{"phase": "request", "actions": [{"type": "set_status", "status": 500}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid rule, got %d", w.Code)
+	}
+	if w := do("POST", "/api/proxyserver/missing/rules", `{"phase": "request"}`); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown config, got %d", w.Code)
+	}
+
+	// 3. Update only the given fields
+	w = do("PATCH", "/api/proxyserver/rules-config/rules/"+created.ID, `{"enabled": false, "priority": 5}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var updated core.ProxyRuleRow
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if updated.Enabled || updated.Priority != 5 || updated.Name != "strip cookie" || len(updated.Actions) != 1 {
+		t.Errorf("Unexpected updated rule: %+v", updated)
+	}
+
+	// 4. List
+	w = do("GET", "/api/proxyserver/rules-config/rules", "")
+	var list struct {
+		Rules []core.ProxyRuleRow `json:"rules"`
+		Count int                 `json:"count"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if list.Count != 1 || list.Rules[0].ID != created.ID || list.Rules[0].Enabled {
+		t.Errorf("Unexpected rule list: %s", w.Body.String())
+	}
+
+	// 5. Delete
+	if w := do("DELETE", "/api/proxyserver/rules-config/rules/"+created.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if w := do("GET", "/api/proxyserver/rules-config/rules/"+created.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 after delete, got %d", w.Code)
+	}
+}
+
+func TestHandleCreateMockRule(t *testing.T) {
+	db := setupTestDB(t)
+	handler := NewHandler(&ApiConfig{DB: db})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	db.Create(&core.ProxyConfigRow{ID: "mock-config", SourcePath: "test", ConfigJSON: "{}"})
+	session, _ := core.CreateProxySession(db, &core.LogEntry{
+		ConfigID:       "mock-config",
+		RequestMethod:  "GET",
+		RequestURL:     &url.URL{Path: "/todo"},
+		RequestHeaders: http.Header{},
+		StatusCode:     http.StatusOK,
+		ResponseBody:   []byte(`{"done":true}`),
+	})
+	bookmark, _ := core.CreateBookmark(db, session.ID)
+
+	for _, path := range []string{
+		"/api/proxyserver/mock-config/rules/from-session/" + session.ID,
+		"/api/proxyserver/mock-config/rules/from-bookmark/" + bookmark.ID,
+	} {
+		req := httptest.NewRequest("POST", path, bytes.NewReader([]byte(`{"name":"todo stub"}`)))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status 201, got %d: %s", path, w.Code, w.Body.String())
+		}
+		var rule core.ProxyRuleRow
+		json.Unmarshal(w.Body.Bytes(), &rule)
+		if rule.Name != "todo stub" || rule.Actions[0].Type != core.RuleActionMock || rule.Actions[0].Body != `{"done":true}` {
+			t.Errorf("%s: unexpected mock rule %+v", path, rule)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/api/proxyserver/mock-config/rules/from-session/missing", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for unknown session, got %d", w.Code)
+	}
+
+	// A body stored without its content cannot be served again
+	noBodies, _ := core.CreateProxySession(db, &core.LogEntry{
+		ConfigID:       "mock-config",
+		RequestMethod:  "GET",
+		RequestURL:     &url.URL{Path: "/todo"},
+		RequestHeaders: http.Header{},
+		StatusCode:     http.StatusOK,
+		ResponseBody:   []byte(`{"done":true}`),
+		CaptureAction:  core.CaptureNoBodies,
+	})
+	noBodiesBookmark, _ := core.CreateBookmark(db, noBodies.ID)
+	for _, path := range []string{
+		"/api/proxyserver/mock-config/rules/from-session/" + noBodies.ID,
+		"/api/proxyserver/mock-config/rules/from-bookmark/" + noBodiesBookmark.ID,
+	} {
+		req := httptest.NewRequest("POST", path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("%s: expected status 409 for a session stored without bodies, got %d", path, w.Code)
+		}
+	}
+}
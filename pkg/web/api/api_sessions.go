@@ -91,6 +91,32 @@ func (h *ApiHandler) handleErrorSessions(w http.ResponseWriter, r *http.Request)
 	})
 }
 
+// handleFailedSessions returns sessions whose target could not be reached for a specific config
+func (h *ApiHandler) handleFailedSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("config_id")
+	limit := getIntParam(r, "limit", 20)
+	offset := getIntParam(r, "offset", 0)
+
+	sessions, err := core.GetFailedSessions(h.db, configID, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch failed sessions", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"count":     len(sessions),
+		"limit":     limit,
+		"offset":    offset,
+		"sessions":  sessions,
+	})
+}
+
 // handleSlowSessions returns slow sessions for a specific config
 func (h *ApiHandler) handleSlowSessions(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -100,10 +126,16 @@ func (h *ApiHandler) handleSlowSessions(w http.ResponseWriter, r *http.Request)
 
 	configID := r.PathValue("config_id")
 	minDuration := int64(getIntParam(r, "min_duration", 1000))
+	sortBy := r.URL.Query().Get("sort")
 	limit := getIntParam(r, "limit", 20)
 	offset := getIntParam(r, "offset", 0)
 
-	sessions, err := core.GetSlowSessions(h.db, configID, minDuration, limit, offset)
+	if _, ok := core.SlowSessionSortColumns[sortBy]; sortBy != "" && !ok {
+		writeError(w, http.StatusBadRequest, "Invalid sort phase", nil)
+		return
+	}
+
+	sessions, err := core.GetSlowSessions(h.db, configID, minDuration, sortBy, limit, offset)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to fetch slow sessions", err)
 		return
@@ -112,6 +144,7 @@ func (h *ApiHandler) handleSlowSessions(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]any{
 		"config_id":    configID,
 		"min_duration": minDuration,
+		"sort":         sortBy,
 		"count":        len(sessions),
 		"limit":        limit,
 		"offset":       offset,
@@ -147,6 +180,34 @@ func (h *ApiHandler) handleSessionsByPath(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// handleSessionsByRoute returns sessions sent through a specific route
+func (h *ApiHandler) handleSessionsByRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("config_id")
+	route := r.URL.Query().Get("route")
+	limit := getIntParam(r, "limit", 20)
+	offset := getIntParam(r, "offset", 0)
+
+	sessions, err := core.GetSessionsByRoute(h.db, configID, route, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Query failed", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"route":     route,
+		"count":     len(sessions),
+		"limit":     limit,
+		"offset":    offset,
+		"sessions":  sessions,
+	})
+}
+
 // handleSessionsByMethod returns sessions matching a specific HTTP method
 func (h *ApiHandler) handleSessionsByMethod(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
@@ -323,12 +384,55 @@ func (h *ApiHandler) handleSessionDetail(w http.ResponseWriter, r *http.Request)
 	reqH, _ := session.ParseRequestHeaders()
 	respH, _ := session.ParseResponseHeaders()
 	qp, _ := session.ParseQueryParameters()
+	reqT, _ := session.ParseRequestTrailers()
+	respT, _ := session.ParseResponseTrailers()
+
+	// Server-Sent Events parsed from the response, the first one timing the stream
+	events, err := core.GetSessionEvents(h.db, session.ID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch events", err)
+		return
+	}
+	var timeToFirstEvent *int64
+	if len(events) > 0 {
+		timeToFirstEvent = &events[0].OffsetMs
+	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"session":          session,
-		"request_headers":  reqH,
-		"response_headers": respH,
-		"query_parameters": qp,
+		"session":                session,
+		"request_headers":        reqH,
+		"response_headers":       respH,
+		"query_parameters":       qp,
+		"request_trailers":       reqT,
+		"response_trailers":      respT,
+		"sse_events":             events,
+		"time_to_first_event_ms": timeToFirstEvent,
+	})
+}
+
+// handleSessionFrames returns the WebSocket frames captured for a session
+func (h *ApiHandler) handleSessionFrames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := r.PathValue("session_id")
+	limit := getIntParam(r, "limit", 0)
+	offset := getIntParam(r, "offset", 0)
+
+	frames, err := core.GetSessionFrames(h.db, sessionID, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch frames", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"session_id": sessionID,
+		"count":      len(frames),
+		"limit":      limit,
+		"offset":     offset,
+		"frames":     frames,
 	})
 }
 
@@ -361,10 +465,12 @@ func (h *ApiHandler) handleBatchSessions(w http.ResponseWriter, r *http.Request)
 	}
 
 	type sessionDetail struct {
-		Session         core.ProxySessionRow `json:"session"`
-		RequestHeaders  http.Header          `json:"request_headers"`
-		ResponseHeaders http.Header          `json:"response_headers"`
-		QueryParameters any                  `json:"query_parameters"`
+		Session          core.ProxySessionRow `json:"session"`
+		RequestHeaders   http.Header          `json:"request_headers"`
+		ResponseHeaders  http.Header          `json:"response_headers"`
+		QueryParameters  any                  `json:"query_parameters"`
+		RequestTrailers  http.Header          `json:"request_trailers"`
+		ResponseTrailers http.Header          `json:"response_trailers"`
 	}
 
 	details := make([]sessionDetail, 0, len(sessions))
@@ -372,12 +478,16 @@ func (h *ApiHandler) handleBatchSessions(w http.ResponseWriter, r *http.Request)
 		reqH, _ := s.ParseRequestHeaders()
 		respH, _ := s.ParseResponseHeaders()
 		qp, _ := s.ParseQueryParameters()
+		reqT, _ := s.ParseRequestTrailers()
+		respT, _ := s.ParseResponseTrailers()
 
 		details = append(details, sessionDetail{
-			Session:         s,
-			RequestHeaders:  reqH,
-			ResponseHeaders: respH,
-			QueryParameters: qp,
+			Session:          s,
+			RequestHeaders:   reqH,
+			ResponseHeaders:  respH,
+			QueryParameters:  qp,
+			RequestTrailers:  reqT,
+			ResponseTrailers: respT,
 		})
 	}
 
@@ -84,6 +84,13 @@ func TestHandleSessionDetail(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create session: %v", err)
 	}
+	events := []*core.ProxySessionEventRow{
+		{SessionID: session.ID, Seq: 1, Timestamp: time.Now(), OffsetMs: 42, Data: "first"},
+		{SessionID: session.ID, Seq: 2, Timestamp: time.Now(), OffsetMs: 80, Event: "done"},
+	}
+	if err := core.CreateSessionEvents(db, events); err != nil {
+		t.Fatalf("Failed to create events: %v", err)
+	}
 
 	handler := NewHandler(&ApiConfig{DB: db})
 	mux := http.NewServeMux()
@@ -115,4 +122,98 @@ func TestHandleSessionDetail(t *testing.T) {
 	if sessMap["RequestMethod"] != "POST" {
 		t.Errorf("Expected method POST, got %v", sessMap["RequestMethod"])
 	}
+
+	sseEvents, ok := result["sse_events"].([]any)
+	if !ok || len(sseEvents) != 2 {
+		t.Fatalf("Expected 2 sse_events, got %v", result["sse_events"])
+	}
+	if first := sseEvents[0].(map[string]any); first["Data"] != "first" {
+		t.Errorf("Expected the events in order, got %v", first)
+	}
+	if result["time_to_first_event_ms"] != float64(42) {
+		t.Errorf("Expected time_to_first_event_ms 42, got %v", result["time_to_first_event_ms"])
+	}
+}
+
+func TestHandleSessionFrames(t *testing.T) {
+	db := setupTestDB(t)
+
+	for i, payload := range []string{"ping", "pong"} {
+		frame := &core.ProxySessionFrameRow{
+			SessionID: "ws-session",
+			Seq:       int64(i + 1),
+			Timestamp: time.Now(),
+			Direction: core.WsFrameClientToServer,
+			Opcode:    1,
+			Fin:       true,
+			Payload:   []byte(payload),
+		}
+		if err := core.CreateSessionFrame(db, frame); err != nil {
+			t.Fatalf("Failed to create frame: %v", err)
+		}
+	}
+
+	handler := NewHandler(&ApiConfig{DB: db})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	req := httptest.NewRequest("GET", "/api/sessions/frames/ws-session", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var result map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result["count"] != float64(2) {
+		t.Errorf("Expected 2 frames, got %v", result["count"])
+	}
+}
+
+func TestHandleFailedSessions(t *testing.T) {
+	db := setupTestDB(t)
+	configID := "config-failed-test"
+
+	u, _ := url.Parse("http://unreachable.invalid/foo")
+	for _, errorKind := range []string{"", core.UpstreamErrorDNS} {
+		entry := &core.LogEntry{
+			ConfigID:       configID,
+			Timestamp:      time.Now(),
+			ClientAddr:     "127.0.0.1:12345",
+			RequestMethod:  "GET",
+			RequestURL:     u,
+			RequestProto:   "HTTP/1.1",
+			RequestHost:    "unreachable.invalid",
+			RequestHeaders: http.Header{},
+			StatusCode:     http.StatusBadGateway,
+			ErrorKind:      errorKind,
+		}
+		if _, err := core.CreateProxySession(db, entry); err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+	}
+
+	handler := NewHandler(&ApiConfig{DB: db})
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	req := httptest.NewRequest("GET", "/api/sessions/failed/"+configID, nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var result map[string]any
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result["count"] != float64(1) {
+		t.Errorf("Expected 1 failed session, got %v", result["count"])
+	}
 }
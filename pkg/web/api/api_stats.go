@@ -41,3 +41,28 @@ func (h *ApiHandler) handleDurationByPath(w http.ResponseWriter, r *http.Request
 		"stats": stats,
 	})
 }
+
+// handleSessionCounts returns the exchanges of a config that capture filters only counted
+// GET /api/stats/counts/{config_id}
+func (h *ApiHandler) handleSessionCounts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	configID := r.PathValue("config_id")
+	limit := getIntParam(r, "limit", 100)
+	offset := getIntParam(r, "offset", 0)
+
+	counts, err := core.GetSessionCounts(h.db, configID, limit, offset)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch session counts", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"config_id": configID,
+		"counts":    counts,
+		"count":     len(counts),
+	})
+}
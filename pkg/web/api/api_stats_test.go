@@ -65,4 +65,41 @@ func TestHandleStats(t *testing.T) {
 	if durStats["/a"].(float64) != 150 {
 		t.Errorf("Expected avg 150 for /a, got %v", durStats["/a"])
 	}
+
+	// 3. Exchanges only counted by capture filters are part of the stats
+	core.CountProxySession(db, &core.LogEntry{
+		ConfigID:      "counted",
+		RequestMethod: "GET",
+		RequestURL:    &url.URL{Path: "/a"},
+		Duration:      600 * time.Millisecond,
+		StatusCode:    200,
+		Timestamp:     time.Now(),
+	})
+
+	req = httptest.NewRequest("GET", "/api/stats/methods", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&methodsResp)
+	if stats := methodsResp["stats"].(map[string]any); stats["GET"].(float64) != 3 {
+		t.Errorf("Expected 3 GETs with the counted one, got %v", stats["GET"])
+	}
+
+	req = httptest.NewRequest("GET", "/api/stats/duration-by-path", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	json.NewDecoder(w.Body).Decode(&durationResp)
+	if durStats := durationResp["stats"].(map[string]any); durStats["/a"].(float64) != 300 {
+		t.Errorf("Expected avg 300 for /a with the counted one, got %v", durStats["/a"])
+	}
+
+	req = httptest.NewRequest("GET", "/api/stats/counts/counted", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var countsResp struct {
+		Counts []core.ProxySessionCountRow `json:"counts"`
+	}
+	json.NewDecoder(w.Body).Decode(&countsResp)
+	if len(countsResp.Counts) != 1 || countsResp.Counts[0].Count != 1 || countsResp.Counts[0].TotalDurationMs != 600 {
+		t.Errorf("Unexpected counts: %+v", countsResp.Counts)
+	}
 }
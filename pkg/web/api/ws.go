@@ -84,11 +84,7 @@ func (h *WsHub) run() {
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				for topic := range client.topics {
-					if _, ok := h.subscriptions[topic]; ok {
-						delete(h.subscriptions[topic], client)
-					}
-				}
+				h.unsubscribeAll(client)
 				close(client.send)
 			}
 			h.mu.Unlock()
@@ -102,6 +98,7 @@ func (h *WsHub) run() {
 					default:
 						close(client.send)
 						delete(h.clients, client)
+						h.unsubscribeAll(client)
 					}
 				}
 			}
@@ -110,6 +107,24 @@ func (h *WsHub) run() {
 	}
 }
 
+// unsubscribe removes client from topic, dropping topics nobody listens to anymore.
+// Per-session topics come and go, so they must not pile up. Must hold h.mu.
+func (h *WsHub) unsubscribe(client *WsClient, topic string) {
+	if clients, ok := h.subscriptions[topic]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.subscriptions, topic)
+		}
+	}
+}
+
+// unsubscribeAll removes client from all its topics. Must hold h.mu.
+func (h *WsHub) unsubscribeAll(client *WsClient) {
+	for topic := range client.topics {
+		h.unsubscribe(client, topic)
+	}
+}
+
 func (c *WsClient) writePump() {
 	defer func() {
 		c.conn.Close()
@@ -184,9 +199,7 @@ func (c *WsClient) handleUnsubscribeMessage(msg map[string]interface{}) {
 		return
 	}
 	c.hub.mu.Lock()
-	if _, ok := c.hub.subscriptions[topic]; ok {
-		delete(c.hub.subscriptions[topic], c)
-	}
+	c.hub.unsubscribe(c, topic)
 	delete(c.topics, topic)
 	c.hub.mu.Unlock()
 	log.Debug().Str("topic", topic).Msg("Client unsubscribed from topic")
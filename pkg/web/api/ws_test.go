@@ -36,3 +36,38 @@ func TestWsHub(t *testing.T) {
 	}
 	hub.mu.Unlock()
 }
+
+func TestWsHub_DropsEmptyTopics(t *testing.T) {
+	hub := NewWsHub()
+	go hub.run()
+
+	client := &WsClient{
+		hub:    hub,
+		send:   make(chan any, 1),
+		topics: make(map[string]bool),
+	}
+	hub.register <- client
+
+	client.handleSubscribeMessage(map[string]any{"topic": "session:a"})
+	client.handleSubscribeMessage(map[string]any{"topic": "session:b"})
+	client.handleUnsubscribeMessage(map[string]any{"topic": "session:a"})
+
+	hub.mu.Lock()
+	if _, ok := hub.subscriptions["session:a"]; ok {
+		t.Error("Expected the topic without subscribers to be dropped")
+	}
+	if _, ok := hub.subscriptions["session:b"]; !ok {
+		t.Error("Expected the subscribed topic to be kept")
+	}
+	hub.mu.Unlock()
+
+	// Unregistering drops the remaining topics
+	hub.unregister <- client
+	time.Sleep(10 * time.Millisecond)
+
+	hub.mu.Lock()
+	if len(hub.subscriptions) != 0 {
+		t.Errorf("Expected no topics left, got %v", hub.subscriptions)
+	}
+	hub.mu.Unlock()
+}